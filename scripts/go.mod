@@ -1,13 +0,0 @@
-module scripts
-
-go 1.25.0
-
-require (
-	github.com/meilisearch/meilisearch-go v0.33.3
-	github.com/wangbin/jiebago v0.3.2
-)
-
-require (
-	github.com/andybalholm/brotli v1.1.1 // indirect
-	github.com/golang-jwt/jwt/v4 v4.5.2 // indirect
-)
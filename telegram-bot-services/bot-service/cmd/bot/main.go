@@ -2,21 +2,36 @@ package main
 
 import (
 	"bot-service/internal/api/handler"
+	"bot-service/internal/callbackstate"
 	"bot-service/internal/config"
+	"bot-service/internal/i18n"
+	"bot-service/internal/ingest"
 	"bot-service/internal/management"
 	"bot-service/internal/repository"
+	"bot-service/internal/searchlog"
 	"bot-service/internal/usecase"
+	"bot-service/internal/user"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
+	"os"
+	"os/signal"
 	"strings"
 	"sync"
+	"syscall"
 	"time"
 
+	"telegram-bot-services/pkg/search"
+
 	"gopkg.in/telebot.v4"
 )
 
+// shutdownTimeout bounds how long in-flight webhook requests may take on shutdown
+const shutdownTimeout = 10 * time.Second
+
 var (
 	tokenMutex     sync.Mutex
 	tokenIndex     = 0
@@ -31,16 +46,49 @@ func main() {
 	}
 
 	// Initialize dependencies
+	meili := search.New(search.Config{
+		URL: cfg.Storage.MeilisearchURL,
+		Key: cfg.Storage.MeilisearchToken,
+	})
 	storageRepo := repository.NewStorageRepository(repository.StorageConfig{
-		PocketBaseURL:    cfg.Storage.PocketBaseURL,
-		MeilisearchURL:   cfg.Storage.MeilisearchURL,
-		MeilisearchToken: cfg.Storage.MeilisearchToken,
+		PocketBaseURL:   cfg.Storage.PocketBaseURL,
+		PocketBaseToken: cfg.Storage.PocketBaseToken,
+	}, meili)
+
+	messagesIndex := cfg.Search.IndexName
+	if messagesIndex == "" {
+		messagesIndex = ingest.DefaultIndexName
+	}
+	if err := storageRepo.ConfigureMessagesIndex(messagesIndex); err != nil {
+		log.Printf("WARN: Failed to configure messages index %s: %v", messagesIndex, err)
+	}
+	if err := storageRepo.ConfigureChatsIndex(); err != nil {
+		log.Printf("WARN: Failed to configure chats index: %v", err)
+	}
+
+	searchRepo := repository.NewSearchRepository(search.Config{
+		URL:           cfg.Search.MeilisearchURL,
+		Key:           cfg.Search.MeilisearchKey,
+		MessagesIndex: messagesIndex,
+		ChatWeight:    cfg.Search.ChatWeight,
+		MessageWeight: cfg.Search.MessageWeight,
 	})
 
-	searchRepo := repository.NewSearchRepository(cfg.Search.MeilisearchURL, cfg.Search.MeilisearchKey)
+	var stateBackend callbackstate.Backend
+	if cfg.Bot.CallbackStateBackend == "pocketbase" {
+		stateBackend = callbackstate.NewPocketBaseBackend(cfg.Storage.PocketBaseURL, cfg.Storage.PocketBaseToken)
+	}
+	stateStore := callbackstate.NewStore(time.Duration(cfg.Bot.CallbackStateTTL)*time.Second, stateBackend)
+
+	locales := i18n.NewResolver(user.LanguagePreferences{})
+
+	var searchLog searchlog.Logger
+	if cfg.Storage.PocketBaseURL != "" {
+		searchLog = searchlog.NewPocketBaseLogger(cfg.Storage.PocketBaseURL, cfg.Storage.PocketBaseToken)
+	}
 
-	messageUsecase := usecase.NewMessageUsecase(cfg, storageRepo, searchRepo)
-	botHandler := handler.NewBotHandler(messageUsecase, cfg)
+	messageUsecase := usecase.NewMessageUsecase(cfg, storageRepo, searchRepo, stateStore, locales, searchLog)
+	botHandler := handler.NewBotHandler(messageUsecase, locales, cfg, meili)
 
 	// Initialize bots
 	if err := initializeBots(botHandler, cfg); err != nil {
@@ -52,8 +100,22 @@ func main() {
 		log.Printf("Failed to initialize review bot: %v", err)
 	}
 
-	// Start server
-	startServer(botHandler)
+	// Start server and wait for a shutdown signal
+	server := startServer(botHandler)
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, syscall.SIGINT, syscall.SIGTERM)
+	<-stop
+
+	log.Println("Shutting down server")
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := server.Shutdown(ctx); err != nil {
+		log.Printf("WARN: Failed to shut down server: %v", err)
+	}
+	// Updates are no longer accepted, so flush the messages still in the ingest queue
+	messageUsecase.Close()
+	stateStore.Close()
+	log.Println("Server stopped")
 }
 
 func initializeBots(botHandler handler.BotHandler, cfg *config.Config) error {
@@ -119,13 +181,18 @@ func getNextToken(cfg *config.Config) string {
 	return "" // No available token
 }
 
-func startServer(botHandler handler.BotHandler) {
-	http.HandleFunc("/webhook", newWebhookHandler(botHandler))
+func startServer(botHandler handler.BotHandler) *http.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/webhook", newWebhookHandler(botHandler))
+	server := &http.Server{Addr: ":8081", Handler: mux}
 
 	log.Println("Starting server on :8081")
-	if err := http.ListenAndServe(":8081", nil); err != nil {
-		log.Fatalf("Failed to start server: %v", err)
-	}
+	go func() {
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatalf("Failed to start server: %v", err)
+		}
+	}()
+	return server
 }
 
 func newWebhookHandler(botHandler handler.BotHandler) http.HandlerFunc {
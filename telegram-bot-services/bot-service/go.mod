@@ -8,6 +8,7 @@ require (
 	github.com/go-resty/resty/v2 v2.16.5
 	github.com/stretchr/testify v1.11.1
 	gopkg.in/telebot.v4 v4.0.0-beta.5
+	telegram-bot-services/pkg v0.0.0
 )
 
 require (
@@ -17,3 +18,5 @@ require (
 	golang.org/x/net v0.33.0 // indirect
 	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
+
+replace telegram-bot-services/pkg => ../pkg
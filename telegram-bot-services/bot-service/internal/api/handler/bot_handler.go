@@ -2,6 +2,7 @@ package handler
 
 import (
 	"bot-service/internal/config"
+	"bot-service/internal/i18n"
 	"bot-service/internal/index"
 	"bot-service/internal/user"
 	"bot-service/internal/usecase"
@@ -16,6 +17,8 @@ import (
 	"time"
 	"unicode/utf8"
 
+	"telegram-bot-services/pkg/search"
+
 	"gopkg.in/telebot.v4"
 )
 
@@ -52,7 +55,9 @@ type botHandlerImpl struct {
 	bots                map[string]*telebot.Bot
 	mutex               sync.RWMutex
 	messageUsecase      usecase.MessageUsecase
+	locales             *i18n.Resolver
 	cfg                 *config.Config
+	meili               *search.Client
 	tokenMutex          sync.Mutex
 	tokenIndex          int
 	tokenBlacklist      map[string]time.Time
@@ -60,11 +65,13 @@ type botHandlerImpl struct {
 }
 
 // NewBotHandler 创建新的机器人处理器实例
-func NewBotHandler(messageUsecase usecase.MessageUsecase, cfg *config.Config) BotHandler {
+func NewBotHandler(messageUsecase usecase.MessageUsecase, locales *i18n.Resolver, cfg *config.Config, meili *search.Client) BotHandler {
 	return &botHandlerImpl{
 		bots:                  make(map[string]*telebot.Bot),
 		messageUsecase:        messageUsecase,
+		locales:               locales,
 		cfg:                   cfg,
+		meili:                 meili,
 		tokenBlacklist:        make(map[string]time.Time),
 		tokenRotationDuration: time.Duration(cfg.Bot.TokenRotationDuration) * time.Second,
 	}
@@ -172,6 +179,7 @@ func getChatMemberCount(token string, chatID int64) (int, error) {
 func (b *botHandlerImpl) RegisterHandlers(bot *telebot.Bot) {
 	// 处理文本消息
 	bot.Handle(telebot.OnText, func(c telebot.Context) error {
+		lang := b.locales.Lang(c.Sender())
 		text := c.Text()
 		if len(text) == 0 {
 			return nil
@@ -182,6 +190,13 @@ func (b *botHandlerImpl) RegisterHandlers(bot *telebot.Bot) {
 			return nil
 		}
 
+		// 群组中看到的消息全部收录；私聊只收录下方的长文本
+		if c.Chat().Type != telebot.ChatPrivate {
+			if err := b.messageUsecase.SaveMessage(c.Message()); err != nil {
+				log.Printf("ERROR: Failed to ingest group message: %v", err)
+			}
+		}
+
 		// 如果文本以 "https://t.me" 开头，处理为索引请求
 		if strings.HasPrefix(text, "https://t.me/") {
 			fmt.Printf("Processing link: %s\n", text)
@@ -228,15 +243,15 @@ func (b *botHandlerImpl) RegisterHandlers(bot *telebot.Bot) {
 					log.Printf("获取成员数量失败: %v, chat: %s", err, chat.Username)
 
 					// 发送带有按钮的错误消息
-					message := "获取用户数量失败，请将机器人拉入群组后重试。"
+					message := i18n.T(lang, "index.member_count_failed")
 					inlineKeys := [][]telebot.InlineButton{
 						{
 							{
-								Text: "🔄 重新获取",
+								Text: i18n.T(lang, "index.btn_retry"),
 								Data: "retry_index:" + text, // text 是原始的 https://t.me/... 链接
 							},
 							{
-								Text: "➕ 添加到群组/频道",
+								Text: i18n.T(lang, "index.btn_add_to_group"),
 								URL:  fmt.Sprintf("https://t.me/%s?startgroup=true", bot.Me.Username),
 							},
 						},
@@ -247,34 +262,28 @@ func (b *botHandlerImpl) RegisterHandlers(bot *telebot.Bot) {
 				}
 
 				// 如果成功，继续索引
-				data := map[string]interface{}{
-					"chat_id":       fmt.Sprintf("%d", chat.ID),
-					"type":          string(chat.Type),
-					"title":         chat.Title,
-					"username":      chat.Username,
-					"first_name":    chat.FirstName,
-					"last_name":     chat.LastName,
-					"description":   description,
-					"is_verified":   false,
-					"members_count": memberCount,
-					"created_at":    time.Now().Format("2006-01-02T15:04:05Z07:00"),
-					"updated_at":    time.Now().Format("2006-01-02T15:04:05Z07:00"),
-					"invite_link":   fullChat.InviteLink,
+				data := search.Chat{
+					ID:           fmt.Sprintf("%d", chat.ID),
+					Type:         string(chat.Type),
+					Title:        chat.Title,
+					Username:     chat.Username,
+					FirstName:    chat.FirstName,
+					LastName:     chat.LastName,
+					Description:  description,
+					MembersCount: int64(memberCount),
+					CreatedAt:    time.Now().Format("2006-01-02T15:04:05Z07:00"),
+					UpdatedAt:    time.Now().Format("2006-01-02T15:04:05Z07:00"),
+					InviteLink:   fullChat.InviteLink,
 				}
 				fmt.Printf("Data to save: %+v\n", data)
-				if err := index.SaveTelegramIndex(b.cfg, data); err != nil {
+				if err := index.SaveTelegramIndex(b.cfg, b.meili, data); err != nil {
 					fmt.Printf("Error saving index: %v\n", err)
 					return err
 				}
 				fmt.Println("Index saved successfully")
 
 				// 构建并发送成功消息
-				successMessage := fmt.Sprintf(
-					"<b>群组收录成功</b>\n\n"+
-						"<b>标题:</b> %s\n"+
-						"<b>用户名:</b> @%s\n"+
-						"<b>描述:</b> %s\n"+
-						"<b>成员数量:</b> %d",
+				successMessage := i18n.T(lang, "index.success",
 					html.EscapeString(chat.Title),
 					html.EscapeString(chat.Username),
 					html.EscapeString(description),
@@ -289,45 +298,43 @@ func (b *botHandlerImpl) RegisterHandlers(bot *telebot.Bot) {
 			return b.messageUsecase.SearchWithPagination(c, text, 1, "")
 		}
 
-		// 默认保存消息
-		// 首先，为 operation_details 字段创建一个详细信息映射
-		details := map[string]interface{}{
-			"message_id":    c.Message().ID,
-			"chat_id":       c.Chat().ID,
-			"chat_title":    c.Chat().Title,
-			"chat_username": c.Chat().Username,
-			"chat_type":     string(c.Chat().Type),
-			"text":          text,
-			"sender_is_bot": c.Sender().IsBot,
-			"date":          c.Message().Time().Format("2006-01-02T15:04:05Z07:00"),
+		// 默认保存私聊中的长文本（群组消息已在上方收录）
+		if c.Chat().Type != telebot.ChatPrivate {
+			return nil
 		}
+		return b.messageUsecase.SaveMessage(c.Message())
+	})
 
-		detailsJSON, err := json.Marshal(details)
-		if err != nil {
-			// 记录错误，但不阻止用户
-			fmt.Printf("ERROR: Failed to marshal operation details: %v\n", err)
+	// 收录频道帖子以及群组/频道中编辑过的消息（按 chat_id + message_id 覆盖写入）
+	ingestMessage := func(c telebot.Context) error {
+		if c.Chat() == nil || c.Chat().Type == telebot.ChatPrivate {
+			return nil
 		}
-
-		data := map[string]interface{}{
-			"user":              c.Sender().ID,
-			"bot_id":            fmt.Sprintf("%d", bot.Me.ID),
-			"operation_type":    "save_message",
-			"operation_time":    time.Now().Format("2006-01-02T15:04:05Z07:00"),
-			"operation_details": string(detailsJSON),
-			"create_time":       time.Now().Format("2006-01-02T15:04:05Z07:00"),
+		if err := b.messageUsecase.SaveMessage(c.Message()); err != nil {
+			log.Printf("ERROR: Failed to ingest message: %v", err)
 		}
-
-		return b.messageUsecase.SaveMessage(data)
+		return nil
+	}
+	bot.Handle(telebot.OnChannelPost, ingestMessage)
+	bot.Handle(telebot.OnEditedChannelPost, ingestMessage)
+	bot.Handle(telebot.OnEdited, ingestMessage)
+
+	// 处理回调查询（语言切换按钮在此处理，其余交给搜索分页）
+	bot.Handle(telebot.OnCallback, func(c telebot.Context) error {
+		if lang, ok := strings.CutPrefix(c.Callback().Data, "lang_"); ok {
+			return b.handleLangCallback(c, lang)
+		}
+		return b.messageUsecase.HandleCallback(c)
 	})
 
-	// 处理回调查询
-	bot.Handle(telebot.OnCallback, b.messageUsecase.HandleCallback)
+	// 处理内联查询（在任意会话中 @bot 关键字）
+	bot.Handle(telebot.OnQuery, b.messageUsecase.HandleInlineQuery)
 
 	// /search 命令处理
 	bot.Handle("/search", func(c telebot.Context) error {
 		query := c.Message().Payload
 		if query == "" {
-			return c.Send("Please provide a search query. Usage: /search <query>")
+			return c.Send(i18n.T(b.locales.Lang(c.Sender()), "cmd.search.usage"))
 		}
 		return b.messageUsecase.SearchWithPagination(c, query, 1, "")
 	})
@@ -340,29 +347,22 @@ func (b *botHandlerImpl) RegisterHandlers(bot *telebot.Bot) {
 			fmt.Printf("保存用户信息失败: %v\n", err)
 		}
 
+		lang := b.locales.Lang(c.Sender())
+
 		// 欢迎消息
-		welcomeText := fmt.Sprintf(
-			"你好, %s, 欢迎来到我们的TG机器人！\n\n"+
-				"<a href=\"https://t.me/addlist/pMIbwEotf14wOGU1\">👏 点击加入我们的交流大群 👏</a>\n\n"+
-				"<b>使用说明:</b>\n"+
-				"- 直接向我发送消息，即可将内容保存到您的个人收藏夹。\n"+
-				"- 发送短于10个字符的文本，将触发搜索功能。\n"+
-				"- 使用 <code>/mini</code> 命令可以随时唤出小程序。\n\n"+
-				"🔍✨👇 点击下方按钮打开小程序，或选择一个大群加入我们！",
-			c.Sender().FirstName,
-		)
+		welcomeText := i18n.T(lang, "cmd.start.welcome", html.EscapeString(c.Sender().FirstName))
 
 		// 创建内联键盘
 		inlineKeys := [][]telebot.InlineButton{
 			{},
 			{
-				telebot.InlineButton{Text: "搜索大群", URL: "https://t.me/SoSo00000000001"},
-				telebot.InlineButton{Text: "搜索每日更新频道", URL: "https://t.me/SoSo00000000002"},
+				telebot.InlineButton{Text: i18n.T(lang, "cmd.start.btn_groups"), URL: "https://t.me/SoSo00000000001"},
+				telebot.InlineButton{Text: i18n.T(lang, "cmd.start.btn_daily"), URL: "https://t.me/SoSo00000000002"},
 			},
 			{
-				telebot.InlineButton{Text: "搜索消息监听", URL: "https://t.me/SoSo00000000003"},
+				telebot.InlineButton{Text: i18n.T(lang, "cmd.start.btn_monitor"), URL: "https://t.me/SoSo00000000003"},
 				telebot.InlineButton{
-					Text: "🚀 打开小程序",
+					Text: i18n.T(lang, "btn.open_mini_app"),
 					WebApp: &telebot.WebApp{
 						URL: "https://timi000000001-ai.github.io/index-telegram-app", // TODO: URL应可配置
 					},
@@ -380,20 +380,7 @@ func (b *botHandlerImpl) RegisterHandlers(bot *telebot.Bot) {
 
 	// 帮助命令
 	bot.Handle("/help", func(c telebot.Context) error {
-		helpText := `<b>可用命令列表：</b>
-
-/help - 显示此帮助信息
-/search <关键词> - 搜索群组、频道和消息
-/clong - 克隆机器人
-/sponsor - 支持我们
-/mini - 打开小程序
-/disclaimer - 查看免责声明
-
-<b>使用说明：</b>
-1. 直接发送消息给机器人，消息会被保存到您的个人收藏夹
-2. 使用 /search 命令搜索群组、频道和消息
-3. 搜索结果支持分页和过滤功能
-4. 点击搜索结果中的链接可以直接访问`
+		helpText := i18n.T(b.locales.Lang(c.Sender()), "cmd.help")
 
 		return c.Send(helpText, &telebot.SendOptions{
 			ParseMode: telebot.ModeHTML,
@@ -402,24 +389,20 @@ func (b *botHandlerImpl) RegisterHandlers(bot *telebot.Bot) {
 
 	// 克隆命令
 	bot.Handle("/clong", func(c telebot.Context) error {
-		cloneGuide := `To clone this bot:
-1. Create a new bot via @BotFather on Telegram and get a Bot Token.
-2. Clone the repository: https://github.com/your-repo/telegram-bot
-3. Set environment variables (BOT_TOKEN, POCKETBASE_TOKEN, MEILISEARCH_KEY).
-4. Deploy using Docker: 'docker run -e BOT_TOKEN=your_token your-image'
-Visit https://your-repo.com for details.`
+		cloneGuide := i18n.T(b.locales.Lang(c.Sender()), "cmd.clone")
 		return c.Send(cloneGuide)
 	})
 
 	// 赞助命令
 	bot.Handle("/sponsor", func(c telebot.Context) error {
-		sponsorText := "如果您觉得本机器人对您有帮助，请考虑赞助我们。\n\nTRX & USDT (TRC20):\n\n✨<code>TD5JGaR7cY5ZxDnZNgmCSv66axR9DhrcYz</code>✨\n\n"
+		lang := b.locales.Lang(c.Sender())
+		sponsorText := i18n.T(lang, "cmd.sponsor")
 
 		inlineKeys := [][]telebot.InlineButton{
 			{
-				telebot.InlineButton{Text: "联系我们", URL: "https://t.me/simi001001"},
+				telebot.InlineButton{Text: i18n.T(lang, "cmd.sponsor.btn_contact"), URL: "https://t.me/simi001001"},
 				telebot.InlineButton{
-					Text: "🚀 打开小程序",
+					Text: i18n.T(lang, "btn.open_mini_app"),
 					WebApp: &telebot.WebApp{
 						URL: "https://timi000000001-ai.github.io/index-telegram-app", // TODO: URL应可配置
 					},
@@ -437,11 +420,13 @@ Visit https://your-repo.com for details.`
 
 	// 迷你模式命令
 	bot.Handle("/mini", func(c telebot.Context) error {
+		lang := b.locales.Lang(c.Sender())
+
 		// 创建一个内联键盘，其中包含一个小程序按钮
 		inlineKeys := [][]telebot.InlineButton{
 			{
 				telebot.InlineButton{
-					Text: "打开小程序",
+					Text: i18n.T(lang, "btn.open_mini_app_text"),
 					WebApp: &telebot.WebApp{
 						URL: "https://timi000000001-ai.github.io/index-telegram-app", // TODO: Make this URL configurable
 					},
@@ -450,7 +435,7 @@ Visit https://your-repo.com for details.`
 		}
 
 		// 发送带有内联键盘的消息
-		return c.Send("<a href=\"https://t.me/addlist/pMIbwEotf14wOGU1\">👏 加入搜索大群 👏 \n\n💡 温馨提示：加入群组可获取更多优质资源！</a>  \n\n🔍✨👇 点击下方按钮打开小程序 🚀\n", &telebot.SendOptions{
+		return c.Send(i18n.T(lang, "cmd.mini"), &telebot.SendOptions{
 			ParseMode:             telebot.ModeHTML,
 			DisableWebPagePreview: true,
 			ReplyMarkup: &telebot.ReplyMarkup{
@@ -461,17 +446,58 @@ Visit https://your-repo.com for details.`
 
 	// 免责声明命令
 	bot.Handle("/disclaimer", func(c telebot.Context) error {
-		disclaimerText := `⚠️ <b>法律声明</b> ⚠️
-
-<b>使用限制</b>：本项目不适用于中国大陆。Telegram 在中国大陆受到政府的访问限制，本项目的数据收集和处理活动可能违反当地法律法规。
-
-<b>免责声明</b>：本项目开发人员对因使用不当、违反当地法律或数据隐私问题而导致的任何后果概不负责。用户应自行评估法律风险，并在必要时咨询法律专业人士。
-
-<b>建议</b>：如果您位于中国大陆，请不要下载、安装或运行本项目。请寻找符合当地法规的替代方案。`
+		disclaimerText := i18n.T(b.locales.Lang(c.Sender()), "cmd.disclaimer")
 		return c.Send(disclaimerText, &telebot.SendOptions{
 			ParseMode: telebot.ModeHTML,
 		})
 	})
+
+	// 语言切换命令：/lang 显示语言按钮，/lang <code> 直接切换
+	bot.Handle("/lang", func(c telebot.Context) error {
+		if payload := strings.TrimSpace(c.Message().Payload); payload != "" {
+			return b.setLang(c, strings.ToLower(payload))
+		}
+
+		var langButtons []telebot.InlineButton
+		for _, lang := range i18n.Supported() {
+			langButtons = append(langButtons, telebot.InlineButton{Text: langNames[lang], Data: "lang_" + lang})
+		}
+		return c.Send(i18n.T(b.locales.Lang(c.Sender()), "cmd.lang.prompt"), &telebot.SendOptions{
+			ReplyMarkup: &telebot.ReplyMarkup{InlineKeyboard: [][]telebot.InlineButton{langButtons}},
+		})
+	})
+}
+
+// langNames 语言按钮上显示的名称
+var langNames = map[string]string{
+	i18n.LangZH: "🇨🇳 中文",
+	i18n.LangEN: "🇬🇧 English",
+}
+
+// handleLangCallback 处理语言按钮回调
+func (b *botHandlerImpl) handleLangCallback(c telebot.Context, lang string) error {
+	if err := b.setLang(c, lang); err != nil {
+		return err
+	}
+	return c.Respond()
+}
+
+// setLang 保存用户的语言偏好并以新语言回复确认
+func (b *botHandlerImpl) setLang(c telebot.Context, lang string) error {
+	if !i18n.IsSupported(lang) {
+		return c.Send(i18n.T(b.locales.Lang(c.Sender()), "cmd.lang.unsupported", lang))
+	}
+
+	// 语言偏好保存在 tele_user 记录上，确保记录存在
+	if err := user.SaveUser(c.Sender()); err != nil {
+		log.Printf("保存用户信息失败: %v", err)
+	}
+	if err := b.locales.SetLang(c.Sender(), lang); err != nil {
+		// 内存中已生效，持久化失败时提示用户
+		log.Printf("ERROR: Failed to persist language preference for user %d: %v", c.Sender().ID, err)
+		return c.Send(i18n.T(lang, "cmd.lang.failed"))
+	}
+	return c.Send(i18n.T(lang, "cmd.lang.changed"))
 }
 
 func (b *botHandlerImpl) RegisterReviewHandlers(bot *telebot.Bot) {
@@ -0,0 +1,120 @@
+package callbackstate
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+)
+
+// pocketBaseBackend 将回调状态持久化到 PocketBase 的 callback_states 集合
+type pocketBaseBackend struct {
+	client  *resty.Client
+	baseURL string
+	token   string
+}
+
+// NewPocketBaseBackend 创建 PocketBase 持久化后端
+// @param baseURL PocketBase 地址
+// @param token PocketBase 认证令牌
+// @return Backend 持久化后端实例
+func NewPocketBaseBackend(baseURL, token string) Backend {
+	return &pocketBaseBackend{
+		client:  resty.New().SetTimeout(5 * time.Second),
+		baseURL: baseURL,
+		token:   token,
+	}
+}
+
+func (p *pocketBaseBackend) collectionURL() string {
+	return p.baseURL + "/api/collections/callback_states/records"
+}
+
+// Put 持久化状态
+func (p *pocketBaseBackend) Put(id string, state State, expiresAt time.Time) error {
+	resp, err := p.client.R().
+		SetHeader("Authorization", "Bearer "+p.token).
+		SetBody(map[string]interface{}{
+			"state_id":   id,
+			"payload":    state,
+			"expires_at": expiresAt.UTC().Format(time.RFC3339),
+		}).
+		Post(p.collectionURL())
+	if err != nil {
+		return fmt.Errorf("failed to connect to PocketBase: %w", err)
+	}
+	if resp.IsError() {
+		return fmt.Errorf("PocketBase returned status code: %d, body: %s", resp.StatusCode(), resp.Body())
+	}
+	return nil
+}
+
+// Get 读取状态及其过期时间；已过期的记录顺便删除，其余过期记录由管理服务定时清理
+func (p *pocketBaseBackend) Get(id string) (State, time.Time, error) {
+	var result struct {
+		Items []struct {
+			RecordID  string          `json:"id"`
+			Payload   json.RawMessage `json:"payload"`
+			ExpiresAt string          `json:"expires_at"`
+		} `json:"items"`
+	}
+	resp, err := p.client.R().
+		SetHeader("Authorization", "Bearer "+p.token).
+		SetQueryParam("filter", fmt.Sprintf("(state_id='%s')", id)).
+		SetQueryParam("perPage", "1").
+		SetResult(&result).
+		Get(p.collectionURL())
+	if err != nil {
+		return State{}, time.Time{}, fmt.Errorf("failed to connect to PocketBase: %w", err)
+	}
+	if resp.IsError() {
+		return State{}, time.Time{}, fmt.Errorf("PocketBase returned status code: %d, body: %s", resp.StatusCode(), resp.Body())
+	}
+	if len(result.Items) == 0 {
+		return State{}, time.Time{}, ErrNotFound
+	}
+
+	item := result.Items[0]
+	expiresAt, err := parsePocketBaseTime(item.ExpiresAt)
+	if err != nil {
+		log.Printf("WARN: Invalid expires_at for callback state %s: %v", id, err)
+		return State{}, time.Time{}, ErrNotFound
+	}
+	if !time.Now().Before(expiresAt) {
+		if err := p.delete(item.RecordID); err != nil {
+			log.Printf("WARN: Failed to delete expired callback state %s: %v", id, err)
+		}
+		return State{}, time.Time{}, ErrNotFound
+	}
+
+	var state State
+	if err := json.Unmarshal(item.Payload, &state); err != nil {
+		return State{}, time.Time{}, fmt.Errorf("failed to unmarshal callback state: %w", err)
+	}
+	return state, expiresAt, nil
+}
+
+// delete 按 PocketBase 记录ID删除状态，记录已被删除时忽略
+func (p *pocketBaseBackend) delete(recordID string) error {
+	resp, err := p.client.R().
+		SetHeader("Authorization", "Bearer "+p.token).
+		Delete(p.collectionURL() + "/" + recordID)
+	if err != nil {
+		return fmt.Errorf("failed to connect to PocketBase: %w", err)
+	}
+	if resp.IsError() && resp.StatusCode() != http.StatusNotFound {
+		return fmt.Errorf("PocketBase returned status code: %d, body: %s", resp.StatusCode(), resp.Body())
+	}
+	return nil
+}
+
+// parsePocketBaseTime 解析 PocketBase 日期字段（"2006-01-02 15:04:05.000Z" 或 RFC3339）
+func parsePocketBaseTime(value string) (time.Time, error) {
+	if t, err := time.Parse("2006-01-02 15:04:05.000Z", value); err == nil {
+		return t, nil
+	}
+	return time.Parse(time.RFC3339, value)
+}
@@ -0,0 +1,206 @@
+/*
+ * 文件功能描述：回调状态存储，将搜索分页状态映射为短小的不透明ID
+ * 主要类/接口说明：State 结构、Store/Backend 接口及内存实现
+ * 修改历史记录：
+ * @author fcj
+ * @date 2023-11-15
+ * @version 1.0.0
+ * © Telegram Bot Services Team
+ */
+
+package callbackstate
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+// ErrNotFound 状态不存在或已过期
+var ErrNotFound = errors.New("callback state not found or expired")
+
+const (
+	// idBytes 状态ID的随机字节数，base64url 编码后为 11 个字符
+	idBytes = 8
+	// DefaultTTL 未配置有效期时使用的默认值
+	DefaultTTL = 24 * time.Hour
+)
+
+// State 搜索分页状态
+// @author fcj
+// @date 2023-11-15
+// @version 1.0.0
+type State struct {
+	Query  string `json:"query"`
+	Filter string `json:"filter"`
+	Page   int    `json:"page"`
+	Sort   string `json:"sort"`
+}
+
+// Store 定义回调状态存储接口
+// @author fcj
+// @date 2023-11-15
+// @version 1.0.0
+type Store interface {
+	// Save 保存状态并返回短ID
+	Save(state State) (string, error)
+
+	// Load 根据短ID读取状态
+	Load(id string) (State, error)
+
+	// Close 停止后台清理协程，可重复调用
+	Close()
+}
+
+// Backend 定义可插拔的持久化后端
+// @author fcj
+// @date 2023-11-15
+// @version 1.0.0
+type Backend interface {
+	// Put 持久化状态
+	Put(id string, state State, expiresAt time.Time) error
+
+	// Get 读取状态及其过期时间，不存在时返回 ErrNotFound
+	Get(id string) (State, time.Time, error)
+}
+
+type entry struct {
+	state     State
+	expiresAt time.Time
+}
+
+// storeImpl 内存缓存加可选持久化后端的 Store 实现
+type storeImpl struct {
+	mutex   sync.RWMutex
+	entries map[string]entry
+	ttl     time.Duration
+	backend Backend
+	now     func() time.Time
+	stop    chan struct{}
+	once    sync.Once
+}
+
+// NewStore 创建回调状态存储
+// @param ttl 状态有效期
+// @param backend 持久化后端，为 nil 时仅使用内存
+// @return Store 回调状态存储实例
+func NewStore(ttl time.Duration, backend Backend) Store {
+	if ttl <= 0 {
+		ttl = DefaultTTL
+	}
+	s := &storeImpl{
+		entries: make(map[string]entry),
+		ttl:     ttl,
+		backend: backend,
+		now:     time.Now,
+		stop:    make(chan struct{}),
+	}
+	go s.startJanitor()
+	return s
+}
+
+// Save 保存状态并返回短ID
+func (s *storeImpl) Save(state State) (string, error) {
+	id, err := newID()
+	if err != nil {
+		return "", fmt.Errorf("failed to generate state id: %w", err)
+	}
+	expiresAt := s.now().Add(s.ttl)
+
+	s.mutex.Lock()
+	s.entries[id] = entry{state: state, expiresAt: expiresAt}
+	s.mutex.Unlock()
+
+	if s.backend != nil {
+		if err := s.backend.Put(id, state, expiresAt); err != nil {
+			// 持久化失败不影响当前进程内的分页
+			log.Printf("WARN: Failed to persist callback state %s: %v", id, err)
+		}
+	}
+	return id, nil
+}
+
+// Load 根据短ID读取状态，内存未命中时回退到持久化后端
+func (s *storeImpl) Load(id string) (State, error) {
+	// ID 来自用户可控的回调数据，格式不符时直接视为不存在
+	if !validID(id) {
+		return State{}, ErrNotFound
+	}
+
+	s.mutex.RLock()
+	e, ok := s.entries[id]
+	s.mutex.RUnlock()
+	if ok {
+		if s.now().Before(e.expiresAt) {
+			return e.state, nil
+		}
+		s.mutex.Lock()
+		delete(s.entries, id)
+		s.mutex.Unlock()
+		return State{}, ErrNotFound
+	}
+
+	if s.backend == nil {
+		return State{}, ErrNotFound
+	}
+	state, expiresAt, err := s.backend.Get(id)
+	if err != nil {
+		return State{}, err
+	}
+	if !s.now().Before(expiresAt) {
+		return State{}, ErrNotFound
+	}
+
+	s.mutex.Lock()
+	s.entries[id] = entry{state: state, expiresAt: expiresAt}
+	s.mutex.Unlock()
+	return state, nil
+}
+
+// Close 停止后台清理协程，已保存的状态仍可读取
+func (s *storeImpl) Close() {
+	s.once.Do(func() { close(s.stop) })
+}
+
+// startJanitor 定期清理内存中过期的状态，直到 Close 被调用
+func (s *storeImpl) startJanitor() {
+	ticker := time.NewTicker(s.ttl)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-ticker.C:
+		}
+		now := s.now()
+		s.mutex.Lock()
+		for id, e := range s.entries {
+			if !now.Before(e.expiresAt) {
+				delete(s.entries, id)
+			}
+		}
+		s.mutex.Unlock()
+	}
+}
+
+// newID 生成URL安全的随机短ID
+func newID() (string, error) {
+	b := make([]byte, idBytes)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// validID 检查ID是否为 newID 生成的格式
+func validID(id string) bool {
+	if len(id) != base64.RawURLEncoding.EncodedLen(idBytes) {
+		return false
+	}
+	_, err := base64.RawURLEncoding.DecodeString(id)
+	return err == nil
+}
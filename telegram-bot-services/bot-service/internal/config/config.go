@@ -11,6 +11,7 @@ type Config struct {
 	Storage Storage      `json:"storage"`
 	Search  SearchConfig `json:"search"`
 	Bot     BotConfig    `json:"bot"`
+	Ingest  IngestConfig `json:"ingest"`
 }
 
 type ServerConfig struct {
@@ -31,6 +32,9 @@ type SearchConfig struct {
 	MeilisearchKey       string `json:"meilisearchKey"`
 	ManagementServiceURL string `json:"managementServiceURL"`
 	IndexName            string `json:"indexName"`
+	// ChatWeight 和 MessageWeight 为"全部"搜索中群组和消息结果的排序权重，默认 1.0 和 0.9
+	ChatWeight    float64 `json:"chatWeight"`
+	MessageWeight float64 `json:"messageWeight"`
 }
 
 type BotConfig struct {
@@ -41,8 +45,25 @@ type BotConfig struct {
 	ManagementServiceToken string   `json:"managementServiceToken"`
 	ReviewChannel          string   `json:"reviewChannel"`
 	ReviewBotToken         string   `json:"reviewBotToken"`
+	ReviewLanguage         string   `json:"reviewLanguage"`
 	BotTokens              []string `json:"bot_tokens"`
 	TokenRotationDuration  int      `json:"token_rotation_duration"`
+	// CallbackStateTTL 搜索分页状态的有效期（秒）
+	CallbackStateTTL int `json:"callback_state_ttl"`
+	// CallbackStateBackend 分页状态持久化后端："memory"（默认）或 "pocketbase"
+	CallbackStateBackend string `json:"callback_state_backend"`
+}
+
+// IngestConfig 消息收录管道配置，未设置的字段使用默认值
+type IngestConfig struct {
+	// BatchSize 每批写入的消息数（最大 50）
+	BatchSize int `json:"batchSize"`
+	// FlushInterval 未满一批时的刷新间隔（毫秒）
+	FlushInterval int `json:"flushInterval"`
+	// MaxRetries 每批写入失败后的重试次数
+	MaxRetries int `json:"maxRetries"`
+	// QueueSize 待写入队列长度，队列满时丢弃新消息
+	QueueSize int `json:"queueSize"`
 }
 
 func LoadConfig(env string) (*Config, error) {
@@ -0,0 +1,230 @@
+package i18n
+
+// catalog 文案目录：语言 -> 文案键 -> 文案
+// 新增文案时需同时补充所有语言，缺失的键会回退到默认语言。
+var catalog = map[string]map[string]string{
+	LangZH: {
+		// 命令
+		"cmd.start.welcome": "你好, %s, 欢迎来到我们的TG机器人！\n\n" +
+			"<a href=\"https://t.me/addlist/pMIbwEotf14wOGU1\">👏 点击加入我们的交流大群 👏</a>\n\n" +
+			"<b>使用说明:</b>\n" +
+			"- 直接向我发送消息，即可将内容保存到您的个人收藏夹。\n" +
+			"- 发送短于10个字符的文本，将触发搜索功能。\n" +
+			"- 使用 <code>/mini</code> 命令可以随时唤出小程序。\n" +
+			"- 使用 <code>/lang</code> 命令可以切换语言。\n\n" +
+			"🔍✨👇 点击下方按钮打开小程序，或选择一个大群加入我们！",
+		"cmd.start.btn_groups":   "搜索大群",
+		"cmd.start.btn_daily":    "搜索每日更新频道",
+		"cmd.start.btn_monitor":  "搜索消息监听",
+		"btn.open_mini_app":      "🚀 打开小程序",
+		"btn.open_mini_app_text": "打开小程序",
+		"cmd.help": `<b>可用命令列表：</b>
+
+/help - 显示此帮助信息
+/search <关键词> - 搜索群组、频道和消息
+/lang - 切换语言
+/clong - 克隆机器人
+/sponsor - 支持我们
+/mini - 打开小程序
+/disclaimer - 查看免责声明
+
+<b>使用说明：</b>
+1. 直接发送消息给机器人，消息会被保存到您的个人收藏夹
+2. 使用 /search 命令搜索群组、频道和消息
+3. 搜索结果支持分页和过滤功能
+4. 点击搜索结果中的链接可以直接访问
+5. 在任意会话中输入 @机器人 关键字 即可使用内联搜索
+6. 搜索语法：<code>"完整短语"</code> 精确匹配，<code>-广告</code> 排除，<code>type:channel</code>、<code>members:&gt;1000</code>、<code>lang:zh</code> 筛选群组；搜索消息时可用 <code>in:@频道</code>、<code>after:2025-01-01</code>、<code>before:2025-02-01</code>`,
+		"cmd.search.usage": "请提供搜索关键字。用法: /search <关键字>",
+		"cmd.clone": `克隆本机器人：
+1. 在 Telegram 中通过 @BotFather 创建新机器人并获取 Bot Token。
+2. 克隆仓库: https://github.com/your-repo/telegram-bot
+3. 设置环境变量（BOT_TOKEN、POCKETBASE_TOKEN、MEILISEARCH_KEY）。
+4. 使用 Docker 部署: 'docker run -e BOT_TOKEN=your_token your-image'
+详情请访问 https://your-repo.com`,
+		"cmd.sponsor":            "如果您觉得本机器人对您有帮助，请考虑赞助我们。\n\nTRX & USDT (TRC20):\n\n✨<code>TD5JGaR7cY5ZxDnZNgmCSv66axR9DhrcYz</code>✨\n\n",
+		"cmd.sponsor.btn_contact": "联系我们",
+		"cmd.mini":               "<a href=\"https://t.me/addlist/pMIbwEotf14wOGU1\">👏 加入搜索大群 👏 \n\n💡 温馨提示：加入群组可获取更多优质资源！</a>  \n\n🔍✨👇 点击下方按钮打开小程序 🚀\n",
+		"cmd.disclaimer": `⚠️ <b>法律声明</b> ⚠️
+
+<b>使用限制</b>：本项目不适用于中国大陆。Telegram 在中国大陆受到政府的访问限制，本项目的数据收集和处理活动可能违反当地法律法规。
+
+<b>免责声明</b>：本项目开发人员对因使用不当、违反当地法律或数据隐私问题而导致的任何后果概不负责。用户应自行评估法律风险，并在必要时咨询法律专业人士。
+
+<b>建议</b>：如果您位于中国大陆，请不要下载、安装或运行本项目。请寻找符合当地法规的替代方案。`,
+		"cmd.lang.prompt":      "请选择语言：",
+		"cmd.lang.changed":     "✅ 语言已切换为中文。",
+		"cmd.lang.unsupported": "不支持的语言: %s",
+		"cmd.lang.failed":      "语言设置保存失败，请稍后重试。",
+
+		// 收录
+		"index.member_count_failed": "获取用户数量失败，请将机器人拉入群组后重试。",
+		"index.btn_retry":           "🔄 重新获取",
+		"index.btn_add_to_group":    "➕ 添加到群组/频道",
+		"index.success": "<b>群组收录成功</b>\n\n" +
+			"<b>标题:</b> %s\n" +
+			"<b>用户名:</b> @%s\n" +
+			"<b>描述:</b> %s\n" +
+			"<b>成员数量:</b> %d",
+
+		// 搜索
+		"search.empty_query":   "请输入搜索关键字。",
+		"search.no_results":    "<i>没有找到相关结果: </i>%s",
+		"search.header":        "<b>🔍 关键字: %s</b> (第 %d 页 / 共 %d 页)\n\n",
+		"search.failed":        "🔍 搜索失败: `%s`",
+		"search.build_failed":  "构建搜索结果失败。",
+		"search.message_label": "💬 消息",
+		"search.from":          "from",
+		"search.jump":          "(跳转)",
+		"search.btn_prev":      "⬅️ 上一页",
+		"search.btn_next":      "下一页 ➡️",
+		"search.expired":       "搜索已过期，请重新搜索",
+		"search.first_page":    "已经是第一页了",
+		"search.state_failed":  "读取搜索状态失败: %v",
+		"search.retry_failed":  "搜索失败: %v",
+		"search.invalid_query": "🔍 搜索语法有误: %s",
+
+		// 会话类型与过滤
+		"chat.private":   "私聊",
+		"chat.group":     "群组",
+		"chat.channel":   "频道",
+		"chat.unknown":   "未知",
+		"filter.all":     "全部",
+		"filter.group":   "群组",
+		"filter.channel": "频道",
+		"filter.bot":     "机器人",
+		"filter.message": "消息",
+
+		// 排序
+		"sort.relevance": "相关度",
+		"sort.newest":    "最新",
+		"sort.members":   "成员数",
+		"sort.growth":    "增长",
+
+		// 回调
+		"callback.internal_error": "内部错误",
+		"callback.failed":         "操作失败: %s",
+
+		// 审核
+		"review.notification":   "<b>【疑似失效】</b>\n请审核: <a href=\"https://t.me/%s\">@%s</a>\n文档ID: <code>%s</code>",
+		"review.btn_delete":     "❌ 确认失效 (删除)",
+		"review.btn_keep":       "✅ 保留 没有失效",
+		"review.invalid_id":     "❌ 删除失败: 无效的文档ID",
+		"review.invalid_format": "❌ 删除失败: 无效的文档ID格式",
+		"review.delete_failed":  "❌ 删除失败",
+		"review.deleted":        "✅ 文档 %s 已被删除。",
+		"review.kept":           "👍 文档 %s 已被保留。",
+	},
+	LangEN: {
+		// Commands
+		"cmd.start.welcome": "Hi %s, welcome to our TG bot!\n\n" +
+			"<a href=\"https://t.me/addlist/pMIbwEotf14wOGU1\">👏 Tap to join our community groups 👏</a>\n\n" +
+			"<b>How to use:</b>\n" +
+			"- Send me a message to save it to your personal collection.\n" +
+			"- Send text shorter than 10 characters to search.\n" +
+			"- Use <code>/mini</code> to open the mini app at any time.\n" +
+			"- Use <code>/lang</code> to switch language.\n\n" +
+			"🔍✨👇 Tap a button below to open the mini app or join one of our groups!",
+		"cmd.start.btn_groups":   "Search groups",
+		"cmd.start.btn_daily":    "Daily updates channel",
+		"cmd.start.btn_monitor":  "Message monitor",
+		"btn.open_mini_app":      "🚀 Open mini app",
+		"btn.open_mini_app_text": "Open mini app",
+		"cmd.help": `<b>Available commands:</b>
+
+/help - Show this help message
+/search <keyword> - Search groups, channels and messages
+/lang - Switch language
+/clong - Clone this bot
+/sponsor - Support us
+/mini - Open the mini app
+/disclaimer - Show the disclaimer
+
+<b>How to use:</b>
+1. Send a message to the bot to save it to your personal collection
+2. Use /search to search groups, channels and messages
+3. Search results support pagination and filters
+4. Tap a link in the results to open it directly
+5. Type @bot keyword in any chat to use inline search
+6. Search syntax: <code>"exact phrase"</code>, <code>-spam</code> to exclude, <code>type:channel</code>, <code>members:&gt;1000</code>, <code>lang:zh</code> for chats; <code>in:@channel</code>, <code>after:2025-01-01</code>, <code>before:2025-02-01</code> for messages`,
+		"cmd.search.usage": "Please provide a search query. Usage: /search <query>",
+		"cmd.clone": `To clone this bot:
+1. Create a new bot via @BotFather on Telegram and get a Bot Token.
+2. Clone the repository: https://github.com/your-repo/telegram-bot
+3. Set environment variables (BOT_TOKEN, POCKETBASE_TOKEN, MEILISEARCH_KEY).
+4. Deploy using Docker: 'docker run -e BOT_TOKEN=your_token your-image'
+Visit https://your-repo.com for details.`,
+		"cmd.sponsor":            "If this bot helps you, please consider sponsoring us.\n\nTRX & USDT (TRC20):\n\n✨<code>TD5JGaR7cY5ZxDnZNgmCSv66axR9DhrcYz</code>✨\n\n",
+		"cmd.sponsor.btn_contact": "Contact us",
+		"cmd.mini":               "<a href=\"https://t.me/addlist/pMIbwEotf14wOGU1\">👏 Join our search groups 👏 \n\n💡 Tip: join the groups for more quality resources!</a>  \n\n🔍✨👇 Tap the button below to open the mini app 🚀\n",
+		"cmd.disclaimer": `⚠️ <b>Legal notice</b> ⚠️
+
+<b>Restrictions</b>: This project is not intended for use in mainland China. Access to Telegram is restricted by the government there, and the data collection and processing in this project may violate local laws and regulations.
+
+<b>Disclaimer</b>: The developers are not responsible for any consequences arising from misuse, violation of local laws or data privacy issues. Users should assess the legal risks themselves and consult a legal professional where necessary.
+
+<b>Recommendation</b>: If you are located in mainland China, do not download, install or run this project. Please look for an alternative that complies with local regulations.`,
+		"cmd.lang.prompt":      "Please choose a language:",
+		"cmd.lang.changed":     "✅ Language switched to English.",
+		"cmd.lang.unsupported": "Unsupported language: %s",
+		"cmd.lang.failed":      "Failed to save your language setting, please try again later.",
+
+		// Indexing
+		"index.member_count_failed": "Failed to get the member count. Please add the bot to the group and try again.",
+		"index.btn_retry":           "🔄 Retry",
+		"index.btn_add_to_group":    "➕ Add to group/channel",
+		"index.success": "<b>Chat indexed successfully</b>\n\n" +
+			"<b>Title:</b> %s\n" +
+			"<b>Username:</b> @%s\n" +
+			"<b>Description:</b> %s\n" +
+			"<b>Members:</b> %d",
+
+		// Search
+		"search.empty_query":   "Please enter a search keyword.",
+		"search.no_results":    "<i>No results found for: </i>%s",
+		"search.header":        "<b>🔍 Keyword: %s</b> (page %d of %d)\n\n",
+		"search.failed":        "🔍 Search failed: `%s`",
+		"search.build_failed":  "Failed to build the search results.",
+		"search.message_label": "💬 Message",
+		"search.from":          "from",
+		"search.jump":          "(open)",
+		"search.btn_prev":      "⬅️ Prev",
+		"search.btn_next":      "Next ➡️",
+		"search.expired":       "This search has expired, please search again",
+		"search.first_page":    "Already on the first page",
+		"search.state_failed":  "Failed to load search state: %v",
+		"search.retry_failed":  "Search failed: %v",
+		"search.invalid_query": "🔍 Invalid search syntax: %s",
+
+		// Chat types and filters
+		"chat.private":   "Private chat",
+		"chat.group":     "Group",
+		"chat.channel":   "Channel",
+		"chat.unknown":   "Unknown",
+		"filter.all":     "All",
+		"filter.group":   "Groups",
+		"filter.channel": "Channels",
+		"filter.bot":     "Bots",
+		"filter.message": "Messages",
+
+		// Sort orders
+		"sort.relevance": "Relevance",
+		"sort.newest":    "Newest",
+		"sort.members":   "Members",
+		"sort.growth":    "Growth",
+
+		// Callbacks
+		"callback.internal_error": "Internal error",
+		"callback.failed":         "Action failed: %s",
+
+		// Review
+		"review.notification":   "<b>[Possibly invalid]</b>\nPlease review: <a href=\"https://t.me/%s\">@%s</a>\nDocument ID: <code>%s</code>",
+		"review.btn_delete":     "❌ Invalid (delete)",
+		"review.btn_keep":       "✅ Keep, still valid",
+		"review.invalid_id":     "❌ Delete failed: invalid document ID",
+		"review.invalid_format": "❌ Delete failed: invalid document ID format",
+		"review.delete_failed":  "❌ Delete failed",
+		"review.deleted":        "✅ Document %s has been deleted.",
+		"review.kept":           "👍 Document %s has been kept.",
+	},
+}
@@ -0,0 +1,148 @@
+/*
+ * 文件功能描述：多语言支持，根据用户语言选择机器人回复文案
+ * 主要类/接口说明：T 翻译函数、Normalize 语言归一化、Resolver 用户语言解析
+ * 修改历史记录：
+ * @author fcj
+ * @date 2023-11-15
+ * @version 1.0.0
+ * © Telegram Bot Services Team
+ */
+
+package i18n
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+
+	"gopkg.in/telebot.v4"
+)
+
+const (
+	// LangZH 中文
+	LangZH = "zh"
+	// LangEN 英文
+	LangEN = "en"
+	// DefaultLang 无法识别用户语言时使用的默认语言
+	DefaultLang = LangZH
+)
+
+// Supported 返回支持的语言列表
+func Supported() []string {
+	return []string{LangZH, LangEN}
+}
+
+// Normalize 将 Telegram 语言代码（如 "zh-hans"、"en-US"）归一化为目录中的语言
+// @param code 语言代码
+// @return string 支持的语言，空代码返回默认语言，其他未支持语言回退到英文
+func Normalize(code string) string {
+	code = strings.ToLower(strings.TrimSpace(code))
+	if code == "" {
+		return DefaultLang
+	}
+	base, _, _ := strings.Cut(strings.ReplaceAll(code, "_", "-"), "-")
+	if _, ok := catalog[base]; ok {
+		return base
+	}
+	return LangEN
+}
+
+// IsSupported 判断语言是否在目录中
+func IsSupported(lang string) bool {
+	_, ok := catalog[lang]
+	return ok
+}
+
+// T 返回指定语言的文案，args 非空时按 fmt.Sprintf 格式化
+// @param lang 语言
+// @param key 文案键
+// @param args 格式化参数
+// @return string 文案，缺失时依次回退到默认语言和键本身
+func T(lang, key string, args ...interface{}) string {
+	text, ok := catalog[lang][key]
+	if !ok {
+		text, ok = catalog[DefaultLang][key]
+		if !ok {
+			log.Printf("WARN: missing i18n key %q", key)
+			text = key
+		}
+	}
+	if len(args) == 0 {
+		return text
+	}
+	return fmt.Sprintf(text, args...)
+}
+
+// Preferences 定义用户语言偏好的持久化接口
+type Preferences interface {
+	// GetPreferredLanguage 读取用户通过 /lang 设置的语言，未设置时返回空字符串
+	GetPreferredLanguage(userID int64) (string, error)
+
+	// SetPreferredLanguage 保存用户的语言偏好
+	SetPreferredLanguage(userID int64, lang string) error
+}
+
+// Resolver 解析用户语言：优先使用 /lang 设置的语言，其次使用 Telegram 客户端语言
+type Resolver struct {
+	prefs     Preferences
+	overrides sync.Map // userID -> string，空字符串表示未设置
+}
+
+// NewResolver 创建用户语言解析器
+// @param prefs 语言偏好持久化，为 nil 时仅保存在内存中
+// @return *Resolver 解析器实例
+func NewResolver(prefs Preferences) *Resolver {
+	return &Resolver{prefs: prefs}
+}
+
+// Lang 返回用户应使用的语言
+// @param u Telegram 用户
+// @return string 支持的语言
+func (r *Resolver) Lang(u *telebot.User) string {
+	if u == nil {
+		return DefaultLang
+	}
+	if r != nil {
+		if override := r.override(u.ID); override != "" {
+			return override
+		}
+	}
+	return Normalize(u.LanguageCode)
+}
+
+// SetLang 设置用户的语言偏好
+// @param u Telegram 用户
+// @param lang 支持的语言
+// @return error 错误信息
+func (r *Resolver) SetLang(u *telebot.User, lang string) error {
+	if !IsSupported(lang) {
+		return fmt.Errorf("unsupported language: %s", lang)
+	}
+	r.overrides.Store(u.ID, lang)
+	if r.prefs == nil {
+		return nil
+	}
+	return r.prefs.SetPreferredLanguage(u.ID, lang)
+}
+
+// override 读取缓存的语言偏好，首次访问时从持久化加载
+func (r *Resolver) override(userID int64) string {
+	if v, ok := r.overrides.Load(userID); ok {
+		return v.(string)
+	}
+	lang := ""
+	if r.prefs != nil {
+		stored, err := r.prefs.GetPreferredLanguage(userID)
+		if err != nil {
+			// 不缓存失败结果，下次再试
+			log.Printf("WARN: Failed to load language preference for user %d: %v", userID, err)
+			return ""
+		}
+		if IsSupported(stored) {
+			lang = stored
+		}
+	}
+	r.overrides.Store(userID, lang)
+	return lang
+}
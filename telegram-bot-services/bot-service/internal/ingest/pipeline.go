@@ -0,0 +1,302 @@
+/*
+ * 文件功能描述：消息收录管道，将机器人看到的消息批量写入PocketBase并索引到Meilisearch
+ * 主要类/接口说明：Message 收录的消息、Pipeline 批量写入管道
+ * 修改历史记录：
+ * @author fcj
+ * @date 2023-11-15
+ * @version 1.0.0
+ * © Telegram Bot Services Team
+ */
+
+package ingest
+
+import (
+	"bot-service/internal/repository"
+	"crypto/sha1"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"strings"
+	"sync"
+	"time"
+
+	"telegram-bot-services/pkg/search"
+
+	"gopkg.in/telebot.v4"
+)
+
+const (
+	// DefaultIndexName 消息索引的默认名称
+	DefaultIndexName = search.DefaultMessagesIndex
+	// DefaultBatchSize 默认批大小，不超过 PocketBase 批量接口的默认上限（50）
+	DefaultBatchSize = 50
+	// DefaultFlushInterval 默认的定时刷新间隔
+	DefaultFlushInterval = 2 * time.Second
+	// DefaultMaxRetries 每批写入失败后的默认重试次数
+	DefaultMaxRetries = 3
+	// DefaultQueueSize 默认的待写入队列长度
+	DefaultQueueSize = 1000
+
+	// recordIDLength PocketBase 记录ID长度（15位 [a-z0-9]）
+	recordIDLength = 15
+	// retryBackoff 首次重试前的等待时间，之后每次翻倍
+	retryBackoff = 500 * time.Millisecond
+)
+
+// ErrQueueFull 队列已满，消息被丢弃
+var ErrQueueFull = errors.New("ingest queue is full")
+
+// ErrClosed 管道已关闭
+var ErrClosed = errors.New("ingest pipeline is closed")
+
+// Message 收录的消息
+type Message struct {
+	ChatID       int64
+	MessageID    int
+	ChatTitle    string
+	ChatUsername string
+	ChatType     string
+	SenderID     int64
+	SenderName   string
+	SenderIsBot  bool
+	Text         string
+	Date         time.Time
+}
+
+// FromTelebot 将 Telegram 消息转换为收录消息，图片等媒体消息使用其说明文字
+// @param msg Telegram 消息
+// @return Message 收录消息
+func FromTelebot(msg *telebot.Message) Message {
+	m := Message{
+		MessageID: msg.ID,
+		Text:      msg.Text,
+		Date:      msg.Time(),
+	}
+	if m.Text == "" {
+		m.Text = msg.Caption
+	}
+	if msg.Chat != nil {
+		m.ChatID = msg.Chat.ID
+		m.ChatTitle = msg.Chat.Title
+		m.ChatUsername = msg.Chat.Username
+		m.ChatType = string(msg.Chat.Type)
+	}
+	switch {
+	case msg.Sender != nil:
+		m.SenderID = msg.Sender.ID
+		m.SenderName = strings.TrimSpace(msg.Sender.FirstName + " " + msg.Sender.LastName)
+		m.SenderIsBot = msg.Sender.IsBot
+	case msg.SenderChat != nil:
+		// 频道消息和匿名管理员以会话身份发送
+		m.SenderID = msg.SenderChat.ID
+		m.SenderName = msg.SenderChat.Title
+	}
+	return m
+}
+
+// DocumentID 返回 Meilisearch 文档ID：<chat_id>_<message_id>
+func (m Message) DocumentID() string {
+	return search.MessageDocumentID(m.ChatID, m.MessageID)
+}
+
+// recordID 返回由会话ID和消息ID确定的 PocketBase 记录ID，保证重复写入同一条消息时幂等
+func (m Message) recordID() string {
+	sum := sha1.Sum([]byte(m.DocumentID()))
+	id := new(big.Int).SetBytes(sum[:]).Text(36)
+	return id[len(id)-recordIDLength:]
+}
+
+// record 构建 PocketBase messages 集合的记录
+func (m Message) record(source string) map[string]interface{} {
+	return map[string]interface{}{
+		"id":            m.recordID(),
+		"chat_id":       fmt.Sprintf("%d", m.ChatID),
+		"message_id":    m.MessageID,
+		"chat_title":    m.ChatTitle,
+		"chat_username": m.ChatUsername,
+		"chat_type":     m.ChatType,
+		"sender_id":     fmt.Sprintf("%d", m.SenderID),
+		"sender_name":   m.SenderName,
+		"sender_is_bot": m.SenderIsBot,
+		"text":          m.Text,
+		"date":          m.Date.UTC().Format(time.RFC3339),
+		"source":        source,
+	}
+}
+
+// document 构建 Meilisearch 消息索引的文档，与采集服务写入的文档结构相同
+func (m Message) document() search.Message {
+	return search.Message{
+		ID:         m.DocumentID(),
+		ChatID:     m.ChatID,
+		MessageID:  m.MessageID,
+		Title:      m.ChatTitle,
+		Username:   m.ChatUsername,
+		Type:       m.ChatType,
+		SenderID:   m.SenderID,
+		SenderName: m.SenderName,
+		Date:       m.Date.Unix(),
+		Text:       m.Text,
+	}
+}
+
+// Options 管道配置，零值字段使用默认值
+type Options struct {
+	IndexName     string
+	BatchSize     int
+	FlushInterval time.Duration
+	MaxRetries    int
+	QueueSize     int
+}
+
+// withDefaults 为未设置的字段填充默认值
+func (o Options) withDefaults() Options {
+	if o.IndexName == "" {
+		o.IndexName = DefaultIndexName
+	}
+	if o.BatchSize <= 0 || o.BatchSize > DefaultBatchSize {
+		o.BatchSize = DefaultBatchSize
+	}
+	if o.FlushInterval <= 0 {
+		o.FlushInterval = DefaultFlushInterval
+	}
+	if o.MaxRetries <= 0 {
+		o.MaxRetries = DefaultMaxRetries
+	}
+	if o.QueueSize <= 0 {
+		o.QueueSize = DefaultQueueSize
+	}
+	return o
+}
+
+// Pipeline 消息收录管道：消息先进入队列，由后台协程按批大小或时间间隔批量写入
+type Pipeline struct {
+	repo    repository.StorageRepository
+	opts    Options
+	queue   chan Message
+	closeMu sync.RWMutex
+	closed  bool
+	done    chan struct{}
+}
+
+// NewPipeline 创建并启动消息收录管道
+// @param repo 存储服务
+// @param opts 管道配置
+// @return *Pipeline 管道实例
+func NewPipeline(repo repository.StorageRepository, opts Options) *Pipeline {
+	opts = opts.withDefaults()
+	p := &Pipeline{
+		repo:  repo,
+		opts:  opts,
+		queue: make(chan Message, opts.QueueSize),
+		done:  make(chan struct{}),
+	}
+	go p.run()
+	return p
+}
+
+// IndexName 返回消息索引名称
+func (p *Pipeline) IndexName() string {
+	return p.opts.IndexName
+}
+
+// Enqueue 将消息放入写入队列，不会阻塞调用方
+// @param msg 收录消息
+// @return error 队列已满或管道已关闭时返回错误
+func (p *Pipeline) Enqueue(msg Message) error {
+	p.closeMu.RLock()
+	defer p.closeMu.RUnlock()
+	if p.closed {
+		return ErrClosed
+	}
+	select {
+	case p.queue <- msg:
+		return nil
+	default:
+		return ErrQueueFull
+	}
+}
+
+// Close 停止接收新消息，并在写完队列中剩余的消息后返回
+func (p *Pipeline) Close() {
+	p.closeMu.Lock()
+	if !p.closed {
+		p.closed = true
+		close(p.queue)
+	}
+	p.closeMu.Unlock()
+	<-p.done
+}
+
+// run 后台写入循环
+func (p *Pipeline) run() {
+	defer close(p.done)
+	ticker := time.NewTicker(p.opts.FlushInterval)
+	defer ticker.Stop()
+
+	batch := make([]Message, 0, p.opts.BatchSize)
+	for {
+		select {
+		case msg, ok := <-p.queue:
+			if !ok {
+				p.flush(batch)
+				return
+			}
+			batch = append(batch, msg)
+			if len(batch) >= p.opts.BatchSize {
+				p.flush(batch)
+				batch = make([]Message, 0, p.opts.BatchSize)
+			}
+		case <-ticker.C:
+			if len(batch) > 0 {
+				p.flush(batch)
+				batch = make([]Message, 0, p.opts.BatchSize)
+			}
+		}
+	}
+}
+
+// flush 将一批消息写入 PocketBase 并索引到 Meilisearch，两者分别重试
+func (p *Pipeline) flush(batch []Message) {
+	if len(batch) == 0 {
+		return
+	}
+	records := make([]map[string]interface{}, 0, len(batch))
+	docs := make([]search.Message, 0, len(batch))
+	for _, msg := range batch {
+		records = append(records, msg.record("bot"))
+		docs = append(docs, msg.document())
+	}
+
+	if err := p.retry(func() error { return p.repo.SaveMessages(records) }); err != nil {
+		log.Printf("ERROR: Failed to save %d messages to PocketBase: %v", len(records), err)
+	}
+	if err := p.retry(func() error { return p.repo.IndexMessages(p.opts.IndexName, docs) }); err != nil {
+		log.Printf("ERROR: Failed to index %d messages to Meilisearch: %v", len(docs), err)
+	}
+}
+
+// retry 执行操作，失败时按指数退避重试
+func (p *Pipeline) retry(op func() error) error {
+	backoff := retryBackoff
+	var err error
+	for attempt := 0; attempt <= p.opts.MaxRetries; attempt++ {
+		if attempt > 0 {
+			log.Printf("WARN: Ingest write failed, retrying in %s (attempt %d/%d): %v", backoff, attempt, p.opts.MaxRetries, err)
+			time.Sleep(backoff)
+			backoff *= 2
+		}
+		if err = op(); err == nil {
+			return nil
+		}
+	}
+	return err
+}
+
+/*
+ * 关键算法说明：
+ * 1. 批量写入：消息进入有界队列，后台协程在达到批大小或定时器触发时一次性写入
+ * 2. 幂等写入：PocketBase 记录ID和 Meilisearch 文档ID均由 chat_id 与 message_id 确定，重试和编辑消息不会产生重复数据
+ * 3. 失败重试：每批写入失败后按指数退避重试，重试耗尽后记录日志并丢弃该批
+ */
@@ -1,7 +1,12 @@
 package repository
 
+import "telegram-bot-services/pkg/search"
+
 // SearchRepository defines the interface for searching data.
 type SearchRepository interface {
-	Search(query string, page int, limit int, filter string) ([]byte, error)
+	// Search runs a search over the scope named by filter: all, group,
+	// channel, bot or message. Malformed queries and unsupported options
+	// return an error matching query.ErrInvalidQuery.
+	Search(query string, page int, limit int, filter string, opts search.Options) (*search.Result, error)
 	DeleteDocument(docID string) error
 }
@@ -1,9 +1,12 @@
 package repository
 
 import (
+	"context"
 	"fmt"
 	"log"
 
+	"telegram-bot-services/pkg/search"
+
 	"github.com/go-resty/resty/v2"
 )
 
@@ -15,11 +18,17 @@ type StorageRepository interface {
 	// SaveToPocketBase 保存消息到PocketBase
 	SaveToPocketBase(data map[string]interface{}) error
 
-	// IndexToMeilisearch 将消息索引到Meilisearch
-	IndexToMeilisearch(data map[string]interface{}) error
+	// SaveMessages 通过PocketBase批量接口将消息写入messages集合（按记录ID幂等写入）
+	SaveMessages(records []map[string]interface{}) error
+
+	// IndexMessages 将一批消息文档写入Meilisearch消息索引
+	IndexMessages(indexName string, docs []search.Message) error
+
+	// ConfigureMessagesIndex 创建消息索引并设置可搜索、可过滤和可排序属性
+	ConfigureMessagesIndex(indexName string) error
 
-	// SaveAndIndex 保存并索引消息
-	SaveAndIndex(data map[string]interface{}) error
+	// ConfigureChatsIndex 创建群组索引并设置可搜索、可过滤和可排序属性
+	ConfigureChatsIndex() error
 }
 
 // StorageConfig 存储服务配置
@@ -27,10 +36,8 @@ type StorageRepository interface {
 // @date 2023-11-15
 // @version 1.0.0
 type StorageConfig struct {
-	PocketBaseURL    string
-	PocketBaseToken  string
-	MeilisearchURL   string
-	MeilisearchToken string
+	PocketBaseURL   string
+	PocketBaseToken string
 }
 
 // storageRepositoryImpl 实现StorageRepository接口
@@ -40,6 +47,7 @@ type StorageConfig struct {
 type storageRepositoryImpl struct {
 	config StorageConfig
 	client *resty.Client
+	meili  *search.Client
 }
 
 // NewStorageRepository 创建新的存储服务实例
@@ -47,11 +55,13 @@ type storageRepositoryImpl struct {
 // @date 2023-11-15
 // @version 1.0.0
 // @param config 存储服务配置
+// @param meili Meilisearch客户端
 // @return StorageRepository 存储服务实例
-func NewStorageRepository(config StorageConfig) StorageRepository {
+func NewStorageRepository(config StorageConfig, meili *search.Client) StorageRepository {
 	return &storageRepositoryImpl{
 		config: config,
 		client: resty.New(),
+		meili:  meili,
 	}
 }
 
@@ -79,60 +89,72 @@ func (s *storageRepositoryImpl) SaveToPocketBase(data map[string]interface{}) er
 	return nil
 }
 
-// IndexToMeilisearch 将消息索引到Meilisearch
-// @author fcj
-// @date 2023-11-15
-// @version 1.0.0
-// @param data 需要索引的消息数据
+// SaveMessages 通过PocketBase批量接口将消息写入messages集合
+// @param records 消息记录，每条记录须包含确定性的 id 字段
 // @return error 错误信息
-func (s *storageRepositoryImpl) IndexToMeilisearch(data map[string]interface{}) error {
-	log.Printf("INFO: Indexing message to Meilisearch...")
+func (s *storageRepositoryImpl) SaveMessages(records []map[string]interface{}) error {
+	if len(records) == 0 {
+		return nil
+	}
+	requests := make([]map[string]interface{}, 0, len(records))
+	for _, record := range records {
+		// PUT 为 upsert，重试同一批消息不会产生重复记录
+		requests = append(requests, map[string]interface{}{
+			"method": "PUT",
+			"url":    "/api/collections/messages/records",
+			"body":   record,
+		})
+	}
 	resp, err := s.client.R().
-		SetHeader("Authorization", "Bearer "+s.config.MeilisearchToken).
-		SetBody([]map[string]interface{}{data}).
-		Post(s.config.MeilisearchURL + "/indexes/operation_logs/documents")
+		SetAuthToken(s.config.PocketBaseToken).
+		SetBody(map[string]interface{}{"requests": requests}).
+		Post(s.config.PocketBaseURL + "/api/batch")
 	if err != nil {
-		log.Printf("ERROR: Failed to connect to Meilisearch: %v", err)
-		return fmt.Errorf("failed to connect to Meilisearch: %w", err)
+		return fmt.Errorf("failed to connect to PocketBase: %w", err)
 	}
-	if resp.StatusCode() != 202 {
-		log.Printf("ERROR: Meilisearch returned non-202 status: %d, body: %s", resp.StatusCode(), resp.Body())
-		return fmt.Errorf("Meilisearch returned status code: %d, body: %s", resp.StatusCode(), resp.Body())
+	if resp.StatusCode() != 200 {
+		return fmt.Errorf("PocketBase batch returned status code: %d, body: %s", resp.StatusCode(), resp.Body())
 	}
-	log.Printf("INFO: Successfully indexed message to Meilisearch")
+	log.Printf("INFO: Saved %d messages to PocketBase", len(records))
 	return nil
 }
 
-// SaveAndIndex 保存并索引消息
-// @author fcj
-// @date 2023-11-15
-// @version 1.0.0
-// @param data 需要保存和索引的消息数据
+// IndexMessages 将一批消息文档写入Meilisearch消息索引
+// @param indexName 索引名称
+// @param docs 消息文档
 // @return error 错误信息
-func (s *storageRepositoryImpl) SaveAndIndex(data map[string]interface{}) error {
-	// 保存到PocketBase
-	if err := s.SaveToPocketBase(data); err != nil {
-		return fmt.Errorf("failed to save to PocketBase: %w", err)
+func (s *storageRepositoryImpl) IndexMessages(indexName string, docs []search.Message) error {
+	if len(docs) == 0 {
+		return nil
 	}
-
-	// 索引到Meilisearch
-	if err := s.IndexToMeilisearch(data); err != nil {
-		log.Printf("WARN: Failed to index to Meilisearch, but continuing: %v", err)
-		// 不返回错误，因为索引失败不应阻止整个流程
+	if err := s.meili.AddDocuments(context.Background(), indexName, docs); err != nil {
+		return err
 	}
-
+	log.Printf("INFO: Indexed %d messages in Meilisearch", len(docs))
 	return nil
 }
 
+// ConfigureMessagesIndex 创建消息索引并设置属性
+// @param indexName 索引名称
+// @return error 错误信息
+func (s *storageRepositoryImpl) ConfigureMessagesIndex(indexName string) error {
+	return s.meili.EnsureIndex(context.Background(), indexName, search.MessageSettings)
+}
+
+// ConfigureChatsIndex 创建群组索引并设置属性
+// @return error 错误信息
+func (s *storageRepositoryImpl) ConfigureChatsIndex() error {
+	return s.meili.EnsureIndex(context.Background(), search.DefaultChatsIndex, search.ChatSettings)
+}
+
 /*
  * 关键算法说明：
  * 1. 数据存储：使用REST API将数据保存到PocketBase
- * 2. 数据索引：使用REST API将数据索引到Meilisearch
- * 
+ * 2. 数据索引：通过共享的 pkg/search 客户端写入Meilisearch
+ * 3. 消息批量写入：SaveMessages 使用 PocketBase /api/batch 的 PUT（upsert），IndexMessages 按批提交文档并等待索引任务完成
+ *
  * 待优化事项：
- * 1. 批量操作：支持批量保存和索引
- * 2. 错误重试：添加重试机制处理临时网络故障
- * 3. 连接池：优化HTTP客户端连接池
+ * 1. 连接池：优化HTTP客户端连接池
  * 
  * 兼容性说明：
  * 1. 依赖PocketBase和Meilisearch API
@@ -0,0 +1,131 @@
+/*
+ * 文件功能描述：搜索日志，将机器人处理的搜索异步写入 PocketBase 的 search_logs 集合，用于统计热门搜索
+ * 主要类/接口说明：Entry 搜索记录、Logger 接口及 PocketBase 实现
+ * 修改历史记录：
+ * @author fcj
+ * @date 2023-11-15
+ * @version 1.0.0
+ * © Telegram Bot Services Team
+ */
+
+package searchlog
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/go-resty/resty/v2"
+)
+
+const (
+	// SourceBot 用户发送的搜索（消息或 /search 命令）
+	SourceBot = "bot"
+	// SourceCallback 翻页或切换筛选重新执行的搜索，不计入热门搜索
+	SourceCallback = "callback"
+
+	// queueSize 待写入队列长度，队列满时丢弃新记录
+	queueSize = 256
+	// maxQueryLength 记录的查询最大字符数，与集合字段长度一致
+	maxQueryLength = 256
+)
+
+// Entry 一次搜索
+// @author fcj
+// @date 2023-11-15
+// @version 1.0.0
+type Entry struct {
+	Query  string
+	Filter string
+	Hits   int64
+	UserID string
+	Source string
+}
+
+// Logger 定义搜索日志接口
+// @author fcj
+// @date 2023-11-15
+// @version 1.0.0
+type Logger interface {
+	// Log 记录一次搜索，不阻塞调用方
+	Log(entry Entry)
+}
+
+// pocketBaseLogger 通过后台协程逐条写入 search_logs 集合
+type pocketBaseLogger struct {
+	client  *resty.Client
+	baseURL string
+	token   string
+	queue   chan Entry
+}
+
+// NewPocketBaseLogger 创建写入 PocketBase 的搜索日志
+// @param baseURL PocketBase 地址
+// @param token PocketBase 认证令牌
+// @return Logger 搜索日志实例
+func NewPocketBaseLogger(baseURL, token string) Logger {
+	l := &pocketBaseLogger{
+		client:  resty.New().SetTimeout(5 * time.Second),
+		baseURL: baseURL,
+		token:   token,
+		queue:   make(chan Entry, queueSize),
+	}
+	go l.run()
+	return l
+}
+
+// Log 将搜索放入队列；队列已满时丢弃，搜索日志不影响搜索本身
+func (l *pocketBaseLogger) Log(entry Entry) {
+	select {
+	case l.queue <- entry:
+	default:
+		log.Printf("WARN: Search log queue is full, dropping query %q", entry.Query)
+	}
+}
+
+// run 逐条写入队列中的搜索
+func (l *pocketBaseLogger) run() {
+	for entry := range l.queue {
+		if err := l.save(entry); err != nil {
+			log.Printf("ERROR: Failed to save search log: %v", err)
+		}
+	}
+}
+
+// save 写入一条搜索记录
+func (l *pocketBaseLogger) save(entry Entry) error {
+	query := strings.TrimSpace(entry.Query)
+	if utf8.RuneCountInString(query) > maxQueryLength {
+		query = string([]rune(query)[:maxQueryLength])
+	}
+	if query == "" {
+		return nil
+	}
+	resp, err := l.client.R().
+		SetHeader("Authorization", "Bearer "+l.token).
+		SetBody(map[string]interface{}{
+			"query":      query,
+			"normalized": Normalize(query),
+			"filter":     entry.Filter,
+			"hits":       entry.Hits,
+			"user_id":    entry.UserID,
+			"source":     entry.Source,
+		}).
+		Post(l.baseURL + "/api/collections/search_logs/records")
+	if err != nil {
+		return fmt.Errorf("failed to connect to PocketBase: %w", err)
+	}
+	if resp.IsError() {
+		return fmt.Errorf("PocketBase returned status code: %d, body: %s", resp.StatusCode(), resp.Body())
+	}
+	return nil
+}
+
+// Normalize 规范化查询用于统计：转为小写、去掉首尾空白并合并连续空白，与管理服务一致
+// @param query 原始查询
+// @return string 规范化后的查询
+func Normalize(query string) string {
+	return strings.Join(strings.Fields(strings.ToLower(query)), " ")
+}
@@ -0,0 +1,196 @@
+/*
+ * 文件功能描述：内联模式搜索，处理 @bot 查询并返回群组/频道/消息结果
+ * 主要类/接口说明：messageUsecaseImpl 的内联查询方法
+ * 修改历史记录：
+ * @author fcj
+ * @date 2023-11-15
+ * @version 1.0.0
+ * © Telegram Bot Services Team
+ */
+
+package usecase
+
+import (
+	"bot-service/internal/i18n"
+	"fmt"
+	"html"
+	"log"
+	"strconv"
+	"strings"
+
+	"telegram-bot-services/pkg/search"
+
+	"gopkg.in/telebot.v4"
+)
+
+const (
+	// inlineResultsPerPage 每次内联查询返回的结果数（Telegram 上限为 50）
+	inlineResultsPerPage = 20
+	// inlineCacheTime 内联结果在 Telegram 服务器上的缓存秒数
+	inlineCacheTime = 60
+	// inlineSnippetLength 消息摘要的最大字符数
+	inlineSnippetLength = 120
+)
+
+// inlineFilterPrefixes 内联查询支持的过滤前缀，与搜索按钮使用相同的过滤值
+var inlineFilterPrefixes = []string{"all", "group", "channel", "bot", "message"}
+
+// parseInlineQuery 解析内联查询文本，支持 "channel: crypto" 形式的过滤前缀
+// @param text 内联查询原始文本
+// @return string 过滤类型
+// @return string 搜索关键字
+func parseInlineQuery(text string) (string, string) {
+	text = strings.TrimSpace(text)
+	prefix, rest, found := strings.Cut(text, ":")
+	if !found {
+		return "", text
+	}
+	prefix = strings.ToLower(strings.TrimSpace(prefix))
+	for _, filter := range inlineFilterPrefixes {
+		if prefix == filter {
+			return filter, strings.TrimSpace(rest)
+		}
+	}
+	return "", text
+}
+
+// HandleInlineQuery 处理内联查询，返回分页的文章结果
+// @param c Telegram上下文
+// @return error 错误信息
+func (m *messageUsecaseImpl) HandleInlineQuery(c telebot.Context) error {
+	query := c.Query()
+	lang := m.locales.Lang(query.Sender)
+	filter, keyword := parseInlineQuery(query.Text)
+	if keyword == "" {
+		return c.Answer(&telebot.QueryResponse{CacheTime: inlineCacheTime})
+	}
+
+	page := 1
+	if query.Offset != "" {
+		if p, err := strconv.Atoi(query.Offset); err == nil && p > 0 {
+			page = p
+		}
+	}
+
+	searchResult, err := m.searchRepo.Search(keyword, page, inlineResultsPerPage, filter, search.Options{})
+	if err != nil {
+		log.Printf("ERROR: inline search failed: %v", err)
+		return c.Answer(&telebot.QueryResponse{CacheTime: inlineCacheTime})
+	}
+
+	results := make(telebot.Results, 0, len(searchResult.Hits))
+	for _, hit := range searchResult.Hits {
+		if result := buildInlineResult(lang, hit); result != nil {
+			results = append(results, result)
+		}
+	}
+
+	nextOffset := ""
+	if searchResult.Page < searchResult.TotalPages {
+		nextOffset = strconv.Itoa(page + 1)
+	}
+
+	return c.Answer(&telebot.QueryResponse{
+		Results:    results,
+		CacheTime:  inlineCacheTime,
+		NextOffset: nextOffset,
+	})
+}
+
+// buildInlineResult 将单条搜索结果转换为内联文章结果
+// @param lang 用户语言
+// @param hit 单条搜索结果
+// @return *telebot.ArticleResult 文章结果，无法展示时返回 nil
+func buildInlineResult(lang string, hit search.Hit) *telebot.ArticleResult {
+	title := hit.Title
+	if title == "" {
+		title = i18n.T(lang, "chat.unknown")
+	}
+
+	link := ""
+	if hit.Username != "" {
+		link = "https://t.me/" + hit.Username
+	}
+
+	var description, text string
+	if hit.Kind == search.KindMessage {
+		if hit.Text == "" {
+			return nil
+		}
+		if link != "" {
+			link = fmt.Sprintf("%s/%d", link, hit.MessageID)
+		}
+		snippet := hit.Text
+		if len([]rune(snippet)) > inlineSnippetLength {
+			snippet = string([]rune(snippet)[:inlineSnippetLength]) + "..."
+		}
+		quote := html.EscapeString(snippet)
+		// The crop centred on the match replaces the leading snippet when available.
+		if highlighted := hit.Highlight["text"]; highlighted != "" {
+			snippet = hit.Snippet("text")
+			quote = highlighted
+		}
+		title = "💬 " + title
+		description = snippet
+		text = fmt.Sprintf("<b>%s</b>\n<blockquote>%s</blockquote>", html.EscapeString(title), quote)
+	} else {
+		typeEmoji := chatTypeEmoji(hit.Type)
+		if typeEmoji != "" {
+			title = typeEmoji + " " + title
+		}
+		var parts []string
+		if hit.MembersCount > 0 {
+			parts = append(parts, fmt.Sprintf("👥 %d", hit.MembersCount))
+		}
+		desc := hit.Snippet("description")
+		if desc == "" {
+			desc = hit.Description
+			if len([]rune(desc)) > inlineSnippetLength {
+				desc = string([]rune(desc)[:inlineSnippetLength]) + "..."
+			}
+		}
+		if desc != "" {
+			parts = append(parts, desc)
+		}
+		description = strings.Join(parts, " · ")
+		text = fmt.Sprintf("<b>%s</b>", html.EscapeString(title))
+		if description != "" {
+			text += "\n" + html.EscapeString(description)
+		}
+	}
+
+	if link != "" {
+		text += fmt.Sprintf("\n\n<a href=\"%s\">%s</a>", link, html.EscapeString(link))
+	}
+
+	result := &telebot.ArticleResult{
+		Title:       title,
+		Text:        text,
+		URL:         link,
+		Description: description,
+	}
+	result.SetParseMode(telebot.ModeHTML)
+	if hit.ID != "" {
+		result.SetResultID(hit.ID)
+	}
+	return result
+}
+
+// chatTypeEmoji 返回会话类型对应的图标
+// @param chatType 会话类型
+// @return string 图标
+func chatTypeEmoji(chatType string) string {
+	switch chatType {
+	case "private":
+		return "👤"
+	case "supergroup":
+		return "👑"
+	case "group":
+		return "👥"
+	case "channel":
+		return "📢"
+	case "bot":
+		return "🤖"
+	}
+	return ""
+}
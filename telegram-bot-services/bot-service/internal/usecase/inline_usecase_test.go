@@ -0,0 +1,169 @@
+package usecase
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"bot-service/internal/i18n"
+
+	"telegram-bot-services/pkg/search"
+
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/telebot.v4"
+)
+
+// inlineContext is a telebot.Context that only supports the calls made by HandleInlineQuery.
+type inlineContext struct {
+	telebot.Context
+	query    *telebot.Query
+	response *telebot.QueryResponse
+}
+
+func (c *inlineContext) Query() *telebot.Query { return c.query }
+
+func (c *inlineContext) Answer(resp *telebot.QueryResponse) error {
+	c.response = resp
+	return nil
+}
+
+func TestParseInlineQuery(t *testing.T) {
+	testCases := []struct {
+		text, filter, keyword string
+	}{
+		{"crypto", "", "crypto"},
+		{"  crypto news  ", "", "crypto news"},
+		{"channel: crypto", "channel", "crypto"},
+		{"Message:空投", "message", "空投"},
+		{"group:", "group", ""},
+		{"price: 100", "", "price: 100"},
+		{"", "", ""},
+	}
+	for _, tc := range testCases {
+		filter, keyword := parseInlineQuery(tc.text)
+		assert.Equal(t, tc.filter, filter, "filter of %q", tc.text)
+		assert.Equal(t, tc.keyword, keyword, "keyword of %q", tc.text)
+	}
+}
+
+func TestHandleInlineQuery(t *testing.T) {
+	testCases := []struct {
+		name           string
+		text           string
+		offset         string
+		searchKeyword  string
+		searchFilter   string
+		searchPage     int
+		mockReturn     *search.Result
+		mockError      error
+		expectedIDs    []string
+		expectedOffset string
+	}{
+		{
+			name:          "First Page",
+			text:          "crypto",
+			searchKeyword: "crypto",
+			searchPage:    1,
+			mockReturn: &search.Result{Hits: []search.Hit{
+				{ID: "-1001", Kind: search.KindChat, Title: "Crypto", Type: "channel"},
+				{ID: "-1002_5", Kind: search.KindMessage, Title: "Group", MessageID: 5, Text: "crypto news"},
+			}, Page: 1, TotalPages: 3},
+			expectedIDs:    []string{"-1001", "-1002_5"},
+			expectedOffset: "2",
+		},
+		{
+			name:          "Prefix Filter And Offset",
+			text:          "channel: crypto",
+			offset:        "3",
+			searchKeyword: "crypto",
+			searchFilter:  "channel",
+			searchPage:    3,
+			mockReturn: &search.Result{Hits: []search.Hit{
+				{ID: "-1003", Kind: search.KindChat, Title: "Crypto 3", Type: "channel"},
+			}, Page: 3, TotalPages: 3},
+			expectedIDs: []string{"-1003"},
+		},
+		{
+			name:          "Invalid Offset Starts At First Page",
+			text:          "crypto",
+			offset:        "-2",
+			searchKeyword: "crypto",
+			searchPage:    1,
+			mockReturn:    &search.Result{Page: 1, TotalPages: 1},
+			expectedIDs:   []string{},
+		},
+		{
+			name:          "Messages Without Text Are Skipped",
+			text:          "message: crypto",
+			searchKeyword: "crypto",
+			searchFilter:  "message",
+			searchPage:    1,
+			mockReturn: &search.Result{Hits: []search.Hit{
+				{ID: "-1002_6", Kind: search.KindMessage, MessageID: 6},
+				{ID: "-1002_7", Kind: search.KindMessage, MessageID: 7, Text: "hello"},
+			}, Page: 1, TotalPages: 1},
+			expectedIDs: []string{"-1002_7"},
+		},
+		{
+			name:          "Search Error",
+			text:          "crypto",
+			searchKeyword: "crypto",
+			searchPage:    1,
+			mockError:     errors.New("meilisearch unavailable"),
+		},
+		{
+			name: "Empty Keyword",
+			text: "channel:",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockSearchUsecase := new(MockSearchUsecase)
+			m := &messageUsecaseImpl{searchRepo: mockSearchUsecase}
+			if tc.searchKeyword != "" {
+				mockSearchUsecase.On("Search", tc.searchKeyword, tc.searchPage, inlineResultsPerPage, tc.searchFilter, search.Options{}).Return(tc.mockReturn, tc.mockError).Once()
+			}
+
+			c := &inlineContext{query: &telebot.Query{Text: tc.text, Offset: tc.offset}}
+			assert.NoError(t, m.HandleInlineQuery(c))
+			if assert.NotNil(t, c.response) {
+				ids := make([]string, 0, len(c.response.Results))
+				for _, result := range c.response.Results {
+					ids = append(ids, result.ResultID())
+				}
+				if tc.expectedIDs != nil {
+					assert.Equal(t, tc.expectedIDs, ids)
+				} else {
+					assert.Empty(t, ids)
+				}
+				assert.Equal(t, tc.expectedOffset, c.response.NextOffset)
+			}
+			mockSearchUsecase.AssertExpectations(t)
+		})
+	}
+}
+
+func TestBuildInlineResult(t *testing.T) {
+	assert.Nil(t, buildInlineResult(i18n.LangZH, search.Hit{Kind: search.KindMessage, Title: "Group"}), "message without text")
+
+	message := buildInlineResult(i18n.LangZH, search.Hit{
+		ID: "-1002_5", Kind: search.KindMessage, Title: "Group", Username: "group_a", MessageID: 5,
+		Text: strings.Repeat("加", inlineSnippetLength+10),
+	})
+	if assert.NotNil(t, message) {
+		assert.Equal(t, "-1002_5", message.ResultID())
+		assert.Equal(t, "💬 Group", message.Title)
+		assert.Equal(t, "https://t.me/group_a/5", message.URL)
+		assert.Equal(t, strings.Repeat("加", inlineSnippetLength)+"...", message.Description)
+	}
+
+	chat := buildInlineResult(i18n.LangZH, search.Hit{Kind: search.KindChat, Type: "channel", MembersCount: 1500, Description: "<b>news</b>"})
+	if assert.NotNil(t, chat) {
+		assert.Empty(t, chat.ResultID(), "hits without an ID leave the result ID to telebot")
+		assert.Equal(t, "📢 未知", chat.Title)
+		assert.Empty(t, chat.URL)
+		assert.Equal(t, "👥 1500 · <b>news</b>", chat.Description)
+		assert.Equal(t, "<b>📢 未知</b>\n👥 1500 · &lt;b&gt;news&lt;/b&gt;", chat.Text)
+	}
+}
@@ -11,18 +11,25 @@
 package usecase
 
 import (
+	"bot-service/internal/callbackstate"
 	"bot-service/internal/config"
+	"bot-service/internal/i18n"
+	"bot-service/internal/ingest"
 	"bot-service/internal/repository"
-	"encoding/json"
+	"bot-service/internal/searchlog"
 	"errors"
 	"fmt"
 	"html"
 	"log"
-	"regexp"
+	"slices"
 	"strconv"
 	"strings"
 	"sync"
 	"time"
+
+	searchquery "telegram-bot-services/pkg/query"
+	"telegram-bot-services/pkg/search"
+
 	"gopkg.in/telebot.v4"
 )
 
@@ -31,8 +38,8 @@ import (
 // @date 2023-11-15
 // @version 1.0.0
 type MessageUsecase interface {
-	// SaveMessage 保存消息到存储系统
-	SaveMessage(data map[string]interface{}) error
+	// SaveMessage 将消息放入收录管道，批量保存到PocketBase并索引到消息索引
+	SaveMessage(msg *telebot.Message) error
 
 	// SearchWithPagination 搜索消息并支持分页
 	SearchWithPagination(c telebot.Context, query string, page int, filter string) error
@@ -42,17 +49,12 @@ type MessageUsecase interface {
 
 	// HandleReviewCallback 处理审核回调
 	HandleReviewCallback(c telebot.Context) error
-}
 
-// SearchResponse defines the structure for a MeiliSearch search response.
-type SearchResponse struct {
-	Hits           []map[string]interface{} `json:"hits"`
-	Query          string                   `json:"query"`
-	ProcessingTimeMs int64                  `json:"processingTimeMs"`
-	HitsPerPage    int64                    `json:"hitsPerPage"`
-	Page           int64                    `json:"page"`
-	TotalPages     int64                    `json:"totalPages"`
-	TotalHits      int64                    `json:"totalHits"`
+	// HandleInlineQuery 处理内联模式查询
+	HandleInlineQuery(c telebot.Context) error
+
+	// Close 停止收录管道，写完队列中剩余的消息后返回
+	Close()
 }
 
 type validationCacheEntry struct {
@@ -62,7 +64,7 @@ type validationCacheEntry struct {
 
 // validationJob defines a task for the validation worker.
 type validationJob struct {
-	hit map[string]interface{}
+	hit search.Hit
 }
 
 // messageUsecaseImpl是messageUsecase的实现
@@ -70,6 +72,10 @@ type messageUsecaseImpl struct {
 	cfg                   *config.Config
 	storageRepo           repository.StorageRepository
 	searchRepo            repository.SearchRepository
+	stateStore            callbackstate.Store
+	locales               *i18n.Resolver
+	searchLog             searchlog.Logger
+	ingest                *ingest.Pipeline
 	cacheMutex            sync.RWMutex
 	validationCache       map[string]validationCacheEntry
 	validationQueue       chan validationJob // Channel for validation jobs
@@ -80,12 +86,22 @@ type messageUsecaseImpl struct {
 	tokenRotationDuration time.Duration
 }
 
-// NewMessageUsecase create a new messageUsecase
-func NewMessageUsecase(cfg *config.Config, storageRepo repository.StorageRepository, searchRepo repository.SearchRepository) MessageUsecase {
+// NewMessageUsecase create a new messageUsecase. searchLog may be nil to disable search logging.
+func NewMessageUsecase(cfg *config.Config, storageRepo repository.StorageRepository, searchRepo repository.SearchRepository, stateStore callbackstate.Store, locales *i18n.Resolver, searchLog searchlog.Logger) MessageUsecase {
 	m := &messageUsecaseImpl{
 		cfg:                   cfg,
 		storageRepo:           storageRepo,
 		searchRepo:            searchRepo,
+		stateStore:            stateStore,
+		locales:               locales,
+		searchLog:             searchLog,
+		ingest: ingest.NewPipeline(storageRepo, ingest.Options{
+			IndexName:     cfg.Search.IndexName,
+			BatchSize:     cfg.Ingest.BatchSize,
+			FlushInterval: time.Duration(cfg.Ingest.FlushInterval) * time.Millisecond,
+			MaxRetries:    cfg.Ingest.MaxRetries,
+			QueueSize:     cfg.Ingest.QueueSize,
+		}),
 		validationCache:       make(map[string]validationCacheEntry),
 		validationQueue:       make(chan validationJob, 100), // Buffered channel for 100 jobs
 		tokenBlacklist:        make(map[string]time.Time),
@@ -133,8 +149,8 @@ func (m *messageUsecaseImpl) getBotForValidation() (*telebot.Bot, error) {
 // startValidationWorker processes username validation jobs from the queue.
 func (m *messageUsecaseImpl) startValidationWorker() {
 	for job := range m.validationQueue {
-		chatUsername, ok := job.hit["USERNAME"].(string)
-		if !ok || chatUsername == "" {
+		chatUsername := job.hit.Username
+		if chatUsername == "" {
 			continue
 		}
 
@@ -207,48 +223,54 @@ func (m *messageUsecaseImpl) startValidationWorker() {
 }
 
 // validateUsernamesAsync sends validation jobs to the background worker.
-func (m *messageUsecaseImpl) validateUsernamesAsync(hits []interface{}) {
+func (m *messageUsecaseImpl) validateUsernamesAsync(hits []search.Hit) {
 	for _, hit := range hits {
-		hitMap, ok := hit.(map[string]interface{})
-		if !ok {
+		// Review deletes only target telegram_index, so messages are not validated.
+		if hit.Kind != search.KindChat {
 			continue
 		}
-		chatUsername, ok := hitMap["USERNAME"].(string)
-		if ok && chatUsername != "" {
+		chatUsername := hit.Username
+		if chatUsername != "" {
 			m.cacheMutex.RLock()
 			entry, exists := m.validationCache[chatUsername]
 			m.cacheMutex.RUnlock()
 
 			// If not in cache or cache is expired, add to the validation queue.
 			if !exists || time.Since(entry.timestamp) >= 24*time.Hour {
-				m.validationQueue <- validationJob{hit: hitMap}
+				m.validationQueue <- validationJob{hit: hit}
 			}
 		}
 	}
 }
 
-// SaveMessage saves a message to MeiliSearch.
-func (m *messageUsecaseImpl) SaveMessage(data map[string]interface{}) error {
-	// Use the configured index name from config
-	indexName := m.cfg.Search.IndexName
-	if indexName == "" {
-		indexName = "messages" // Fallback to a default name
-	}
-	// This part needs to be implemented in storageRepo
-	// _, err := m.meiliSearch.Index(indexName).AddDocuments([]map[string]interface{}{data})
-	// if err != nil {
-	// 	log.Printf("ERROR: failed to index message: %v", err)
-	// 	return fmt.Errorf("failed to index message: %w", err)
-	// }
+// SaveMessage enqueues a message for batched saving to PocketBase and indexing
+// in the messages index. Writes happen asynchronously, so a full queue is logged
+// and dropped rather than slowing down the update handler.
+func (m *messageUsecaseImpl) SaveMessage(msg *telebot.Message) error {
+	if msg == nil || (msg.Text == "" && msg.Caption == "") {
+		return nil
+	}
+	if err := m.ingest.Enqueue(ingest.FromTelebot(msg)); err != nil {
+		log.Printf("ERROR: Failed to enqueue message %d from chat %d: %v", msg.ID, msg.Chat.ID, err)
+		return fmt.Errorf("failed to enqueue message: %w", err)
+	}
 	return nil
 }
 
+// Close stops the ingest pipeline and waits until the queued messages are written.
+func (m *messageUsecaseImpl) Close() {
+	m.ingest.Close()
+}
+
 // buildSearchResponse builds the search response string and buttons.
-func (m *messageUsecaseImpl) buildSearchResponse(query string, filter string, searchResult *SearchResponse) (string, [][]telebot.InlineButton, error) {
+// The buttons carry a short state ID instead of the raw query, so the callback
+// data stays within Telegram's 64-byte limit for any query length.
+func (m *messageUsecaseImpl) buildSearchResponse(lang string, state callbackstate.State, searchResult *search.Result) (string, [][]telebot.InlineButton, error) {
+	query, filter := state.Query, state.Filter
 	log.Printf("INFO: Building search response: query='%s', filter='%s', page=%d", query, filter, searchResult.Page)
 
 	if len(searchResult.Hits) == 0 {
-		return "<i>No results found for: </i>" + html.EscapeString(query), nil, nil
+		return i18n.T(lang, "search.no_results", html.EscapeString(query)), nil, nil
 	}
 
 	currentPage := searchResult.Page
@@ -258,100 +280,101 @@ func (m *messageUsecaseImpl) buildSearchResponse(query string, filter string, se
 		hitsPerPage = 10 // Fallback
 	}
 
-	response := fmt.Sprintf("<b>🔍 关键字: %s</b> (第 %d 页 / 共 %d 页)\n\n", html.EscapeString(query), currentPage, totalPages)
+	response := i18n.T(lang, "search.header", html.EscapeString(query), currentPage, totalPages)
 	for i, hit := range searchResult.Hits {
 
-		chatTitle := hit["TITLE"]
-		if chatTitle == nil || chatTitle == "" {
-			if chatType, ok := hit["TYPE"].(string); ok {
-				switch chatType {
-				case "private":
-					chatTitle = "私聊"
-				case "group", "supergroup":
-					chatTitle = "群组"
-				case "channel":
-					chatTitle = "频道"
-				default:
-					chatTitle = "未知"
-				}
-			} else {
-				chatTitle = "未知"
+		chatTitle := hit.Title
+		if chatTitle == "" {
+			switch hit.Type {
+			case "private":
+				chatTitle = i18n.T(lang, "chat.private")
+			case "group", "supergroup":
+				chatTitle = i18n.T(lang, "chat.group")
+			case "channel":
+				chatTitle = i18n.T(lang, "chat.channel")
+			default:
+				chatTitle = i18n.T(lang, "chat.unknown")
 			}
 		}
 		var displayTitle string
-		chatUsername, ok := hit["USERNAME"].(string)
-		if ok && chatUsername != "" {
-			displayTitle = fmt.Sprintf("<a href=\"https://t.me/%s\">%s</a>", chatUsername, html.EscapeString(fmt.Sprint(chatTitle)))
+		if hit.Username != "" {
+			displayTitle = fmt.Sprintf("<a href=\"https://t.me/%s\">%s</a>", hit.Username, html.EscapeString(chatTitle))
 		} else {
-			displayTitle = html.EscapeString(fmt.Sprint(chatTitle))
+			displayTitle = html.EscapeString(chatTitle)
 		}
 
-		if messageIDFloat, ok := hit["MESSAGE_ID"].(float64); ok {
-			messageID := int(messageIDFloat)
-			messageText, textOk := hit["text"].(string)
-			if textOk && messageText != "" {
-				if len([]rune(messageText)) > 120 {
-					messageText = string([]rune(messageText)[:120]) + "..."
+		if hit.Kind == search.KindMessage {
+			if hit.Text != "" {
+				// Prefer the crop centred on the match, with the matched terms in bold.
+				snippet := hit.Highlight["text"]
+				if snippet == "" {
+					messageText := hit.Text
+					if len([]rune(messageText)) > 120 {
+						messageText = string([]rune(messageText)[:120]) + "..."
+					}
+					snippet = html.EscapeString(messageText)
 				}
 				jumpLink := ""
-				if chatUsername, ok := hit["USERNAME"].(string); ok && chatUsername != "" {
-					jumpLink = fmt.Sprintf(" <a href=\"https://t.me/%s/%d\">(跳转)</a>", chatUsername, messageID)
+				if hit.Username != "" {
+					jumpLink = fmt.Sprintf(" <a href=\"https://t.me/%s/%d\">%s</a>", hit.Username, hit.MessageID, i18n.T(lang, "search.jump"))
 				}
-				response += fmt.Sprintf("<b>%d. 💬 消息</b> from %s%s\n", i+1+int((currentPage-1)*hitsPerPage), displayTitle, jumpLink)
-				response += fmt.Sprintf("<blockquote>%s</blockquote>\n", html.EscapeString(messageText))
+				response += fmt.Sprintf("<b>%d. %s</b> %s %s%s\n", i+1+(currentPage-1)*hitsPerPage, i18n.T(lang, "search.message_label"), i18n.T(lang, "search.from"), displayTitle, jumpLink)
+				response += fmt.Sprintf("<blockquote>%s</blockquote>\n", snippet)
 			}
 		} else {
-			var typeEmoji string
-			if chatType, ok := hit["TYPE"].(string); ok {
-				switch chatType {
-				case "private":
-					typeEmoji = "👤"
-				case "supergroup":
-					typeEmoji = "👑"
-				case "group":
-					typeEmoji = "👥"
-				case "channel":
-					typeEmoji = "📢"
-				case "bot":
-					typeEmoji = "🤖"
-				}
-			}
+			typeEmoji := chatTypeEmoji(hit.Type)
 			var membersCountStr string
-			if membersCount, ok := hit["MEMBERS_COUNT"].(float64); ok && membersCount > 0 {
-				membersCountStr = fmt.Sprintf(" %d", int(membersCount))
+			if hit.MembersCount > 0 {
+				membersCountStr = fmt.Sprintf(" %d", hit.MembersCount)
 			}
-			response += fmt.Sprintf("<b>%d. %s</b> %s%s\n\n", i+1+int((currentPage-1)*hitsPerPage), displayTitle, typeEmoji, membersCountStr)
+			response += fmt.Sprintf("<b>%d. %s</b> %s%s\n\n", i+1+(currentPage-1)*hitsPerPage, displayTitle, typeEmoji, membersCountStr)
 		}
 	}
 
+	state.Page = currentPage
+	stateID, err := m.stateStore.Save(state)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to save callback state: %w", err)
+	}
+
 	var buttonRows [][]telebot.InlineButton
 	paginationRow := []telebot.InlineButton{}
 	if currentPage > 1 {
-		paginationRow = append(paginationRow, telebot.InlineButton{Text: "⬅️ 上一页", Data: fmt.Sprintf("prev_%s_%s", filter, query)})
+		paginationRow = append(paginationRow, telebot.InlineButton{Text: i18n.T(lang, "search.btn_prev"), Data: "prev_" + stateID})
 	}
 	paginationRow = append(paginationRow, telebot.InlineButton{Text: fmt.Sprintf("%d/%d", currentPage, totalPages), Data: "current"})
 	if currentPage < totalPages {
-		paginationRow = append(paginationRow, telebot.InlineButton{Text: "下一页 ➡️", Data: fmt.Sprintf("next_%s_%s", filter, query)})
+		paginationRow = append(paginationRow, telebot.InlineButton{Text: i18n.T(lang, "search.btn_next"), Data: "next_" + stateID})
 	}
 	buttonRows = append(buttonRows, paginationRow)
 
-	filterModels := []struct {
-		Text  string
-		Value string
-	}{
-		{"全部", "all"}, {"群组", "group"}, {"频道", "channel"}, {"机器人", "bot"}, {"消息", "message"},
+	sorts, defaultSort := search.Sorts(filter)
+	currentSort := state.Sort
+	if currentSort == "" {
+		currentSort = defaultSort
 	}
+	var sortRow []telebot.InlineButton
+	for _, value := range sorts {
+		text := i18n.T(lang, "sort."+value)
+		if currentSort == value {
+			text = "✅ " + text
+		}
+		sortRow = append(sortRow, telebot.InlineButton{Text: text, Data: fmt.Sprintf("sort_%s_%s", value, stateID)})
+	}
+	buttonRows = append(buttonRows, sortRow)
+
+	filterValues := []string{"all", "group", "channel", "bot", "message"}
 	var filterButtons []telebot.InlineButton
-	for _, model := range filterModels {
-		text := model.Text
+	for _, value := range filterValues {
+		text := i18n.T(lang, "filter."+value)
 		currentFilter := filter
 		if currentFilter == "" {
 			currentFilter = "all"
 		}
-		if currentFilter == model.Value {
+		if currentFilter == value {
 			text = "✅ " + text
 		}
-		filterButtons = append(filterButtons, telebot.InlineButton{Text: text, Data: fmt.Sprintf("filter_%s_%s", model.Value, query)})
+		filterButtons = append(filterButtons, telebot.InlineButton{Text: text, Data: fmt.Sprintf("filter_%s_%s", value, stateID)})
 	}
 	const maxButtonsPerRow = 3
 	for i := 0; i < len(filterButtons); i += maxButtonsPerRow {
@@ -367,34 +390,31 @@ func (m *messageUsecaseImpl) buildSearchResponse(query string, filter string, se
 
 // SearchWithPagination handles paginated search queries.
 func (m *messageUsecaseImpl) SearchWithPagination(c telebot.Context, query string, page int, filter string) error {
+	lang := m.locales.Lang(c.Sender())
 	if query == "" {
-		return c.Send("请输入搜索关键字。")
+		return c.Send(i18n.T(lang, "search.empty_query"))
 	}
 
 	limit := 10
-	searchResultRaw, err := m.searchRepo.Search(query, page, limit, filter)
+	searchResult, err := m.searchRepo.Search(query, page, limit, filter, search.Options{})
+	if errors.Is(err, searchquery.ErrInvalidQuery) {
+		return c.Send(i18n.T(lang, "search.invalid_query", err.Error()))
+	}
 	if err != nil {
 		log.Printf("ERROR: search failed: %v", err)
-		return c.Send(fmt.Sprintf("🔍 搜索失败: `%s`", err.Error()), &telebot.SendOptions{ParseMode: telebot.ModeMarkdown})
+		return c.Send(i18n.T(lang, "search.failed", err.Error()), &telebot.SendOptions{ParseMode: telebot.ModeMarkdown})
 	}
 
-	var searchResult SearchResponse
-	if err := json.Unmarshal(searchResultRaw, &searchResult); err != nil {
-		log.Printf("ERROR: failed to unmarshal search result: %v", err)
-		return c.Send("🔍 搜索失败: 无法解析搜索结果。")
-	}
+	m.logSearch(searchlog.Entry{Query: query, Filter: filter, Hits: int64(searchResult.TotalHits), UserID: senderID(c.Sender()), Source: searchlog.SourceBot})
 
 	// Asynchronously validate usernames without blocking the search result response.
-	var hits []interface{}
-	for _, hit := range searchResult.Hits {
-		hits = append(hits, hit)
-	}
-	go m.validateUsernamesAsync(hits)
+	go m.validateUsernamesAsync(searchResult.Hits)
 
-	response, buttonRows, err := m.buildSearchResponse(query, filter, &searchResult)
+	state := callbackstate.State{Query: query, Filter: filter, Page: page}
+	response, buttonRows, err := m.buildSearchResponse(lang, state, searchResult)
 	if err != nil {
 		log.Printf("ERROR: Failed to build search response: %v", err)
-		return c.Send("构建搜索结果失败。")
+		return c.Send(i18n.T(lang, "search.build_failed"))
 	}
 
 	markup := &telebot.ReplyMarkup{
@@ -421,15 +441,16 @@ func (m *messageUsecaseImpl) SearchWithPagination(c telebot.Context, query strin
 // @param c Telegram上下文
 // @return error 错误信息
 func (m *messageUsecaseImpl) HandleCallback(c telebot.Context) error {
+	lang := m.locales.Lang(c.Sender())
 	bot, ok := c.Bot().(*telebot.Bot)
 	if !ok {
-		return c.Respond(&telebot.CallbackResponse{Text: "内部错误", ShowAlert: true})
+		return c.Respond(&telebot.CallbackResponse{Text: i18n.T(lang, "callback.internal_error"), ShowAlert: true})
 	}
 
-	newText, newMarkup, err := m.handleCallbackLogic(c.Callback().Data, c.Callback().Message.Text)
+	newText, newMarkup, err := m.handleCallbackLogic(lang, senderID(c.Sender()), c.Callback().Data)
 
 	if err != nil {
-		return c.Respond(&telebot.CallbackResponse{Text: "操作失败: " + err.Error()})
+		return c.Respond(&telebot.CallbackResponse{Text: i18n.T(lang, "callback.failed", err.Error())})
 	}
 
 	if newText != "" && newMarkup == nil {
@@ -452,6 +473,7 @@ func (m *messageUsecaseImpl) HandleCallback(c telebot.Context) error {
 }
 
 func (m *messageUsecaseImpl) HandleReviewCallback(c telebot.Context) error {
+	lang := m.locales.Lang(c.Sender())
 	data := c.Callback().Data
 	var responseText string
 	var err error
@@ -459,27 +481,27 @@ func (m *messageUsecaseImpl) HandleReviewCallback(c telebot.Context) error {
 	if strings.HasPrefix(data, "delete_doc_") {
 		docID := strings.TrimPrefix(data, "delete_doc_")
 		if docID == "" {
-			responseText = "❌ 删除失败: 无效的文档ID"
+			responseText = i18n.T(lang, "review.invalid_id")
 			log.Printf("ERROR: Attempted to delete document with empty ID.")
 		} else {
 			// 添加额外的安全检查，确保 docID 不为空且格式有效
 			if strings.Contains(docID, "/") || strings.Contains(docID, "\\") {
-				responseText = "❌ 删除失败: 无效的文档ID格式"
+				responseText = i18n.T(lang, "review.invalid_format")
 				log.Printf("ERROR: Invalid document ID format: %s", docID)
 			} else {
 				err = m.searchRepo.DeleteDocument(docID)
 				if err != nil {
-					responseText = "❌ 删除失败"
+					responseText = i18n.T(lang, "review.delete_failed")
 					log.Printf("ERROR: Failed to delete document %s: %v", docID, err)
 				} else {
-					responseText = fmt.Sprintf("✅ 文档 %s 已被删除。", docID)
+					responseText = i18n.T(lang, "review.deleted", docID)
 					log.Printf("INFO: Successfully deleted document %s", docID)
 				}
 			}
 		}
 	} else if strings.HasPrefix(data, "keep_doc_") {
 		docID := strings.TrimPrefix(data, "keep_doc_")
-		responseText = fmt.Sprintf("👍 文档 %s 已被保留。", docID)
+		responseText = i18n.T(lang, "review.kept", docID)
 	} else {
 		return c.Respond() // Ignore other callbacks
 	}
@@ -499,68 +521,89 @@ func (m *messageUsecaseImpl) HandleReviewCallback(c telebot.Context) error {
 }
 
 // handleCallbackLogic contains the testable logic for handling callbacks.
-func (m *messageUsecaseImpl) handleCallbackLogic(data, messageText string) (string, [][]telebot.InlineButton, error) {
-	// Callback data format: action_filter_query
-	// Use SplitN to correctly handle queries that may contain underscores.
-	parts := strings.SplitN(data, "_", 3)
-	if len(parts) < 1 {
-		return "", nil, errors.New("invalid callback data")
-	}
-	action := parts[0]
+// userID identifies the user in the search log.
+func (m *messageUsecaseImpl) handleCallbackLogic(lang string, userID string, data string) (string, [][]telebot.InlineButton, error) {
+	// Callback data format: prev_<stateID>, next_<stateID>, filter_<filter>_<stateID> or sort_<sort>_<stateID>.
+	// State IDs are base64url, so they may contain underscores; split from the left only.
+	action, rest, _ := strings.Cut(data, "_")
 
 	if action == "current" {
 		return "", nil, nil // No action needed for the current page button
 	}
 
-	// For prev/next/filter, we need the full callback data.
-	if len(parts) < 3 {
+	var stateID, value string
+	switch action {
+	case "prev", "next":
+		stateID = rest
+	case "filter", "sort":
+		value, stateID, _ = strings.Cut(rest, "_")
+	default:
+		return "", nil, fmt.Errorf("unknown action: %s", action)
+	}
+	if stateID == "" {
 		return "", nil, fmt.Errorf("incomplete callback data: %s", data)
 	}
 
-	var query, filter string
-	var page = 1
-
-	// Extract current page from the message text.
-	rePage := regexp.MustCompile(`\(第 (\d+) 页 / 共 (\d+) 页\)`)
-	pageMatches := rePage.FindStringSubmatch(messageText)
-	if len(pageMatches) >= 2 {
-		page, _ = strconv.Atoi(pageMatches[1])
+	state, err := m.stateStore.Load(stateID)
+	if err != nil {
+		if errors.Is(err, callbackstate.ErrNotFound) {
+			return "", nil, errors.New(i18n.T(lang, "search.expired"))
+		}
+		return "", nil, errors.New(i18n.T(lang, "search.state_failed", err))
 	}
 
-	filter = parts[1]
-	query = parts[2]
-
 	switch action {
 	case "filter":
-		page = 1 // Reset to the first page when filter changes
+		state.Filter = value
+		state.Page = 1 // Reset to the first page when filter changes
+		// Keep the sort order only if the new index supports it, e.g. messages cannot be sorted by members.
+		if sorts, _ := search.Sorts(value); !slices.Contains(sorts, state.Sort) {
+			state.Sort = ""
+		}
+	case "sort":
+		state.Sort = value
+		state.Page = 1
 	case "prev":
-		if page > 1 {
-			page--
+		if state.Page <= 1 {
+			return "", nil, errors.New(i18n.T(lang, "search.first_page"))
 		}
+		state.Page--
 	case "next":
-		page++
-	default:
-		return "", nil, fmt.Errorf("unknown action: %s", action)
+		state.Page++
 	}
 
 	// Perform the search again with the new parameters
 	limit := 10
-	searchResultRaw, err := m.searchRepo.Search(query, page, limit, filter)
+	searchResult, err := m.searchRepo.Search(state.Query, state.Page, limit, state.Filter, search.Options{Sort: state.Sort})
+	if errors.Is(err, searchquery.ErrInvalidQuery) {
+		return "", nil, errors.New(i18n.T(lang, "search.invalid_query", err.Error()))
+	}
 	if err != nil {
-		return "", nil, fmt.Errorf("搜索失败: %w", err)
+		return "", nil, errors.New(i18n.T(lang, "search.retry_failed", err))
 	}
 
-	var searchResult SearchResponse
-	if err := json.Unmarshal(searchResultRaw, &searchResult); err != nil {
-		log.Printf("ERROR: failed to unmarshal search result: %v", err)
-		return "", nil, fmt.Errorf("搜索失败: 无法解析搜索结果。")
+	m.logSearch(searchlog.Entry{Query: state.Query, Filter: state.Filter, Hits: int64(searchResult.TotalHits), UserID: userID, Source: searchlog.SourceCallback})
+
+	return m.buildSearchResponse(lang, state, searchResult)
+}
+
+// logSearch records a search when search logging is enabled.
+func (m *messageUsecaseImpl) logSearch(entry searchlog.Entry) {
+	if m.searchLog != nil {
+		m.searchLog.Log(entry)
 	}
+}
 
-	return m.buildSearchResponse(query, filter, &searchResult)
+// senderID returns the Telegram user ID as a string, or "" when unknown.
+func senderID(user *telebot.User) string {
+	if user == nil {
+		return ""
+	}
+	return strconv.FormatInt(user.ID, 10)
 }
 
 // sendReviewNotification sends a message to the review channel.
-func (m *messageUsecaseImpl) sendReviewNotification(bot *telebot.Bot, hit map[string]interface{}) {
+func (m *messageUsecaseImpl) sendReviewNotification(bot *telebot.Bot, hit search.Hit) {
 	go func() {
 		// Use a separate bot instance for sending review notifications
 		reviewBotToken := m.cfg.Bot.ReviewBotToken
@@ -572,36 +615,25 @@ func (m *messageUsecaseImpl) sendReviewNotification(bot *telebot.Bot, hit map[st
 			return
 		}
 
-		chatTitle, _ := hit["TITLE"].(string)
+		chatTitle := hit.Title
 		reviewChannelID, err := strconv.ParseInt(m.cfg.Bot.ReviewChannel, 10, 64)
 		if err != nil {
 			log.Printf("ERROR: Invalid review channel ID: %v", err)
 			return
 		}
 		reviewChat := &telebot.Chat{ID: reviewChannelID}
-		var docID string
-		if idVal, ok := hit["id"]; ok {
-			switch v := idVal.(type) {
-			case string:
-				docID = v
-			case float64:
-				docID = strconv.FormatFloat(v, 'f', 0, 64)
-			default:
-				log.Printf("ERROR: docID is of an unexpected type (%T) in sendReviewNotification. hit: %v", v, hit)
-				return
-			}
-		}
-
+		docID := hit.ID
 		if docID == "" {
-			log.Printf("ERROR: docID is empty after conversion in sendReviewNotification. hit: %v", hit)
+			log.Printf("ERROR: docID is empty in sendReviewNotification. hit: %+v", hit)
 			return
 		}
-		chatUsername, _ := hit["USERNAME"].(string)
-		message := fmt.Sprintf("<b>【疑似失效】</b>\n请审核: <a href=\"https://t.me/%s\">@%s</a>\n文档ID: <code>%s</code>", chatUsername, html.EscapeString(chatTitle), docID)
+		chatUsername := hit.Username
+		lang := i18n.Normalize(m.cfg.Bot.ReviewLanguage)
+		message := i18n.T(lang, "review.notification", chatUsername, html.EscapeString(chatTitle), docID)
 		inlineKeys := [][]telebot.InlineButton{
 			{
-				telebot.InlineButton{Text: "❌ 确认失效 (删除)", Data: fmt.Sprintf("delete_doc_%s", docID)},
-				telebot.InlineButton{Text: "✅ 保留 没有失效", Data: fmt.Sprintf("keep_doc_%s", docID)},
+				telebot.InlineButton{Text: i18n.T(lang, "review.btn_delete"), Data: fmt.Sprintf("delete_doc_%s", docID)},
+				telebot.InlineButton{Text: i18n.T(lang, "review.btn_keep"), Data: fmt.Sprintf("keep_doc_%s", docID)},
 			},
 		}
 
@@ -2,8 +2,16 @@ package usecase
 
 import (
 	"errors"
+	"strings"
 	"testing"
+	"time"
 
+	"bot-service/internal/callbackstate"
+	"bot-service/internal/i18n"
+
+	"telegram-bot-services/pkg/search"
+
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 )
 
@@ -12,18 +20,19 @@ type MockSearchUsecase struct {
 	mock.Mock
 }
 
-// Search provides a mock function with given fields: query, page, pageSize, filter
-func (_m *MockSearchUsecase) Search(query string, page int, pageSize int, filter string) ([]byte, error) {
-	args := _m.Called(query, page, pageSize, filter)
+// Search provides a mock function with given fields: query, page, pageSize, filter, opts
+func (_m *MockSearchUsecase) Search(query string, page int, pageSize int, filter string, opts search.Options) (*search.Result, error) {
+	args := _m.Called(query, page, pageSize, filter, opts)
 	// Handle the case where the first argument is nil
 	if args.Get(0) == nil {
 		return nil, args.Error(1)
 	}
-	return args.Get(0).([]byte), args.Error(1)
+	return args.Get(0).(*search.Result), args.Error(1)
 }
 
-func (_m *MockSearchUsecase) IndexToMeilisearch(data map[string]interface{}) error {
-	args := _m.Called(data)
+// DeleteDocument provides a mock function with given fields: docID
+func (_m *MockSearchUsecase) DeleteDocument(docID string) error {
+	args := _m.Called(docID)
 	return args.Error(0)
 }
 
@@ -32,119 +41,151 @@ type MockStorageRepository struct {
 	mock.Mock
 }
 
-// SaveAndIndex provides a mock function with given fields: data
-func (_m *MockStorageRepository) SaveAndIndex(data map[string]interface{}) error {
-	args := _m.Called(data)
-	return args.Error(0)
-}
-
 // SaveToPocketBase provides a mock function with given fields: data
 func (_m *MockStorageRepository) SaveToPocketBase(data map[string]interface{}) error {
 	args := _m.Called(data)
 	return args.Error(0)
 }
 
-// IndexToMeilisearch provides a mock function with given fields: data
-func (_m *MockStorageRepository) IndexToMeilisearch(data map[string]interface{}) error {
-	args := _m.Called(data)
-	return args.Error(0)
-}
-
 func TestHandleCallbackLogic(t *testing.T) {
 	// Test cases
 	testCases := []struct {
 		name          string
+		state         *callbackstate.State
 		callbackData  string
-		messageText   string
-		mockSearch    bool
-		mockReturn    []byte
+		searchPage    int
+		searchFilter  string
+		searchSort    string
+		mockReturn    *search.Result
 		mockError     error
 		expectedText  string
 		expectedError error
 	}{
 		{
 			name:         "Next Page Success",
-			callbackData: "next_all",
-			messageText:  "<b>🔍 关键字: test</b> (第 1 页 / 共 3 页)\n\n",
-			mockSearch:   true,
-			mockReturn:   []byte(`{"hits":[{"MESSAGE_ID": 456.0, "text":"result 2"}],"query":"test","totalPages":3,"page":2}`),
-			mockError:    nil,
-			expectedText: "<b>🔍 关键字: test</b> (第 2 页 / 共 3 页)\n\n<b>6. 💬 消息</b> from 未知\n<blockquote>result 2</blockquote>\n",
+			state:        &callbackstate.State{Query: "test", Filter: "all", Page: 1},
+			callbackData: "next_",
+			searchPage:   2,
+			searchFilter: "all",
+			mockReturn:   &search.Result{Hits: []search.Hit{{Kind: search.KindMessage, MessageID: 456, Text: "result 2"}}, TotalPages: 3, Page: 2, HitsPerPage: 10},
+			expectedText: "<b>🔍 关键字: test</b> (第 2 页 / 共 3 页)\n\n<b>11. 💬 消息</b> from 未知\n<blockquote>result 2</blockquote>\n",
 		},
 		{
 			name:         "Prev Page Success",
-			callbackData: "prev_all",
-			messageText:  "<b>🔍 关键字: test</b> (第 2 页 / 共 3 页)",
-			mockSearch:   true,
-			mockReturn:   []byte(`{"hits":[{"MESSAGE_ID": 123.0, "text": "result 1"}],"totalPages":3,"page":1}`),
-			mockError:    nil,
-			expectedText:  "<b>🔍 关键字: test</b> (第 1 页 / 共 3 页)\n\n<b>1. 💬 消息</b> from 未知\n<blockquote>result 1</blockquote>\n",
+			state:        &callbackstate.State{Query: "test", Filter: "all", Page: 2},
+			callbackData: "prev_",
+			searchPage:   1,
+			searchFilter: "all",
+			mockReturn:   &search.Result{Hits: []search.Hit{{Kind: search.KindMessage, MessageID: 123, Text: "result 1"}}, TotalPages: 3, Page: 1, HitsPerPage: 10},
+			expectedText: "<b>🔍 关键字: test</b> (第 1 页 / 共 3 页)\n\n<b>1. 💬 消息</b> from 未知\n<blockquote>result 1</blockquote>\n",
 		},
 		{
 			name:          "Already on First Page",
-			callbackData:  "prev_all",
-			messageText:   "<b>🔍 关键字: test</b> (第 1 页 / 共 3 页)\n\n",
-			mockSearch:    false,
+			state:         &callbackstate.State{Query: "test", Filter: "all", Page: 1},
+			callbackData:  "prev_",
 			expectedError: errors.New("已经是第一页了"),
 		},
 		{
-			name:          "Already on Last Page",
-			callbackData:  "next_all",
-			messageText:   "<b>🔍 关键字: test</b> (第 3 页 / 共 3 页)\n\n",
-			mockSearch:    false,
-			expectedError: errors.New("已经是最后一页了"),
+			name:         "Filter Change",
+			state:        &callbackstate.State{Query: "test", Filter: "all", Page: 3},
+			callbackData: "filter_group_",
+			searchPage:   1,
+			searchFilter: "group",
+			mockReturn:   &search.Result{Hits: []search.Hit{{Kind: search.KindChat, Title: "Group A", Username: "group_a", Type: "group"}}, TotalPages: 1, Page: 1},
+			expectedText: "<b>🔍 关键字: test</b> (第 1 页 / 共 1 页)\n\n<b>1. <a href=\"https://t.me/group_a\">Group A</a></b> 👥\n\n",
 		},
 		{
-			name:         "Filter Change",
-			callbackData: "filter_group",
-			messageText:  "<b>🔍 关键字: test</b> (第 1 页 / 共 1 页)",
-			mockSearch:   true,
-			mockReturn:   []byte(`{"hits":[{"TITLE": "Group A", "chat_username": "group_a", "TYPE": "group"}],"totalPages":1,"page":1}`),
-			mockError:    nil,
-			expectedText:  "<b>🔍 关键字: test</b> (第 1 页 / 共 1 页)\n\n<b>1. <a href=\"https://t.me/group_a\">Group A</a></b> 👥\n\n",
+			name:         "Highlighted Snippet",
+			state:        &callbackstate.State{Query: "test", Filter: "message", Page: 1},
+			callbackData: "next_",
+			searchPage:   2,
+			searchFilter: "message",
+			mockReturn:   &search.Result{Hits: []search.Hit{{Kind: search.KindMessage, MessageID: 9, Text: "a <tag> crypto news and more", Highlight: map[string]string{"text": "…a &lt;tag&gt; <b>crypto</b> news…"}}}, TotalPages: 2, Page: 2, HitsPerPage: 10},
+			expectedText: "<b>🔍 关键字: test</b> (第 2 页 / 共 2 页)\n\n<b>11. 💬 消息</b> from 未知\n<blockquote>…a &lt;tag&gt; <b>crypto</b> news…</blockquote>\n",
 		},
 		{
-			name:          "Current Page No-op",
-			callbackData:  "current",
-			messageText:   "<b>🔍 关键字: test</b> (第 1 页 / 共 3 页)\n\n",
-			mockSearch:    false,
-			expectedText:  "",
-			expectedError: nil,
+			name:         "Sort Change",
+			state:        &callbackstate.State{Query: "test", Filter: "channel", Page: 2},
+			callbackData: "sort_growth_",
+			searchPage:   1,
+			searchFilter: "channel",
+			searchSort:   "growth",
+			mockReturn:   &search.Result{Hits: []search.Hit{{Kind: search.KindChat, Title: "Channel A", Type: "channel"}}, TotalPages: 1, Page: 1},
+			expectedText: "<b>🔍 关键字: test</b> (第 1 页 / 共 1 页)\n\n<b>1. Channel A</b> 📢\n\n",
 		},
 		{
-			name:          "Unknown Action",
-			callbackData:  "unknown_action",
-			messageText:   "<b>🔍 关键字: test</b> (第 1 页 / 共 3 页)\n\n",
-			mockSearch:    false,
-			expectedError: errors.New("未知操作"),
+			name:         "Filter Change Drops Unsupported Sort",
+			state:        &callbackstate.State{Query: "test", Filter: "channel", Page: 1, Sort: "members"},
+			callbackData: "filter_message_",
+			searchPage:   1,
+			searchFilter: "message",
+			mockReturn:   &search.Result{Hits: []search.Hit{{Kind: search.KindMessage, MessageID: 7, Text: "hello"}}, TotalPages: 1, Page: 1},
+			expectedText: "<b>🔍 关键字: test</b> (第 1 页 / 共 1 页)\n\n<b>1. 💬 消息</b> from 未知\n<blockquote>hello</blockquote>\n",
 		},
 		{
-			name:          "Cannot Parse Query",
-			callbackData:  "next_all",
-			messageText:   "Invalid message text",
-			mockSearch:    false,
-			expectedError: errors.New("无法解析查询关键字"),
+			name:         "Current Page No-op",
+			callbackData: "current",
 		},
 		{
-			name:          "Cannot Parse Page",
-			callbackData:  "next_all",
-			messageText:   "<b>🔍 关键字: test</b> (第 a 页 / 共 b 页)\n\n",
-			mockSearch:    false,
-			expectedError: errors.New("无法解析页码"),
+			name:          "Unknown Action",
+			callbackData:  "unknown_action",
+			expectedError: errors.New("unknown action: unknown"),
+		},
+		{
+			name:          "Expired State",
+			callbackData:  "next_missing",
+			expectedError: errors.New("搜索已过期，请重新搜索"),
 		},
 	}
 
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
 			mockSearchUsecase := new(MockSearchUsecase)
+			store := callbackstate.NewStore(time.Minute, nil)
+			defer store.Close()
+			m := &messageUsecaseImpl{searchRepo: mockSearchUsecase, stateStore: store}
+
+			data := tc.callbackData
+			if tc.state != nil {
+				id, err := store.Save(*tc.state)
+				assert.NoError(t, err)
+				data += id
+			}
 
-			if tc.mockSearch {
-				mockSearchUsecase.On("Search", "test", mock.AnythingOfType("int"), mock.AnythingOfType("int"), mock.AnythingOfType("string")).Return(tc.mockReturn, tc.mockError).Once()
+			if tc.mockReturn != nil {
+				mockSearchUsecase.On("Search", "test", tc.searchPage, 10, tc.searchFilter, search.Options{Sort: tc.searchSort}).Return(tc.mockReturn, tc.mockError).Once()
 			}
 
-			
+			text, _, err := m.handleCallbackLogic(i18n.LangZH, "", data)
+			if tc.expectedError != nil {
+				assert.EqualError(t, err, tc.expectedError.Error())
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tc.expectedText, text)
+			}
 
 			mockSearchUsecase.AssertExpectations(t)
 		})
 	}
-}
\ No newline at end of file
+}
+
+func TestBuildSearchResponseCallbackDataFitsLimit(t *testing.T) {
+	store := callbackstate.NewStore(time.Minute, nil)
+	defer store.Close()
+	m := &messageUsecaseImpl{stateStore: store}
+	query := strings.Repeat("加密货币交易所", 20)
+	searchResult := &search.Result{
+		Hits:        []search.Hit{{Kind: search.KindChat, Title: "Group A", Type: "group"}},
+		Page:        2,
+		TotalPages:  3,
+		HitsPerPage: 10,
+	}
+
+	_, buttonRows, err := m.buildSearchResponse(i18n.LangZH, callbackstate.State{Query: query, Filter: "channel"}, searchResult)
+	assert.NoError(t, err)
+	for _, row := range buttonRows {
+		for _, button := range row {
+			assert.LessOrEqual(t, len(button.Data), 64, "callback data %q exceeds Telegram limit", button.Data)
+		}
+	}
+}
@@ -4,11 +4,13 @@ import (
 	"bot-service/internal/repository"
 	"fmt"
 	"log"
+
+	"telegram-bot-services/pkg/search"
 )
 
 // SearchUsecase defines the interface for the search use case.
 type SearchUsecase interface {
-	Search(query string, page int, limit int, filter string) ([]byte, error)
+	Search(query string, page int, limit int, filter string, opts search.Options) (*search.Result, error)
 	DeleteDocument(docID string) error
 }
 
@@ -29,8 +31,8 @@ func NewSearchUsecase(searchRepo repository.SearchRepository) SearchUsecase {
 }
 
 // Search performs a search using the search repository.
-func (s *searchUsecaseImpl) Search(query string, page int, limit int, filter string) ([]byte, error) {
-	log.Printf("INFO: Performing search: query='%s', page=%d, limit=%d, filter='%s'", query, page, limit, filter)
+func (s *searchUsecaseImpl) Search(query string, page int, limit int, filter string, opts search.Options) (*search.Result, error) {
+	log.Printf("INFO: Performing search: query='%s', page=%d, limit=%d, filter='%s', sort='%s'", query, page, limit, filter, opts.Sort)
 
 	if query == "" {
 		log.Printf("ERROR: empty search query")
@@ -47,7 +49,7 @@ func (s *searchUsecaseImpl) Search(query string, page int, limit int, filter str
 		return nil, fmt.Errorf("limit must be greater than 0")
 	}
 
-	result, err := s.searchRepo.Search(query, page, limit, filter)
+	result, err := s.searchRepo.Search(query, page, limit, filter, opts)
 	if err != nil {
 		log.Printf("ERROR: failed to search: %v", err)
 		return nil, fmt.Errorf("failed to search: %w", err)
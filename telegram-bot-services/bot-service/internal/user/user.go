@@ -130,4 +130,117 @@ func SaveUser(user *telebot.User) error {
 	}
 
 	return nil
-}
\ No newline at end of file
+}
+
+// LanguagePreferences 通过管理服务的 tele_user 集合持久化用户语言偏好，实现 i18n.Preferences
+type LanguagePreferences struct{}
+
+// GetPreferredLanguage 读取用户通过 /lang 设置的语言，未设置或用户不存在时返回空字符串
+func (LanguagePreferences) GetPreferredLanguage(userID int64) (string, error) {
+	return GetPreferredLanguage(userID)
+}
+
+// SetPreferredLanguage 保存用户的语言偏好
+func (LanguagePreferences) SetPreferredLanguage(userID int64, lang string) error {
+	return SetPreferredLanguage(userID, lang)
+}
+
+// GetPreferredLanguage reads the language a user selected with /lang.
+func GetPreferredLanguage(userID int64) (string, error) {
+	c, err := config.LoadConfig("development")
+	if err != nil {
+		return "", fmt.Errorf("failed to load config: %w", err)
+	}
+	collectionURL := fmt.Sprintf("%s/api/collections/tele_user/records", c.Bot.ManagementServiceURL)
+	findURL := fmt.Sprintf("%s?filter=(tg_user_id='%s')&fields=preferred_language", collectionURL, strconv.FormatInt(userID, 10))
+
+	req, err := http.NewRequest(http.MethodGet, findURL, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to create find request: %w", err)
+	}
+	req.Header.Set("Authorization", c.Bot.ManagementServiceToken)
+
+	client := &http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to query user: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("failed to query user, status: %s", resp.Status)
+	}
+
+	var findResult struct {
+		Items []struct {
+			PreferredLanguage string `json:"preferred_language"`
+		} `json:"items"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&findResult); err != nil {
+		return "", fmt.Errorf("failed to decode find user response: %w", err)
+	}
+	if len(findResult.Items) == 0 {
+		return "", nil
+	}
+	return findResult.Items[0].PreferredLanguage, nil
+}
+
+// SetPreferredLanguage stores the language a user selected with /lang.
+// The user record must already exist (see SaveUser).
+func SetPreferredLanguage(userID int64, lang string) error {
+	c, err := config.LoadConfig("development")
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+	collectionURL := fmt.Sprintf("%s/api/collections/tele_user/records", c.Bot.ManagementServiceURL)
+	findURL := fmt.Sprintf("%s?filter=(tg_user_id='%s')", collectionURL, strconv.FormatInt(userID, 10))
+
+	req, err := http.NewRequest(http.MethodGet, findURL, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create find request: %w", err)
+	}
+	req.Header.Set("Authorization", c.Bot.ManagementServiceToken)
+
+	client := &http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to query user: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var findResult struct {
+		Items []struct {
+			ID string `json:"id"`
+		} `json:"items"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&findResult); err != nil {
+		return fmt.Errorf("failed to decode find user response: %w", err)
+	}
+	if len(findResult.Items) == 0 {
+		return fmt.Errorf("user %d not found", userID)
+	}
+
+	jsonData, err := json.Marshal(map[string]interface{}{
+		"preferred_language": lang,
+		"update_time":        time.Now().UTC().Format(time.RFC3339),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal language preference: %w", err)
+	}
+	updateURL := fmt.Sprintf("%s/%s", collectionURL, findResult.Items[0].ID)
+	req, err = http.NewRequest(http.MethodPatch, updateURL, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return fmt.Errorf("failed to create update request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", c.Bot.ManagementServiceToken)
+
+	resp, err = client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to update user: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to update user, status: %s", resp.Status)
+	}
+	return nil
+}
@@ -8,6 +8,7 @@ require (
 	github.com/go-resty/resty/v2 v2.16.5
 	github.com/gotd/td v0.131.0
 	github.com/joho/godotenv v1.5.1
+	telegram-bot-services/pkg v0.0.0
 )
 
 require (
@@ -45,3 +46,5 @@ require (
 	gopkg.in/yaml.v2 v2.4.0 // indirect
 	rsc.io/qr v0.2.0 // indirect
 )
+
+replace telegram-bot-services/pkg => ../pkg
@@ -4,13 +4,18 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+	"time"
 )
 
 type Config struct {
-	Server   ServerConfig   `json:"server"`
-	Telegram TelegramConfig `json:"telegram"`
-	Storage  StorageConfig  `json:"storage"`
-	Search   SearchConfig   `json:"search"`
+	Server     ServerConfig     `json:"server"`
+	Telegram   TelegramConfig   `json:"telegram"`
+	Storage    StorageConfig    `json:"storage"`
+	Search     SearchConfig     `json:"search"`
+	Session    SessionConfig    `json:"session"`
+	History    HistoryConfig    `json:"history"`
+	Media      MediaConfig      `json:"media"`
+	Enrichment EnrichmentConfig `json:"enrichment"`
 }
 
 type ServerConfig struct {
@@ -23,13 +28,71 @@ type TelegramConfig struct {
 }
 
 type StorageConfig struct {
-	PocketBaseURL string `json:"pocketBaseURL"`
+	PocketBaseURL   string `json:"pocketBaseURL"`
+	PocketBaseToken string `json:"pocketBaseToken"`
 }
 
 type SearchConfig struct {
-	MeilisearchURL  string `json:"meilisearch_url"`
-	MeilisearchToken string `json:"meilisearch_token"`
-	MessageLimit     int    `json:"message_limit"`
+	MeilisearchURL   string `json:"meilisearchURL"`
+	MeilisearchToken string `json:"meilisearchToken"`
+	// IndexName 消息索引名称，默认 messages，须与机器人服务的 search.indexName 一致
+	IndexName    string `json:"indexName"`
+	MessageLimit int    `json:"message_limit"`
+}
+
+// SessionConfig MTProto会话持久化配置
+type SessionConfig struct {
+	// Backend 会话存储后端："file"（默认）或 "pocketbase"
+	Backend string `json:"backend"`
+	// Dir 文件后端的会话目录
+	Dir string `json:"dir"`
+	// EncryptionKey 会话加密口令，可通过 SESSION_ENCRYPTION_KEY 环境变量覆盖
+	EncryptionKey string `json:"encryption_key"`
+}
+
+// HistoryConfig 历史消息增量采集与回填配置
+type HistoryConfig struct {
+	// PageSize 每页消息数，默认且最大为 100
+	PageSize int `json:"page_size"`
+	// BackfillPagesPerRun 每次采集每个群组最多回填的页数，默认 5
+	BackfillPagesPerRun int `json:"backfill_pages_per_run"`
+	// BackfillDepth 每个群组最多回填的消息数，0 表示不限制
+	BackfillDepth int `json:"backfill_depth"`
+	// BackfillUntil 回填截止日期（YYYY-MM-DD），为空时回填到群组起点
+	BackfillUntil string `json:"backfill_until"`
+}
+
+// MediaConfig 媒体归档配置
+type MediaConfig struct {
+	// Enabled 是否下载媒体并保存到 S3 兼容存储
+	Enabled bool `json:"enabled"`
+	// MaxFileSize 下载的最大文件大小（字节），默认 20 MB
+	MaxFileSize int64 `json:"max_file_size"`
+	// ThumbnailSize 图片缩略图长边像素数，默认 320
+	ThumbnailSize int      `json:"thumbnail_size"`
+	S3            S3Config `json:"s3"`
+}
+
+// S3Config S3兼容对象存储配置
+type S3Config struct {
+	Endpoint string `json:"endpoint"`
+	Region   string `json:"region"`
+	Bucket   string `json:"bucket"`
+	// AccessKey、SecretKey 可通过 S3_ACCESS_KEY、S3_SECRET_KEY 环境变量覆盖
+	AccessKey string `json:"access_key"`
+	SecretKey string `json:"secret_key"`
+	// UsePathStyle 使用路径形式的地址，MinIO 需要开启
+	UsePathStyle bool `json:"use_path_style"`
+}
+
+// EnrichmentConfig telegram_index 群组元数据补全配置
+type EnrichmentConfig struct {
+	// IntervalMinutes 刷新间隔（分钟），默认 360
+	IntervalMinutes int `json:"interval_minutes"`
+	// DelaySeconds 相邻两个群组之间的间隔（秒），默认 3
+	DelaySeconds int `json:"delay_seconds"`
+	// PhoneNumber 用于查询的账号，为空时使用任意已连接的账号
+	PhoneNumber string `json:"phone_number"`
 }
 
 func Load(env string) (*Config, error) {
@@ -45,5 +108,22 @@ func Load(env string) (*Config, error) {
 		return nil, fmt.Errorf("failed to decode config: %w", err)
 	}
 
+	if cfg.History.BackfillUntil != "" {
+		if _, err := time.Parse(time.DateOnly, cfg.History.BackfillUntil); err != nil {
+			return nil, fmt.Errorf("invalid history.backfill_until: %w", err)
+		}
+	}
+
+	// 会话口令属于敏感信息，优先从环境变量读取
+	if key := os.Getenv("SESSION_ENCRYPTION_KEY"); key != "" {
+		cfg.Session.EncryptionKey = key
+	}
+	if key := os.Getenv("S3_ACCESS_KEY"); key != "" {
+		cfg.Media.S3.AccessKey = key
+	}
+	if key := os.Getenv("S3_SECRET_KEY"); key != "" {
+		cfg.Media.S3.SecretKey = key
+	}
+
 	return cfg, nil
 }
\ No newline at end of file
@@ -12,12 +12,17 @@ package main
 
 import (
 	"collection-service/internal/config"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
 
 	"os"
+	"os/signal"
+	"strings"
+	"syscall"
 	"time"
 
 	"collection-service/service"
@@ -29,6 +34,8 @@ import (
 // @version 1.0.0
 type ConfigureRequest struct {
 	ChatIDs []int64 `json:"chat_ids"`
+	// Chats 用户名、t.me 链接或数字ID，由采集账号解析为会话ID
+	Chats []string `json:"chats"`
 }
 
 // LoginRequest 登录请求结构体
@@ -38,6 +45,7 @@ type ConfigureRequest struct {
 type LoginRequest struct {
 	PhoneNumber string `json:"phone_number"`
 	Code        string `json:"code,omitempty"`
+	Password    string `json:"password,omitempty"`
 }
 
 // 全局服务实例
@@ -46,9 +54,10 @@ var (
 	sessionService   service.SessionService
 	storageService   service.StorageService
 	collectionService service.CollectionService
+	enrichmentService service.EnrichmentService
 )
 
-// handleLogin 处理登录请求
+// handleLogin 处理登录请求，依次提交电话号码、验证码和两步验证密码（如账号开启）
 // @author fcj
 // @date 2023-11-15
 // @version 1.0.0
@@ -71,39 +80,48 @@ func handleLogin(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// 如果没有提供验证码，则发送验证码
-	if req.Code == "" {
-		// 创建会话
+	var result *service.LoginResult
+	var err error
+	switch {
+	case req.Password != "":
+		// 第三步：提交两步验证密码
+		result, err = telegramService.CheckPassword(req.PhoneNumber, req.Password)
+	case req.Code != "":
+		// 第二步：提交验证码
+		result, err = telegramService.Login(req.PhoneNumber, req.Code)
+	default:
+		// 第一步：创建会话并发送验证码
 		if err := sessionService.CreateSession(req.PhoneNumber); err != nil {
 			log.Printf("Failed to create session: %v", err)
 		}
-
-		// 发送验证码
-		if err := telegramService.SendCode(req.PhoneNumber); err != nil {
-			http.Error(w, fmt.Sprintf("Failed to send verification code: %v", err), http.StatusInternalServerError)
-			return
+		result, err = telegramService.SendCode(req.PhoneNumber)
+	}
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, service.ErrNoLoginInProgress) {
+			status = http.StatusConflict
 		}
-
-		w.WriteHeader(http.StatusOK)
-		json.NewEncoder(w).Encode(map[string]string{"status": "code_sent"})
+		http.Error(w, fmt.Sprintf("Login failed: %v", err), status)
 		return
 	}
 
-	// 如果提供了验证码，则进行登录
-	if err := telegramService.Login(req.PhoneNumber, req.Code); err != nil {
-		http.Error(w, fmt.Sprintf("Login failed: %v", err), http.StatusInternalServerError)
-		return
-	}
+	w.WriteHeader(loginHTTPStatus(result.Status))
+	json.NewEncoder(w).Encode(result)
+}
 
-	// 检查是否已认证
-	isAuthorized, err := telegramService.IsAuthorized(req.PhoneNumber)
-	if err != nil || !isAuthorized {
-		http.Error(w, fmt.Sprintf("Authorization check failed: %v", err), http.StatusInternalServerError)
-		return
+// loginHTTPStatus 将登录状态映射为HTTP状态码，流程中的正常状态返回200
+// @param status 登录状态
+// @return int HTTP状态码
+func loginHTTPStatus(status service.LoginStatus) int {
+	switch status {
+	case service.LoginStatusCodeInvalid, service.LoginStatusPasswordInvalid:
+		return http.StatusUnauthorized
+	case service.LoginStatusCodeExpired:
+		return http.StatusGone
+	case service.LoginStatusSignUpRequired:
+		return http.StatusForbidden
 	}
-
-	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(map[string]string{"status": "logged_in"})
+	return http.StatusOK
 }
 
 // handleConfigure 处理配置请求
@@ -131,14 +149,40 @@ func handleConfigure(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// 使用采集账号解析用户名和链接，解析结果（含 access hash）会被缓存
+	chatIDs := req.ChatIDs
+	resolved := make([]*service.ResolvedPeer, 0, len(req.Chats))
+	for _, target := range req.Chats {
+		peer, err := telegramService.ResolvePeer(phoneNumber, target)
+		if err != nil {
+			status := http.StatusInternalServerError
+			switch {
+			case errors.Is(err, service.ErrInvalidPeerTarget):
+				status = http.StatusBadRequest
+			case errors.Is(err, service.ErrPeerNotFound):
+				status = http.StatusNotFound
+			}
+			http.Error(w, fmt.Sprintf("Failed to resolve %q: %v", target, err), status)
+			return
+		}
+		resolved = append(resolved, peer)
+		chatIDs = append(chatIDs, peer.ChatID)
+	}
+
 	// 更新会话配置
-	if err := sessionService.UpdateSession(phoneNumber, req.ChatIDs); err != nil {
+	if err := sessionService.UpdateSession(phoneNumber, chatIDs); err != nil {
 		http.Error(w, fmt.Sprintf("Failed to update session: %v", err), http.StatusInternalServerError)
 		return
 	}
+	// 配置的群组同时通过实时更新采集
+	telegramService.WatchChats(phoneNumber, chatIDs)
 
 	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(map[string]string{"status": "configured"})
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":   "configured",
+		"chat_ids": chatIDs,
+		"chats":    resolved,
+	})
 }
 
 // handleStartCollection 处理开始采集请求
@@ -163,6 +207,79 @@ func handleStartCollection(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(map[string]string{"status": "collection_started"})
 }
 
+// handleStartEnrichment 处理刷新群组元数据请求，刷新在后台进行
+// @author fcj
+// @date 2023-11-15
+// @version 1.0.0
+// @param w http.ResponseWriter
+// @param r *http.Request
+func handleStartEnrichment(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	go func() {
+		if err := enrichmentService.EnrichAll(); err != nil {
+			log.Printf("Enrichment failed: %v", err)
+		}
+	}()
+
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]string{"status": "enrichment_started"})
+}
+
+// handleListSessions 列出所有已持久化的Telegram会话
+// @author fcj
+// @date 2023-11-15
+// @version 1.0.0
+// @param w http.ResponseWriter
+// @param r *http.Request
+func handleListSessions(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	sessions, err := telegramService.ListSessions()
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to list sessions: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{"sessions": sessions})
+}
+
+// handleDeleteSession 注销账号并删除其持久化会话，路径为 /sessions/{phone}
+// @author fcj
+// @date 2023-11-15
+// @version 1.0.0
+// @param w http.ResponseWriter
+// @param r *http.Request
+func handleDeleteSession(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	phoneNumber := strings.TrimPrefix(r.URL.Path, "/sessions/")
+	if err := service.ValidatePhoneNumber(phoneNumber); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := telegramService.Logout(phoneNumber); err != nil {
+		http.Error(w, fmt.Sprintf("Failed to log out: %v", err), http.StatusInternalServerError)
+		return
+	}
+	// 采集配置会话可能不存在（例如从未配置过群组），忽略该错误
+	sessionService.DeleteSession(phoneNumber)
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{"status": "logged_out"})
+}
+
 // handleHealth 处理健康检查请求
 // @author fcj
 // @date 2023-11-15
@@ -171,28 +288,108 @@ func handleStartCollection(w http.ResponseWriter, r *http.Request) {
 // @param r *http.Request
 func handleHealth(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(map[string]string{"status": "healthy"})
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":  "healthy",
+		"clients": telegramService.ClientHealth(),
+	})
 }
 
 // initServices 初始化服务
 // @author fcj
 // @date 2023-11-15
 // @version 1.0.0
+// @param ctx 服务上下文，取消时关闭所有Telegram连接
 // @return error 错误信息
-func initServices(cfg *config.Config) error {
+func initServices(ctx context.Context, cfg *config.Config) error {
 	// 初始化服务
 
-	storageService = service.NewStorageService(service.StorageConfig{PocketBaseURL: cfg.Storage.PocketBaseURL})
+	storageService = service.NewStorageService(service.StorageConfig{
+		PocketBaseURL:    cfg.Storage.PocketBaseURL,
+		PocketBaseToken:  cfg.Storage.PocketBaseToken,
+		MeilisearchURL:   cfg.Search.MeilisearchURL,
+		MeilisearchToken: cfg.Search.MeilisearchToken,
+		IndexName:        cfg.Search.IndexName,
+	})
+	// 索引可能已由机器人服务创建，失败时采集仍可启动，索引写入会在采集时报错
+	if err := storageService.ConfigureMessagesIndex(); err != nil {
+		log.Printf("Warning: Failed to configure Meilisearch messages index: %v", err)
+	}
 	sessionService = service.NewSessionService()
-	telegramService = service.NewTelegramService(service.TelegramConfig{
+
+	// 群组/频道的 access hash 缓存与会话使用同一存储后端
+	var sessionStore service.SessionStore
+	var peerStore service.PeerStore
+	var err error
+	switch cfg.Session.Backend {
+	case "", "file":
+		sessionStore, err = service.NewFileSessionStore(cfg.Session.Dir, cfg.Session.EncryptionKey)
+		if err == nil {
+			peerStore, err = service.NewFilePeerStore(cfg.Session.Dir)
+		}
+	case "pocketbase":
+		sessionStore, err = service.NewPocketBaseSessionStore(cfg.Storage.PocketBaseURL, cfg.Storage.PocketBaseToken, cfg.Session.EncryptionKey)
+		peerStore = service.NewPocketBasePeerStore(cfg.Storage.PocketBaseURL, cfg.Storage.PocketBaseToken)
+	default:
+		err = fmt.Errorf("unknown session backend: %s", cfg.Session.Backend)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to create session store: %w", err)
+	}
+
+	// 回填截止日期已在加载配置时校验
+	backfillUntil, _ := time.Parse(time.DateOnly, cfg.History.BackfillUntil)
+	cursorStore := service.NewPocketBaseCursorStore(cfg.Storage.PocketBaseURL, cfg.Storage.PocketBaseToken)
+
+	var mediaStore service.ObjectStore
+	if cfg.Media.Enabled {
+		mediaStore, err = service.NewS3ObjectStore(service.S3Config{
+			Endpoint:     cfg.Media.S3.Endpoint,
+			Region:       cfg.Media.S3.Region,
+			Bucket:       cfg.Media.S3.Bucket,
+			AccessKey:    cfg.Media.S3.AccessKey,
+			SecretKey:    cfg.Media.S3.SecretKey,
+			UsePathStyle: cfg.Media.S3.UsePathStyle,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to create media store: %w", err)
+		}
+	}
+
+	telegramService = service.NewTelegramService(ctx, service.TelegramConfig{
 		AppID:   cfg.Telegram.AppID,
 		AppHash: cfg.Telegram.AppHash,
-	}, storageService)
+		History: service.HistoryConfig{
+			PageSize:            cfg.History.PageSize,
+			BackfillPagesPerRun: cfg.History.BackfillPagesPerRun,
+			BackfillDepth:       cfg.History.BackfillDepth,
+			BackfillUntil:       backfillUntil,
+		},
+		Media: service.MediaConfig{
+			Enabled:       cfg.Media.Enabled,
+			MaxFileSize:   cfg.Media.MaxFileSize,
+			ThumbnailSize: cfg.Media.ThumbnailSize,
+		},
+	}, storageService, sessionStore, peerStore, cursorStore, mediaStore)
 	collectionService = service.NewCollectionService(service.SearchConfig{
-		MeilisearchURL:  cfg.Search.MeilisearchURL,
-		MeilisearchToken: cfg.Search.MeilisearchToken,
-		MessageLimit:    cfg.Search.MessageLimit,
+		MessageLimit: cfg.Search.MessageLimit,
 	}, telegramService, sessionService)
+	enrichmentService = service.NewEnrichmentService(service.EnrichmentConfig{
+		Interval:    time.Duration(cfg.Enrichment.IntervalMinutes) * time.Minute,
+		Delay:       time.Duration(cfg.Enrichment.DelaySeconds) * time.Second,
+		PhoneNumber: cfg.Enrichment.PhoneNumber,
+	}, telegramService, storageService)
+
+	// 恢复重启前已登录的账号，并为其创建采集配置会话
+	restored, err := telegramService.RestoreSessions()
+	if err != nil {
+		log.Printf("Warning: Failed to restore sessions: %v", err)
+	}
+	for _, phoneNumber := range restored {
+		if err := sessionService.CreateSession(phoneNumber); err != nil {
+			log.Printf("Failed to create session: %v", err)
+		}
+	}
+	log.Printf("Restored %d Telegram sessions", len(restored))
 
 	return nil
 }
@@ -214,8 +411,12 @@ func main() {
 
 
 
+	// 收到退出信号时关闭HTTP服务和所有Telegram连接
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
 	// 初始化服务
-	if err := initServices(cfg); err != nil {
+	if err := initServices(ctx, cfg); err != nil {
 		log.Fatalf("Failed to initialize services: %v", err)
 	}
 
@@ -223,19 +424,36 @@ func main() {
 	http.HandleFunc("/login", handleLogin)
 	http.HandleFunc("/configure", handleConfigure)
 	http.HandleFunc("/collect", handleStartCollection)
+	http.HandleFunc("/enrich", handleStartEnrichment)
+	http.HandleFunc("/sessions", handleListSessions)
+	http.HandleFunc("/sessions/", handleDeleteSession)
 	http.HandleFunc("/health", handleHealth)
 
 	// 启动定时采集
 	if err := collectionService.ScheduleCollection(5 * time.Minute); err != nil {
 		log.Printf("Warning: Failed to schedule collection: %v", err)
 	}
+	if err := enrichmentService.ScheduleEnrichment(); err != nil {
+		log.Printf("Warning: Failed to schedule enrichment: %v", err)
+	}
 
 	// 启动HTTP服务器
-	addr := fmt.Sprintf(":%s", cfg.Server.Port)
+	server := &http.Server{Addr: fmt.Sprintf(":%s", cfg.Server.Port)}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		server.Shutdown(shutdownCtx)
+	}()
+
 	log.Printf("Starting collection service on port %s", cfg.Server.Port)
-	if err := http.ListenAndServe(addr, nil); err != nil {
+	if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
 		log.Fatalf("Failed to start server: %v", err)
 	}
+
+	collectionService.StopCollection()
+	telegramService.Close()
+	log.Printf("Collection service stopped")
 }
 
 /*
@@ -0,0 +1,98 @@
+/*
+ * 文件功能描述：群组每日快照与增长分析，由元数据补全任务生成快照，并根据历史快照计算成员增长率和 7 天、30 天变化量
+ * 主要类/接口说明：newChatSnapshot生成快照、chatGrowthFields计算增长字段
+ * 修改历史记录：
+ * @author fcj
+ * @date 2023-11-15
+ * @version 1.0.0
+ * © Telegram Bot Services Team
+ */
+
+package service
+
+import (
+    "time"
+)
+
+const (
+    // snapshotDateLayout 快照日期格式
+    snapshotDateLayout = "2006-01-02"
+    // growthRateWindow 计算增长率和 7 天变化量使用的天数
+    growthRateWindow = 7
+    // snapshotHistoryDays 计算 30 天变化量使用的天数，也是读取历史快照的天数
+    snapshotHistoryDays = 30
+)
+
+// newChatSnapshot 根据元数据生成当天的快照，变化量相对今天之前最近的一个快照计算
+// @param chatID Bot API 格式的会话ID
+// @param metadata 群组元数据
+// @param now 当前时间
+// @param history 按日期升序排列的历史快照，可包含当天已写入的快照
+// @return ChatSnapshot 当天的快照
+func newChatSnapshot(chatID string, metadata *ChatMetadata, now time.Time, history []ChatSnapshot) ChatSnapshot {
+    snapshot := ChatSnapshot{
+        ChatID:        chatID,
+        Date:          now.UTC().Format(snapshotDateLayout),
+        MembersCount:  metadata.MembersCount,
+        MessageCount:  metadata.MessageCount,
+        OnlineCount:   metadata.OnlineCount,
+        ActiveMembers: metadata.ActiveMembers,
+    }
+    for i := len(history) - 1; i >= 0; i-- {
+        if history[i].Date < snapshot.Date {
+            snapshot.MembersDelta = snapshot.MembersCount - history[i].MembersCount
+            snapshot.MessagesDelta = snapshot.MessageCount - history[i].MessageCount
+            break
+        }
+    }
+    return snapshot
+}
+
+// chatGrowthFields 计算写入 telegram_index 的增长字段：
+// members_change_7d / members_change_30d 为相对窗口内最早快照的成员数变化，
+// growth_rate 为最近 7 天成员数的日均增长百分比；窗口内没有更早的快照时不写入
+// @param current 当天的快照
+// @param history 按日期升序排列的历史快照
+// @return map[string]interface{} 增长字段
+func chatGrowthFields(current ChatSnapshot, history []ChatSnapshot) map[string]interface{} {
+    fields := make(map[string]interface{})
+    if base, days, ok := baselineSnapshot(current, history, growthRateWindow); ok {
+        fields["members_change_7d"] = current.MembersCount - base.MembersCount
+        if base.MembersCount > 0 {
+            change := float64(current.MembersCount-base.MembersCount) / float64(base.MembersCount) * 100
+            fields["growth_rate"] = round2(change / float64(days))
+        }
+    }
+    if base, _, ok := baselineSnapshot(current, history, snapshotHistoryDays); ok {
+        fields["members_change_30d"] = current.MembersCount - base.MembersCount
+    }
+    return fields
+}
+
+// baselineSnapshot 返回 window 天窗口内（不含当天）最早的快照及其距今的天数；
+// 历史不足 window 天时使用已有的最早快照
+func baselineSnapshot(current ChatSnapshot, history []ChatSnapshot, window int) (ChatSnapshot, int, bool) {
+    today, err := time.Parse(snapshotDateLayout, current.Date)
+    if err != nil {
+        return ChatSnapshot{}, 0, false
+    }
+    start := today.AddDate(0, 0, -window).Format(snapshotDateLayout)
+    for _, snapshot := range history {
+        if snapshot.Date < start || snapshot.Date >= current.Date {
+            continue
+        }
+        date, err := time.Parse(snapshotDateLayout, snapshot.Date)
+        if err != nil {
+            continue
+        }
+        return snapshot, int(today.Sub(date).Hours() / 24), true
+    }
+    return ChatSnapshot{}, 0, false
+}
+
+/*
+ * 关键算法说明：
+ * 1. 快照：每个群组每天（UTC）一条记录，同一天多次刷新时覆盖为最新数据，变化量相对前一个快照
+ * 2. 变化量：7 天、30 天变化量取窗口内最早的快照作为基准，刚开始采集时窗口不完整，变化量覆盖的天数较少
+ * 3. 增长率：(当前成员数 - 基准成员数) / 基准成员数 / 间隔天数，单位为百分比/天
+ */
@@ -0,0 +1,52 @@
+package service
+
+import (
+    "testing"
+    "time"
+)
+
+func TestNewChatSnapshotDeltaSkipsToday(t *testing.T) {
+    now := time.Date(2024, 3, 10, 15, 0, 0, 0, time.UTC)
+    history := []ChatSnapshot{
+        {ChatID: "-100", Date: "2024-03-08", MembersCount: 900, MessageCount: 4000},
+        {ChatID: "-100", Date: "2024-03-09", MembersCount: 950, MessageCount: 4200},
+        {ChatID: "-100", Date: "2024-03-10", MembersCount: 990, MessageCount: 4300},
+    }
+    snapshot := newChatSnapshot("-100", &ChatMetadata{MembersCount: 1000, MessageCount: 4500}, now, history)
+    if snapshot.Date != "2024-03-10" {
+        t.Errorf("Date = %q, want 2024-03-10", snapshot.Date)
+    }
+    if snapshot.MembersDelta != 50 || snapshot.MessagesDelta != 300 {
+        t.Errorf("deltas = %d, %d; want 50, 300", snapshot.MembersDelta, snapshot.MessagesDelta)
+    }
+}
+
+func TestChatGrowthFields(t *testing.T) {
+    history := []ChatSnapshot{
+        {Date: "2024-02-01", MembersCount: 100}, // outside the 30-day window
+        {Date: "2024-02-20", MembersCount: 500},
+        {Date: "2024-03-05", MembersCount: 800},
+        {Date: "2024-03-09", MembersCount: 990},
+    }
+    current := ChatSnapshot{Date: "2024-03-10", MembersCount: 1000}
+
+    fields := chatGrowthFields(current, history)
+    if got := fields["members_change_7d"]; got != 200 {
+        t.Errorf("members_change_7d = %v, want 200", got)
+    }
+    if got := fields["members_change_30d"]; got != 500 {
+        t.Errorf("members_change_30d = %v, want 500", got)
+    }
+    // 800 -> 1000 over 5 days
+    if got := fields["growth_rate"]; got != 5.0 {
+        t.Errorf("growth_rate = %v, want 5", got)
+    }
+}
+
+func TestChatGrowthFieldsWithoutHistory(t *testing.T) {
+    current := ChatSnapshot{Date: "2024-03-10", MembersCount: 1000}
+    history := []ChatSnapshot{current}
+    if fields := chatGrowthFields(current, history); len(fields) != 0 {
+        t.Errorf("fields = %v, want none on the first snapshot", fields)
+    }
+}
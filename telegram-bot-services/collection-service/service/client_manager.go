@@ -0,0 +1,356 @@
+/*
+ * 文件功能描述：Telegram客户端连接管理，为每个账号维持一个长期运行的gotd客户端
+ * 主要类/接口说明：ClientManager接口及其实现、ClientHealth连接健康状态
+ * 修改历史记录：
+ * @author fcj
+ * @date 2023-11-15
+ * @version 1.0.0
+ * © Telegram Bot Services Team
+ */
+
+package service
+
+import (
+    "context"
+    "errors"
+    "fmt"
+    "log"
+    "sort"
+    "sync"
+    "time"
+
+    tdapi "github.com/gotd/td/telegram"
+    "github.com/gotd/td/tg"
+)
+
+const (
+    // clientReadyTimeout 等待连接就绪的最长时间
+    clientReadyTimeout = 30 * time.Second
+    // reconnectMinBackoff 首次重连前的等待时间
+    reconnectMinBackoff = time.Second
+    // reconnectMaxBackoff 重连等待时间上限
+    reconnectMaxBackoff = 5 * time.Minute
+)
+
+// ErrClientStopped 账号连接已停止
+var ErrClientStopped = errors.New("telegram client is stopped")
+
+// ClientStatus 账号连接状态
+type ClientStatus string
+
+const (
+    // ClientStatusConnecting 正在建立首次连接
+    ClientStatusConnecting ClientStatus = "connecting"
+    // ClientStatusConnected 已连接，可以调用API
+    ClientStatusConnected ClientStatus = "connected"
+    // ClientStatusReconnecting 连接断开，等待退避后重连
+    ClientStatusReconnecting ClientStatus = "reconnecting"
+    // ClientStatusStopped 已停止（注销或服务关闭）
+    ClientStatusStopped ClientStatus = "stopped"
+)
+
+// ClientHealth 账号连接的健康状态
+// @author fcj
+// @date 2023-11-15
+// @version 1.0.0
+type ClientHealth struct {
+    PhoneNumber string       `json:"phone_number"`
+    Status      ClientStatus `json:"status"`
+    ConnectedAt *time.Time   `json:"connected_at,omitempty"`
+    Reconnects  int          `json:"reconnects"`
+    LastError   string       `json:"last_error,omitempty"`
+}
+
+// ClientFactory 为账号创建新的gotd客户端；gotd客户端在Run返回后不能复用，每次重连都会重新创建
+type ClientFactory func(phoneNumber string) (*tdapi.Client, error)
+
+// ClientRunner 在每次连接就绪后运行的后台任务（如接收更新），返回错误时断开并重连；上下文在连接断开时取消
+type ClientRunner func(ctx context.Context, phoneNumber string, client *tdapi.Client) error
+
+// ClientManager 定义账号连接管理接口
+// @author fcj
+// @date 2023-11-15
+// @version 1.0.0
+type ClientManager interface {
+    // Start 启动账号的后台连接，已启动时不做任何操作
+    Start(phoneNumber string)
+
+    // Do 在账号的长连接上执行操作，连接未启动时自动启动并等待就绪
+    Do(ctx context.Context, phoneNumber string, fn func(ctx context.Context, client *tdapi.Client) error) error
+
+    // API 返回账号已就绪连接的 tg.Client，供采集器直接调用
+    API(ctx context.Context, phoneNumber string) (*tg.Client, error)
+
+    // Stop 停止账号的连接并等待其退出
+    Stop(phoneNumber string)
+
+    // PhoneNumbers 列出所有已启动的账号
+    PhoneNumbers() []string
+
+    // Health 返回所有账号的连接状态
+    Health() []ClientHealth
+
+    // Close 停止所有连接
+    Close()
+}
+
+// accountClient 单个账号的连接运行时
+type accountClient struct {
+    phoneNumber string
+    cancel      context.CancelFunc
+    done        chan struct{}
+
+    mutex       sync.RWMutex
+    client      *tdapi.Client
+    ready       chan struct{} // 连接就绪时关闭，断开后替换为新的通道
+    status      ClientStatus
+    connectedAt time.Time
+    reconnects  int
+    lastError   error
+}
+
+// clientManagerImpl 实现ClientManager接口
+// @author fcj
+// @date 2023-11-15
+// @version 1.0.0
+type clientManagerImpl struct {
+    ctx     context.Context
+    factory ClientFactory
+    runner  ClientRunner
+    mutex   sync.Mutex
+    clients map[string]*accountClient
+}
+
+// NewClientManager 创建账号连接管理器
+// @param ctx 父上下文，取消时所有连接随之关闭
+// @param factory 客户端工厂
+// @param runner 连接就绪后运行的后台任务，可为 nil
+// @return ClientManager 连接管理器实例
+func NewClientManager(ctx context.Context, factory ClientFactory, runner ClientRunner) ClientManager {
+    return &clientManagerImpl{
+        ctx:     ctx,
+        factory: factory,
+        runner:  runner,
+        clients: make(map[string]*accountClient),
+    }
+}
+
+// Start 启动账号的后台连接
+func (m *clientManagerImpl) Start(phoneNumber string) {
+    m.account(phoneNumber)
+}
+
+// account 获取账号运行时，不存在时创建并启动后台连接循环
+func (m *clientManagerImpl) account(phoneNumber string) *accountClient {
+    m.mutex.Lock()
+    defer m.mutex.Unlock()
+
+    if a, exists := m.clients[phoneNumber]; exists {
+        return a
+    }
+    ctx, cancel := context.WithCancel(m.ctx)
+    a := &accountClient{
+        phoneNumber: phoneNumber,
+        cancel:      cancel,
+        done:        make(chan struct{}),
+        ready:       make(chan struct{}),
+        status:      ClientStatusConnecting,
+    }
+    m.clients[phoneNumber] = a
+    go a.run(ctx, m.factory, m.runner)
+    return a
+}
+
+// Do 在账号的长连接上执行操作
+func (m *clientManagerImpl) Do(ctx context.Context, phoneNumber string, fn func(ctx context.Context, client *tdapi.Client) error) error {
+    client, err := m.account(phoneNumber).waitReady(ctx)
+    if err != nil {
+        return err
+    }
+    return fn(ctx, client)
+}
+
+// API 返回账号已就绪连接的 tg.Client
+func (m *clientManagerImpl) API(ctx context.Context, phoneNumber string) (*tg.Client, error) {
+    client, err := m.account(phoneNumber).waitReady(ctx)
+    if err != nil {
+        return nil, err
+    }
+    return client.API(), nil
+}
+
+// Stop 停止账号的连接
+func (m *clientManagerImpl) Stop(phoneNumber string) {
+    m.mutex.Lock()
+    a, exists := m.clients[phoneNumber]
+    delete(m.clients, phoneNumber)
+    m.mutex.Unlock()
+
+    if exists {
+        a.cancel()
+        <-a.done
+    }
+}
+
+// PhoneNumbers 列出所有已启动的账号
+func (m *clientManagerImpl) PhoneNumbers() []string {
+    m.mutex.Lock()
+    defer m.mutex.Unlock()
+
+    phoneNumbers := make([]string, 0, len(m.clients))
+    for phoneNumber := range m.clients {
+        phoneNumbers = append(phoneNumbers, phoneNumber)
+    }
+    sort.Strings(phoneNumbers)
+    return phoneNumbers
+}
+
+// Health 返回所有账号的连接状态
+func (m *clientManagerImpl) Health() []ClientHealth {
+    m.mutex.Lock()
+    accounts := make([]*accountClient, 0, len(m.clients))
+    for _, a := range m.clients {
+        accounts = append(accounts, a)
+    }
+    m.mutex.Unlock()
+
+    health := make([]ClientHealth, 0, len(accounts))
+    for _, a := range accounts {
+        health = append(health, a.health())
+    }
+    sort.Slice(health, func(i, j int) bool { return health[i].PhoneNumber < health[j].PhoneNumber })
+    return health
+}
+
+// Close 停止所有连接
+func (m *clientManagerImpl) Close() {
+    for _, phoneNumber := range m.PhoneNumbers() {
+        m.Stop(phoneNumber)
+    }
+}
+
+// run 后台连接循环：保持连接直到上下文取消，断开后按指数退避重连
+func (a *accountClient) run(ctx context.Context, factory ClientFactory, runner ClientRunner) {
+    defer close(a.done)
+    defer a.setStatus(ClientStatusStopped, nil)
+
+    backoff := reconnectMinBackoff
+    for {
+        client, err := factory(a.phoneNumber)
+        if err == nil {
+            err = client.Run(ctx, func(runCtx context.Context) error {
+                a.setConnected(client)
+                backoff = reconnectMinBackoff
+                if runner != nil {
+                    if err := runner(runCtx, a.phoneNumber, client); err != nil && runCtx.Err() == nil {
+                        return err
+                    }
+                }
+                <-runCtx.Done()
+                return runCtx.Err()
+            })
+        }
+        if ctx.Err() != nil {
+            return
+        }
+
+        a.setStatus(ClientStatusReconnecting, err)
+        log.Printf("Telegram client for phone %s disconnected, reconnecting in %s: %v", a.phoneNumber, backoff, err)
+        select {
+        case <-ctx.Done():
+            return
+        case <-time.After(backoff):
+        }
+        backoff *= 2
+        if backoff > reconnectMaxBackoff {
+            backoff = reconnectMaxBackoff
+        }
+    }
+}
+
+// setConnected 标记连接就绪
+func (a *accountClient) setConnected(client *tdapi.Client) {
+    a.mutex.Lock()
+    defer a.mutex.Unlock()
+
+    if a.status == ClientStatusReconnecting {
+        a.reconnects++
+    }
+    a.client = client
+    a.status = ClientStatusConnected
+    a.connectedAt = time.Now()
+    a.lastError = nil
+    close(a.ready)
+    log.Printf("Telegram client for phone %s connected", a.phoneNumber)
+}
+
+// setStatus 标记连接断开或停止
+func (a *accountClient) setStatus(status ClientStatus, err error) {
+    a.mutex.Lock()
+    defer a.mutex.Unlock()
+
+    if a.status == ClientStatusConnected {
+        a.ready = make(chan struct{})
+    }
+    a.client = nil
+    a.status = status
+    if err != nil {
+        a.lastError = err
+    }
+}
+
+// waitReady 等待连接就绪并返回当前客户端
+func (a *accountClient) waitReady(ctx context.Context) (*tdapi.Client, error) {
+    ctx, cancel := context.WithTimeout(ctx, clientReadyTimeout)
+    defer cancel()
+
+    for {
+        a.mutex.RLock()
+        client, ready, status, lastError := a.client, a.ready, a.status, a.lastError
+        a.mutex.RUnlock()
+
+        switch status {
+        case ClientStatusConnected:
+            return client, nil
+        case ClientStatusStopped:
+            return nil, fmt.Errorf("%w for phone %s", ErrClientStopped, a.phoneNumber)
+        }
+        select {
+        case <-ready:
+        case <-a.done:
+        case <-ctx.Done():
+            if lastError != nil {
+                return nil, fmt.Errorf("telegram client for phone %s is not ready (%s): %w", a.phoneNumber, status, lastError)
+            }
+            return nil, fmt.Errorf("telegram client for phone %s is not ready (%s): %w", a.phoneNumber, status, ctx.Err())
+        }
+    }
+}
+
+// health 返回连接状态快照
+func (a *accountClient) health() ClientHealth {
+    a.mutex.RLock()
+    defer a.mutex.RUnlock()
+
+    h := ClientHealth{
+        PhoneNumber: a.phoneNumber,
+        Status:      a.status,
+        Reconnects:  a.reconnects,
+    }
+    if a.status == ClientStatusConnected {
+        connectedAt := a.connectedAt
+        h.ConnectedAt = &connectedAt
+    }
+    if a.lastError != nil {
+        h.LastError = a.lastError.Error()
+    }
+    return h
+}
+
+/*
+ * 关键算法说明：
+ * 1. 长连接：每个账号一个后台协程运行 client.Run，回调中运行 ClientRunner（如接收更新）后阻塞到上下文取消，API调用复用该连接
+ * 2. 就绪等待：ready 通道在连接就绪时关闭、断开时重建，调用方等待就绪或超时
+ * 3. 断线重连：Run 返回后按 1s 起指数退避重连（上限 5 分钟），连接成功后退避重置；gotd 在单次 Run 内还会自动重连网络
+ * 4. 关闭：取消账号上下文即结束 Run，Stop 等待后台协程退出
+ */
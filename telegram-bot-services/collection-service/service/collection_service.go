@@ -21,9 +21,7 @@ import (
 // @date 2023-11-15
 // @version 1.0.0
 type SearchConfig struct {
-    MeilisearchURL  string `json:"meilisearch_url"`
-    MeilisearchToken string `json:"meilisearch_token"`
-    MessageLimit     int    `json:"message_limit"`
+    MessageLimit int `json:"message_limit"`
 }
 
 // CollectionService 定义消息采集服务接口
@@ -210,7 +208,6 @@ func (c *collectionServiceImpl) StopCollection() error {
  * 待优化事项：
  * 1. 负载均衡：实现多会话负载均衡采集
  * 2. 错误重试：添加采集失败重试机制
- * 3. 增量采集：实现增量采集以减少重复数据
  * 
  * 兼容性说明：
  * 1. 依赖TelegramService和SessionService
@@ -0,0 +1,332 @@
+/*
+ * 文件功能描述：群组元数据补全任务，定时通过MTProto刷新 telegram_index 中收录的群组和频道的统计字段和评分
+ * 主要类/接口说明：EnrichmentConfig任务配置、EnrichmentService接口及其实现、评分计算
+ * 修改历史记录：
+ * @author fcj
+ * @date 2023-11-15
+ * @version 1.0.0
+ * © Telegram Bot Services Team
+ */
+
+package service
+
+import (
+    "fmt"
+    "log"
+    "math"
+    "strconv"
+    "time"
+
+    "github.com/gotd/td/tgerr"
+)
+
+const (
+    // DefaultEnrichmentInterval 默认刷新间隔
+    DefaultEnrichmentInterval = 6 * time.Hour
+    // DefaultEnrichmentDelay 相邻两个群组之间的默认间隔，避免触发 FLOOD_WAIT
+    DefaultEnrichmentDelay = 3 * time.Second
+)
+
+// EnrichmentConfig 元数据补全配置
+// @author fcj
+// @date 2023-11-15
+// @version 1.0.0
+type EnrichmentConfig struct {
+    // Interval 刷新间隔
+    Interval time.Duration
+    // Delay 相邻两个群组之间的间隔
+    Delay time.Duration
+    // PhoneNumber 用于查询的账号，为空时使用任意已连接的账号
+    PhoneNumber string
+}
+
+// EnrichmentService 定义群组元数据补全服务接口
+// @author fcj
+// @date 2023-11-15
+// @version 1.0.0
+type EnrichmentService interface {
+    // EnrichAll 刷新所有收录的群组和频道，上次刷新最早的优先
+    EnrichAll() error
+
+    // ScheduleEnrichment 按配置的间隔定时刷新
+    ScheduleEnrichment() error
+
+    // StopEnrichment 停止定时刷新
+    StopEnrichment() error
+}
+
+// enrichmentServiceImpl 实现EnrichmentService接口
+// @author fcj
+// @date 2023-11-15
+// @version 1.0.0
+type enrichmentServiceImpl struct {
+    config          EnrichmentConfig
+    telegramService TelegramService
+    storageService  StorageService
+    ticker          *time.Ticker
+    stopChan        chan struct{}
+    isRunning       bool
+}
+
+// NewEnrichmentService 创建群组元数据补全服务
+// @author fcj
+// @date 2023-11-15
+// @version 1.0.0
+// @param config 补全配置
+// @param telegramService Telegram客户端服务
+// @param storageService 存储服务
+// @return EnrichmentService 元数据补全服务
+func NewEnrichmentService(config EnrichmentConfig, telegramService TelegramService, storageService StorageService) EnrichmentService {
+    if config.Interval <= 0 {
+        config.Interval = DefaultEnrichmentInterval
+    }
+    if config.Delay <= 0 {
+        config.Delay = DefaultEnrichmentDelay
+    }
+    return &enrichmentServiceImpl{
+        config:          config,
+        telegramService: telegramService,
+        storageService:  storageService,
+        stopChan:        make(chan struct{}),
+    }
+}
+
+// EnrichAll 刷新所有收录的群组和频道
+// @author fcj
+// @date 2023-11-15
+// @version 1.0.0
+// @return error 错误信息
+func (e *enrichmentServiceImpl) EnrichAll() error {
+    phoneNumber, err := e.phoneNumber()
+    if err != nil {
+        return err
+    }
+    chats, err := e.storageService.ListIndexedChats()
+    if err != nil {
+        return fmt.Errorf("failed to list indexed chats: %w", err)
+    }
+
+    log.Printf("Enriching %d indexed chats using phone %s", len(chats), phoneNumber)
+    enriched := 0
+    for i, chat := range chats {
+        if i > 0 {
+            time.Sleep(e.config.Delay)
+        }
+        if err := e.enrich(phoneNumber, chat); err != nil {
+            log.Printf("Failed to enrich chat %s (%s): %v", chat.ChatID, chat.Username, err)
+            // 触发限流时等待要求的时间后继续
+            if wait, ok := tgerr.AsFloodWait(err); ok {
+                log.Printf("Flood wait %v while enriching chats", wait)
+                time.Sleep(wait)
+            }
+            continue
+        }
+        enriched++
+    }
+    log.Printf("Enriched %d/%d indexed chats", enriched, len(chats))
+    return nil
+}
+
+// enrich 刷新单个群组：获取元数据、写入当天快照、计算增长和评分，写入 PocketBase 和 Meilisearch
+func (e *enrichmentServiceImpl) enrich(phoneNumber string, chat IndexedChat) error {
+    // 公开群组按用户名解析，无需账号加入；私有群组要求账号已在群组中
+    target := chat.Username
+    if target == "" {
+        target = chat.ChatID
+    }
+    if target == "" {
+        return fmt.Errorf("record %s has neither username nor chat_id", chat.RecordID)
+    }
+    metadata, err := e.telegramService.FetchChatMetadata(phoneNumber, target)
+    if err != nil {
+        return err
+    }
+
+    now := time.Now().UTC()
+    fields := chatMetadataFields(metadata, now)
+    if chat.ChatID == "" {
+        chat.ChatID = strconv.FormatInt(metadata.ChatID, 10)
+        fields["chat_id"] = chat.ChatID
+    }
+
+    since := now.AddDate(0, 0, -snapshotHistoryDays).Format(snapshotDateLayout)
+    history, err := e.storageService.ListChatSnapshots(chat.ChatID, since)
+    if err != nil {
+        return fmt.Errorf("failed to list chat snapshots: %w", err)
+    }
+    snapshot := newChatSnapshot(chat.ChatID, metadata, now, history)
+    if err := e.storageService.SaveChatSnapshot(snapshot); err != nil {
+        return fmt.Errorf("failed to save chat snapshot: %w", err)
+    }
+    for name, value := range chatGrowthFields(snapshot, history) {
+        fields[name] = value
+    }
+    for name, score := range chatScores(metadata, now) {
+        fields[name] = score
+    }
+    return e.storageService.UpdateIndexedChat(chat, fields)
+}
+
+// phoneNumber 返回用于查询的账号
+func (e *enrichmentServiceImpl) phoneNumber() (string, error) {
+    if e.config.PhoneNumber != "" {
+        return e.config.PhoneNumber, nil
+    }
+    for _, health := range e.telegramService.ClientHealth() {
+        if health.Status == ClientStatusConnected {
+            return health.PhoneNumber, nil
+        }
+    }
+    return "", fmt.Errorf("no connected account available for enrichment")
+}
+
+// ScheduleEnrichment 按配置的间隔定时刷新
+// @author fcj
+// @date 2023-11-15
+// @version 1.0.0
+// @return error 错误信息
+func (e *enrichmentServiceImpl) ScheduleEnrichment() error {
+    if e.isRunning {
+        return fmt.Errorf("enrichment is already scheduled")
+    }
+
+    e.ticker = time.NewTicker(e.config.Interval)
+    e.isRunning = true
+
+    go func() {
+        for {
+            select {
+            case <-e.ticker.C:
+                if err := e.EnrichAll(); err != nil {
+                    log.Printf("Scheduled enrichment failed: %v", err)
+                }
+            case <-e.stopChan:
+                e.ticker.Stop()
+                e.isRunning = false
+                return
+            }
+        }
+    }()
+
+    log.Printf("Scheduled chat enrichment every %v", e.config.Interval)
+    return nil
+}
+
+// StopEnrichment 停止定时刷新
+// @author fcj
+// @date 2023-11-15
+// @version 1.0.0
+// @return error 错误信息
+func (e *enrichmentServiceImpl) StopEnrichment() error {
+    if !e.isRunning {
+        return fmt.Errorf("enrichment is not running")
+    }
+    e.stopChan <- struct{}{}
+    log.Printf("Stopped scheduled enrichment")
+    return nil
+}
+
+// chatMetadataFields 将元数据转换为 telegram_index 的字段；无法获取的字段不写入，保留原值
+func chatMetadataFields(metadata *ChatMetadata, now time.Time) map[string]interface{} {
+    fields := map[string]interface{}{
+        "type":                  metadata.Type,
+        "title":                 metadata.Title,
+        "description":           metadata.About,
+        "members_count":         metadata.MembersCount,
+        "online_count":          metadata.OnlineCount,
+        "active_members":        metadata.ActiveMembers,
+        "message_count":         metadata.MessageCount,
+        "avg_message_per_day":   avgMessagesPerDay(metadata, now),
+        "is_verified":           metadata.IsVerified,
+        "is_restricted":         metadata.IsRestricted,
+        "is_scam":               metadata.IsScam,
+        "is_fake":               metadata.IsFake,
+        "has_protected_content": metadata.HasProtectedContent,
+        "can_set_sticker_set":   metadata.CanSetStickerSet,
+        "slow_mode_delay":       metadata.SlowModeDelay,
+        "linked_chat_id":        "",
+        "last_calculated":       now.Format(time.RFC3339),
+        "updated_at":            now.Format(time.RFC3339),
+    }
+    if metadata.Username != "" {
+        fields["username"] = metadata.Username
+    }
+    if metadata.InviteLink != "" {
+        fields["invite_link"] = metadata.InviteLink
+    }
+    if metadata.LinkedChatID != 0 {
+        fields["linked_chat_id"] = strconv.FormatInt(metadata.LinkedChatID, 10)
+    }
+    if !metadata.LastActivity.IsZero() {
+        fields["last_activity"] = metadata.LastActivity.UTC().Format(time.RFC3339)
+    }
+    if metadata.CreatorID != 0 {
+        fields["creator_id"] = strconv.FormatInt(metadata.CreatorID, 10)
+    }
+    if metadata.AdminIDs != nil {
+        adminList := make([]string, 0, len(metadata.AdminIDs))
+        for _, id := range metadata.AdminIDs {
+            adminList = append(adminList, strconv.FormatInt(id, 10))
+        }
+        fields["admin_list"] = adminList
+    }
+    return fields
+}
+
+// avgMessagesPerDay 群组创建以来平均每天的消息数
+func avgMessagesPerDay(metadata *ChatMetadata, now time.Time) float64 {
+    if metadata.CreatedAt.IsZero() {
+        return 0
+    }
+    days := math.Max(now.Sub(metadata.CreatedAt).Hours()/24, 1)
+    return round2(float64(metadata.MessageCount) / days)
+}
+
+// chatScores 计算 0-10 的评分
+// popularity：成员数的对数，10 万成员为满分
+// activity：日均消息数的对数，1 万条为满分
+// authority：认证、存在时间、公开用户名和成员规模，诈骗或仿冒为 0
+// overall：按 telegram_index 设计的权重（流行度 0.3、活跃度 0.25、权威性 0.1）加权平均，
+// 内容和相关性评分暂不计算，权重按已计算的评分归一化
+func chatScores(metadata *ChatMetadata, now time.Time) map[string]float64 {
+    popularity := math.Min(10, 2*math.Log10(float64(metadata.MembersCount)+1))
+    activity := math.Min(10, 2.5*math.Log10(avgMessagesPerDay(metadata, now)+1))
+
+    authority := 0.0
+    if !metadata.IsScam && !metadata.IsFake {
+        if metadata.IsVerified {
+            authority += 4
+        }
+        if !metadata.CreatedAt.IsZero() {
+            authority += math.Min(3, now.Sub(metadata.CreatedAt).Hours()/24/365)
+        }
+        if metadata.Username != "" {
+            authority++
+        }
+        authority += math.Min(2, math.Log10(float64(metadata.MembersCount)+1)/2.5)
+        authority = math.Min(10, authority)
+    }
+
+    return map[string]float64{
+        "popularity_score": round2(popularity),
+        "activity_score":   round2(activity),
+        "authority_score":  round2(authority),
+        "overall_score":    round2((0.3*popularity + 0.25*activity + 0.1*authority) / 0.65),
+    }
+}
+
+// round2 保留两位小数
+func round2(value float64) float64 {
+    return math.Round(value*100) / 100
+}
+
+/*
+ * 关键算法说明：
+ * 1. 刷新顺序：按 last_calculated 升序处理，未刷新过的群组优先；相邻群组之间间隔 Delay，遇到 FLOOD_WAIT 时等待要求的时间
+ * 2. 写入：PocketBase 记录使用 PATCH，Meilisearch 文档使用 PUT（部分更新），不会覆盖机器人服务写入的其他字段
+ * 3. 增长：每次刷新写入 chat_stats_daily 当天的快照，growth_rate 和 members_change_7d/30d 由历史快照计算（见 chat_stats.go）
+ *
+ * 待优化事项：
+ * 1. 内容评分：content_score、relevance_score 需要内容分析，暂不计算
+ * 2. 多账号：目前使用单个账号查询，大量群组时可按账号分摊
+ */
@@ -0,0 +1,174 @@
+/*
+ * 文件功能描述：实时消息的索引批处理，将逐条到达的索引和删除操作合并后按批提交到 Meilisearch
+ * 主要类/接口说明：indexBatcher按数量或时间间隔提交，保持操作顺序
+ * 修改历史记录：
+ * @author fcj
+ * @date 2023-11-15
+ * @version 1.0.0
+ * © Telegram Bot Services Team
+ */
+
+package service
+
+import (
+    "log"
+    "sync"
+    "time"
+
+    "telegram-bot-services/pkg/search"
+)
+
+const (
+    // indexFlushSize 累积的操作数达到此值时立即提交
+    indexFlushSize = 100
+    // indexFlushInterval 未达到批量大小时的最长等待时间
+    indexFlushInterval = 2 * time.Second
+    // indexMaxRetries 每次提交失败后的重试次数
+    indexMaxRetries = 3
+    // indexRetryBackoff 首次重试前的等待时间，之后每次翻倍
+    indexRetryBackoff = 500 * time.Millisecond
+)
+
+// indexOp 单个索引操作，doc 与 deleteIDs 二选一
+type indexOp struct {
+    doc       *search.Message
+    deleteIDs []string
+}
+
+// indexBatcher 合并实时更新产生的索引操作；同一条消息的编辑和删除按到达顺序提交
+// @author fcj
+// @date 2023-11-15
+// @version 1.0.0
+type indexBatcher struct {
+    storage StorageService
+    backoff time.Duration
+    mu      sync.Mutex
+    pending []indexOp
+    flush   chan struct{}
+    done    chan struct{}
+    stopped chan struct{}
+    once    sync.Once
+}
+
+// newIndexBatcher 创建索引批处理器并启动后台提交协程
+// @param storage 存储服务
+// @return *indexBatcher 索引批处理器
+func newIndexBatcher(storage StorageService) *indexBatcher {
+    b := &indexBatcher{
+        storage: storage,
+        backoff: indexRetryBackoff,
+        flush:   make(chan struct{}, 1),
+        done:    make(chan struct{}),
+        stopped: make(chan struct{}),
+    }
+    go b.run()
+    return b
+}
+
+// Add 加入待索引的文档
+func (b *indexBatcher) Add(doc search.Message) {
+    b.enqueue(indexOp{doc: &doc})
+}
+
+// Delete 加入待删除的文档ID
+func (b *indexBatcher) Delete(ids []string) {
+    if len(ids) == 0 {
+        return
+    }
+    b.enqueue(indexOp{deleteIDs: ids})
+}
+
+// enqueue 加入操作，达到批量大小时通知后台协程提交
+func (b *indexBatcher) enqueue(op indexOp) {
+    b.mu.Lock()
+    b.pending = append(b.pending, op)
+    full := len(b.pending) >= indexFlushSize
+    b.mu.Unlock()
+    if full {
+        select {
+        case b.flush <- struct{}{}:
+        default:
+        }
+    }
+}
+
+// run 按数量或时间间隔提交，关闭时提交剩余的操作
+func (b *indexBatcher) run() {
+    defer close(b.stopped)
+    ticker := time.NewTicker(indexFlushInterval)
+    defer ticker.Stop()
+    for {
+        select {
+        case <-b.done:
+            b.commit()
+            return
+        case <-b.flush:
+            b.commit()
+        case <-ticker.C:
+            b.commit()
+        }
+    }
+}
+
+// commit 将连续的同类操作合并为一次请求，按顺序提交；失败的请求重试后才提交后面的操作
+func (b *indexBatcher) commit() {
+    b.mu.Lock()
+    ops := b.pending
+    b.pending = nil
+    b.mu.Unlock()
+
+    for start := 0; start < len(ops); {
+        end := start
+        if ops[start].doc != nil {
+            var docs []search.Message
+            for ; end < len(ops) && ops[end].doc != nil; end++ {
+                docs = append(docs, *ops[end].doc)
+            }
+            if err := b.retry(func() error { return b.storage.IndexMessages(docs) }); err != nil {
+                log.Printf("Failed to index %d messages, dropping them: %v", len(docs), err)
+            }
+        } else {
+            var ids []string
+            for ; end < len(ops) && ops[end].doc == nil; end++ {
+                ids = append(ids, ops[end].deleteIDs...)
+            }
+            if err := b.retry(func() error { return b.storage.DeleteIndexedMessages(ids) }); err != nil {
+                log.Printf("Failed to delete %d messages from index, dropping them: %v", len(ids), err)
+            }
+        }
+        start = end
+    }
+}
+
+// retry 执行提交，失败时按指数退避重试；等待期间到达的操作留在队列中，下次提交
+func (b *indexBatcher) retry(op func() error) error {
+    backoff := b.backoff
+    var err error
+    for attempt := 0; attempt <= indexMaxRetries; attempt++ {
+        if attempt > 0 {
+            log.Printf("Index commit failed, retrying in %s (attempt %d/%d): %v", backoff, attempt, indexMaxRetries, err)
+            time.Sleep(backoff)
+            backoff *= 2
+        }
+        if err = op(); err == nil {
+            return nil
+        }
+    }
+    return err
+}
+
+// Close 提交剩余的操作并停止后台协程
+func (b *indexBatcher) Close() {
+    b.once.Do(func() { close(b.done) })
+    <-b.stopped
+}
+
+/*
+ * 关键算法说明：
+ * 1. 批量提交：操作数达到 100 或每隔 2 秒提交一次，减少 Meilisearch 任务数量
+ * 2. 顺序保证：连续的索引或删除操作合并为一个请求，不同类型的操作按到达顺序依次提交，编辑后又删除的消息不会被重新索引
+ * 3. 失败重试：提交失败时按指数退避重试 3 次，重试期间暂停后续提交以保持顺序
+ *
+ * 待优化事项：
+ * 1. 重试耗尽的操作只记录日志后丢弃，定时采集不会重新索引已采集过的消息；消息已保存在 PocketBase，可运行管理服务的 reindex-messages 重建索引
+ */
@@ -0,0 +1,78 @@
+package service
+
+import (
+    "errors"
+    "reflect"
+    "testing"
+    "time"
+
+    "telegram-bot-services/pkg/search"
+)
+
+// fakeIndexStorage 记录索引请求，前 failures 次请求返回错误
+type fakeIndexStorage struct {
+    StorageService
+    failures int
+    calls    []string
+}
+
+func (f *fakeIndexStorage) result(call string) error {
+    f.calls = append(f.calls, call)
+    if f.failures > 0 {
+        f.failures--
+        return errors.New("meilisearch unavailable")
+    }
+    return nil
+}
+
+func (f *fakeIndexStorage) IndexMessages(docs []search.Message) error {
+    call := "index"
+    for _, doc := range docs {
+        call += " " + doc.ID
+    }
+    return f.result(call)
+}
+
+func (f *fakeIndexStorage) DeleteIndexedMessages(ids []string) error {
+    call := "delete"
+    for _, id := range ids {
+        call += " " + id
+    }
+    return f.result(call)
+}
+
+func newTestBatcher(storage StorageService) *indexBatcher {
+    return &indexBatcher{storage: storage, backoff: time.Millisecond}
+}
+
+func TestIndexBatcherRetriesInOrder(t *testing.T) {
+    storage := &fakeIndexStorage{failures: 2}
+    b := newTestBatcher(storage)
+    b.Add(search.Message{ID: "-100_1"})
+    b.Add(search.Message{ID: "-100_2"})
+    b.Delete([]string{"-100_1"})
+    b.commit()
+
+    want := []string{"index -100_1 -100_2", "index -100_1 -100_2", "index -100_1 -100_2", "delete -100_1"}
+    if !reflect.DeepEqual(storage.calls, want) {
+        t.Errorf("calls = %q, want %q", storage.calls, want)
+    }
+}
+
+func TestIndexBatcherDropsAfterRetries(t *testing.T) {
+    storage := &fakeIndexStorage{failures: indexMaxRetries + 1}
+    b := newTestBatcher(storage)
+    b.Delete([]string{"-100_1"})
+    b.Add(search.Message{ID: "-100_2"})
+    b.commit()
+
+    if len(storage.calls) != indexMaxRetries+2 {
+        t.Fatalf("got %d calls, want %d", len(storage.calls), indexMaxRetries+2)
+    }
+    if last := storage.calls[len(storage.calls)-1]; last != "index -100_2" {
+        t.Errorf("last call = %q, want the following index request", last)
+    }
+    if len(b.pending) != 0 {
+        t.Errorf("pending = %d ops, want 0", len(b.pending))
+    }
+}
@@ -0,0 +1,194 @@
+package service
+
+import (
+    "bytes"
+    "context"
+    "encoding/binary"
+    "errors"
+    "hash/crc32"
+    "image"
+    "image/color"
+    "image/jpeg"
+    "image/png"
+    "io"
+    "net/http"
+    "net/http/httptest"
+    "strings"
+    "sync"
+    "testing"
+)
+
+// fakeS3 S3 兼容存储的最小替身：支持 PutObject 和 HeadObject，并校验签名相关的请求头
+type fakeS3 struct {
+    mu      sync.Mutex
+    objects map[string][]byte
+    puts    int
+}
+
+func newFakeS3(t *testing.T) (*fakeS3, *httptest.Server) {
+    fake := &fakeS3{objects: map[string][]byte{}}
+    server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        auth := r.Header.Get("Authorization")
+        if !strings.HasPrefix(auth, "AWS4-HMAC-SHA256 Credential=test-access/") ||
+            !strings.Contains(auth, "/us-east-1/s3/aws4_request, SignedHeaders=host;x-amz-content-sha256;x-amz-date, Signature=") {
+            t.Errorf("unexpected Authorization header: %q", auth)
+            w.WriteHeader(http.StatusForbidden)
+            return
+        }
+        fake.mu.Lock()
+        defer fake.mu.Unlock()
+        switch r.Method {
+        case http.MethodPut:
+            body, _ := io.ReadAll(r.Body)
+            if got := r.Header.Get("X-Amz-Content-Sha256"); got != sha256Hex(body) {
+                t.Errorf("payload hash %q does not match body", got)
+            }
+            fake.objects[r.URL.Path] = body
+            fake.puts++
+        case http.MethodHead:
+            if _, ok := fake.objects[r.URL.Path]; !ok {
+                w.WriteHeader(http.StatusNotFound)
+            }
+        default:
+            w.WriteHeader(http.StatusMethodNotAllowed)
+        }
+    }))
+    t.Cleanup(server.Close)
+    return fake, server
+}
+
+func newTestObjectStore(t *testing.T, endpoint string) ObjectStore {
+    store, err := NewS3ObjectStore(S3Config{
+        Endpoint:     endpoint,
+        Bucket:       "media",
+        AccessKey:    "test-access",
+        SecretKey:    "test-secret",
+        UsePathStyle: true,
+    })
+    if err != nil {
+        t.Fatalf("NewS3ObjectStore: %v", err)
+    }
+    return store
+}
+
+func TestS3ObjectStorePutAndExists(t *testing.T) {
+    fake, server := newFakeS3(t)
+    store := newTestObjectStore(t, server.URL)
+    ctx := context.Background()
+
+    exists, err := store.Exists(ctx, "media/ab/abc.jpg")
+    if err != nil || exists {
+        t.Fatalf("Exists before Put = %v, %v; want false, nil", exists, err)
+    }
+    if err := store.Put(ctx, "media/ab/abc.jpg", "image/jpeg", []byte("data")); err != nil {
+        t.Fatalf("Put: %v", err)
+    }
+    exists, err = store.Exists(ctx, "media/ab/abc.jpg")
+    if err != nil || !exists {
+        t.Fatalf("Exists after Put = %v, %v; want true, nil", exists, err)
+    }
+    if got := string(fake.objects["/media/media/ab/abc.jpg"]); got != "data" {
+        t.Errorf("stored object = %q, want %q", got, "data")
+    }
+}
+
+func TestMediaArchiverPutOnceDeduplicates(t *testing.T) {
+    fake, server := newFakeS3(t)
+    archiver := newMediaArchiver(MediaConfig{Enabled: true}, newTestObjectStore(t, server.URL))
+    ctx := context.Background()
+
+    data := []byte("same file forwarded twice")
+    key := mediaObjectKey(sha256Hex(data), "report.PDF", "application/pdf")
+    for i := 0; i < 2; i++ {
+        if err := archiver.putOnce(ctx, key, "application/pdf", data); err != nil {
+            t.Fatalf("putOnce: %v", err)
+        }
+    }
+    if fake.puts != 1 {
+        t.Errorf("puts = %d, want 1", fake.puts)
+    }
+    if want := "media/" + sha256Hex(data)[:2] + "/" + sha256Hex(data) + ".pdf"; key != want {
+        t.Errorf("key = %q, want %q", key, want)
+    }
+}
+
+func TestMediaObjectKeyExtension(t *testing.T) {
+    hash := sha256Hex([]byte("x"))
+    tests := []struct {
+        fileName string
+        mimeType string
+        want     string
+    }{
+        {"", "image/jpeg", ".jpg"},
+        {"clip.MP4", "video/mp4", ".mp4"},
+        {"", "application/x-unknown-type", ""},
+    }
+    for _, tt := range tests {
+        if got := mediaObjectKey(hash, tt.fileName, tt.mimeType); got != "media/"+hash[:2]+"/"+hash+tt.want {
+            t.Errorf("mediaObjectKey(%q, %q) = %q", tt.fileName, tt.mimeType, got)
+        }
+    }
+}
+
+func TestMakeThumbnail(t *testing.T) {
+    tests := []struct {
+        name          string
+        width, height int
+        size          int
+        wantW, wantH  int
+    }{
+        {"landscape", 800, 400, 320, 320, 160},
+        {"portrait", 300, 900, 300, 100, 300},
+        {"small image keeps size", 100, 50, 320, 100, 50},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            src := image.NewRGBA(image.Rect(0, 0, tt.width, tt.height))
+            for y := 0; y < tt.height; y++ {
+                for x := 0; x < tt.width; x++ {
+                    src.Set(x, y, color.RGBA{R: 200, G: 100, B: 50, A: 255})
+                }
+            }
+            var buf bytes.Buffer
+            if err := png.Encode(&buf, src); err != nil {
+                t.Fatal(err)
+            }
+
+            thumbnail, err := makeThumbnail(buf.Bytes(), tt.size)
+            if err != nil {
+                t.Fatalf("makeThumbnail: %v", err)
+            }
+            img, err := jpeg.Decode(bytes.NewReader(thumbnail))
+            if err != nil {
+                t.Fatalf("thumbnail is not a JPEG: %v", err)
+            }
+            if got := img.Bounds().Size(); got.X != tt.wantW || got.Y != tt.wantH {
+                t.Errorf("thumbnail size = %dx%d, want %dx%d", got.X, got.Y, tt.wantW, tt.wantH)
+            }
+        })
+    }
+}
+
+func TestMakeThumbnailRejectsNonImage(t *testing.T) {
+    if _, err := makeThumbnail([]byte("not an image"), 320); err == nil {
+        t.Error("makeThumbnail succeeded on non-image data")
+    }
+}
+
+func TestMakeThumbnailRejectsOversizedImage(t *testing.T) {
+    // 只有 PNG 签名和 IHDR 的图片，声明 20000x20000 像素，解码需要约 1.6 GB 内存
+    ihdr := make([]byte, 13)
+    binary.BigEndian.PutUint32(ihdr[0:], 20000)
+    binary.BigEndian.PutUint32(ihdr[4:], 20000)
+    ihdr[8], ihdr[9] = 8, 6 // 8 位 RGBA
+    var buf bytes.Buffer
+    buf.WriteString("\x89PNG\r\n\x1a\n")
+    binary.Write(&buf, binary.BigEndian, uint32(len(ihdr)))
+    chunk := append([]byte("IHDR"), ihdr...)
+    buf.Write(chunk)
+    binary.Write(&buf, binary.BigEndian, crc32.ChecksumIEEE(chunk))
+
+    if _, err := makeThumbnail(buf.Bytes(), 320); !errors.Is(err, errImageTooLarge) {
+        t.Errorf("makeThumbnail error = %v, want errImageTooLarge", err)
+    }
+}
@@ -0,0 +1,264 @@
+/*
+ * 文件功能描述：从MTProto消息中提取发送者、媒体、链接、提及、话题标签、转发、回复和互动数据
+ * 主要类/接口说明：messageContent提取结果，extractMessage提取入口，写入PocketBase记录和Meilisearch文档
+ * 修改历史记录：
+ * @author fcj
+ * @date 2023-11-15
+ * @version 1.0.0
+ * © Telegram Bot Services Team
+ */
+
+package service
+
+import (
+    "strconv"
+    "strings"
+    "unicode/utf16"
+
+    "github.com/gotd/td/tg"
+)
+
+// 媒体类型，与 Bot API 的消息字段名保持一致
+const (
+    MediaTypePhoto     = "photo"
+    MediaTypeVideo     = "video"
+    MediaTypeAnimation = "animation"
+    MediaTypeVideoNote = "video_note"
+    MediaTypeAudio     = "audio"
+    MediaTypeVoice     = "voice"
+    MediaTypeSticker   = "sticker"
+    MediaTypeDocument  = "document"
+    MediaTypePoll      = "poll"
+    MediaTypeWebPage   = "webpage"
+    MediaTypeLocation  = "location"
+    MediaTypeContact   = "contact"
+    MediaTypeOther     = "other"
+)
+
+// messageContent 从消息中提取的结构化内容
+// @author fcj
+// @date 2023-11-15
+// @version 1.0.0
+type messageContent struct {
+    SenderID   int64
+    SenderName string
+
+    // MediaType 媒体类型，纯文本消息为空
+    MediaType string
+    FileName  string
+    FileSize  int64
+    MimeType  string
+
+    // URLs 文本中的链接和文字链接的目标地址
+    URLs []string
+    // Mentions 提及的用户名（不含 @）或用户ID
+    Mentions []string
+    // Hashtags 话题标签（不含 #，小写）
+    Hashtags []string
+
+    // ForwardFromID 转发来源的 Bot API 格式ID，隐藏来源的转发为 0
+    ForwardFromID   int64
+    ForwardFromName string
+    ForwardDate     int
+
+    ReplyToMessageID int
+
+    Views     int
+    Forwards  int
+    Reactions int
+
+    EditDate  int
+    GroupedID int64
+
+    // MediaKey 归档到对象存储的媒体文件键，未归档时为空
+    MediaKey string
+    // ThumbnailKey 图片缩略图的对象键
+    ThumbnailKey string
+}
+
+// extractMessage 提取消息内容
+// @param peer 消息所在的群组或频道
+// @param message 消息
+// @param names 按 Bot API 格式ID索引的用户、群组和频道名称
+// @return *messageContent 提取结果
+func extractMessage(peer *ResolvedPeer, message *tg.Message, names map[int64]string) *messageContent {
+    content := &messageContent{
+        SenderID:  messageSenderID(peer, message),
+        EditDate:  message.EditDate,
+        GroupedID: message.GroupedID,
+    }
+    content.SenderName = names[content.SenderID]
+    if content.SenderName == "" && content.SenderID == peer.ChatID {
+        content.SenderName = peer.Title
+    }
+
+    if message.Media != nil {
+        extractMedia(content, message.Media)
+    }
+    extractEntities(content, message.Message, message.Entities)
+
+    if fwd, ok := message.GetFwdFrom(); ok {
+        content.ForwardDate = fwd.Date
+        content.ForwardFromName = fwd.FromName
+        if from, ok := fwd.GetFromID(); ok {
+            content.ForwardFromID = peerChatID(from)
+            if name := names[content.ForwardFromID]; name != "" {
+                content.ForwardFromName = name
+            }
+        }
+    }
+    if reply, ok := message.ReplyTo.(*tg.MessageReplyHeader); ok {
+        content.ReplyToMessageID = reply.ReplyToMsgID
+    }
+
+    content.Views = message.Views
+    content.Forwards = message.Forwards
+    if reactions, ok := message.GetReactions(); ok {
+        for _, result := range reactions.Results {
+            content.Reactions += result.Count
+        }
+    }
+    return content
+}
+
+// extractMedia 提取媒体类型、文件名、大小和 MIME 类型
+func extractMedia(content *messageContent, media tg.MessageMediaClass) {
+    switch m := media.(type) {
+    case *tg.MessageMediaPhoto:
+        content.MediaType = MediaTypePhoto
+        content.MimeType = "image/jpeg"
+        if photo, ok := m.Photo.(*tg.Photo); ok {
+            _, content.FileSize = largestPhotoSize(photo)
+        }
+    case *tg.MessageMediaDocument:
+        content.MediaType = MediaTypeDocument
+        document, ok := m.Document.(*tg.Document)
+        if !ok {
+            return
+        }
+        content.FileSize = document.Size
+        content.MimeType = document.MimeType
+        for _, attribute := range document.Attributes {
+            switch a := attribute.(type) {
+            case *tg.DocumentAttributeFilename:
+                content.FileName = a.FileName
+            case *tg.DocumentAttributeSticker:
+                content.MediaType = MediaTypeSticker
+            case *tg.DocumentAttributeAnimated:
+                content.MediaType = MediaTypeAnimation
+            case *tg.DocumentAttributeVideo:
+                // 动图同时带有视频属性，保留 animation
+                if content.MediaType == MediaTypeDocument {
+                    content.MediaType = MediaTypeVideo
+                }
+                if a.RoundMessage {
+                    content.MediaType = MediaTypeVideoNote
+                }
+            case *tg.DocumentAttributeAudio:
+                content.MediaType = MediaTypeAudio
+                if a.Voice {
+                    content.MediaType = MediaTypeVoice
+                }
+            }
+        }
+    case *tg.MessageMediaPoll:
+        content.MediaType = MediaTypePoll
+    case *tg.MessageMediaWebPage:
+        content.MediaType = MediaTypeWebPage
+        if page, ok := m.Webpage.(*tg.WebPage); ok {
+            content.URLs = appendUnique(content.URLs, page.URL)
+        }
+    case *tg.MessageMediaGeo, *tg.MessageMediaGeoLive, *tg.MessageMediaVenue:
+        content.MediaType = MediaTypeLocation
+    case *tg.MessageMediaContact:
+        content.MediaType = MediaTypeContact
+    case *tg.MessageMediaEmpty:
+    default:
+        content.MediaType = MediaTypeOther
+    }
+}
+
+// largestPhotoSize 返回照片最大尺寸的类型和文件大小
+func largestPhotoSize(photo *tg.Photo) (string, int64) {
+    var thumbType string
+    var largest int
+    for _, size := range photo.Sizes {
+        switch s := size.(type) {
+        case *tg.PhotoSize:
+            if s.Size > largest {
+                thumbType, largest = s.Type, s.Size
+            }
+        case *tg.PhotoSizeProgressive:
+            if len(s.Sizes) > 0 && s.Sizes[len(s.Sizes)-1] > largest {
+                thumbType, largest = s.Type, s.Sizes[len(s.Sizes)-1]
+            }
+        }
+    }
+    return thumbType, int64(largest)
+}
+
+// extractEntities 提取链接、提及和话题标签；实体的偏移和长度以 UTF-16 代码单元计
+func extractEntities(content *messageContent, text string, entities []tg.MessageEntityClass) {
+    if len(entities) == 0 {
+        return
+    }
+    encoded := utf16.Encode([]rune(text))
+    substr := func(offset, length int) string {
+        if offset < 0 || length <= 0 || offset+length > len(encoded) {
+            return ""
+        }
+        return string(utf16.Decode(encoded[offset : offset+length]))
+    }
+
+    for _, entity := range entities {
+        switch e := entity.(type) {
+        case *tg.MessageEntityURL:
+            content.URLs = appendUnique(content.URLs, substr(e.Offset, e.Length))
+        case *tg.MessageEntityTextURL:
+            content.URLs = appendUnique(content.URLs, e.URL)
+        case *tg.MessageEntityMention:
+            content.Mentions = appendUnique(content.Mentions, strings.TrimPrefix(substr(e.Offset, e.Length), "@"))
+        case *tg.MessageEntityMentionName:
+            content.Mentions = appendUnique(content.Mentions, strconv.FormatInt(e.UserID, 10))
+        case *tg.MessageEntityHashtag:
+            tag := strings.TrimPrefix(substr(e.Offset, e.Length), "#")
+            content.Hashtags = appendUnique(content.Hashtags, strings.ToLower(tag))
+        }
+    }
+}
+
+// appendUnique 追加非空且未出现过的值
+func appendUnique(values []string, value string) []string {
+    if value == "" {
+        return values
+    }
+    for _, existing := range values {
+        if existing == value {
+            return values
+        }
+    }
+    return append(values, value)
+}
+
+// peerChatID 将 PeerClass 转换为 Bot API 格式的ID
+func peerChatID(peer tg.PeerClass) int64 {
+    switch p := peer.(type) {
+    case *tg.PeerUser:
+        return p.UserID
+    case *tg.PeerChat:
+        return -p.ChatID
+    case *tg.PeerChannel:
+        return -channelChatIDOffset - p.ChannelID
+    }
+    return 0
+}
+
+/*
+ * 关键算法说明：
+ * 1. 媒体类型：照片按 MessageMediaPhoto 识别；文档按属性细分为贴纸、动图、视频、圆形视频、音频、语音，其余为普通文档
+ * 2. 实体：MTProto 的实体偏移以 UTF-16 代码单元计，先将文本编码为 UTF-16 再截取，避免中文和表情符号导致错位
+ * 3. 名称：发送者和转发来源的名称来自同一响应中的 users/chats，找不到时转发来源使用 fwd_from.from_name
+ *
+ * 待优化事项：
+ * 1. 投票：暂不提取投票问题和选项文本
+ */
@@ -0,0 +1,125 @@
+package service
+
+import (
+    "reflect"
+    "testing"
+
+    "github.com/gotd/td/tg"
+)
+
+func TestExtractEntitiesUTF16Offsets(t *testing.T) {
+    // "价格" 占 2 个 UTF-16 代码单元，"😀" 是代理对，占 2 个
+    text := "价格😀 #空投 @Alpha_News https://example.com #DeFi #defi"
+    tests := []struct {
+        name     string
+        entities []tg.MessageEntityClass
+        want     messageContent
+    }{
+        {
+            name: "hashtag after emoji",
+            entities: []tg.MessageEntityClass{
+                &tg.MessageEntityHashtag{Offset: 5, Length: 3},
+            },
+            want: messageContent{Hashtags: []string{"空投"}},
+        },
+        {
+            name: "mention and url",
+            entities: []tg.MessageEntityClass{
+                &tg.MessageEntityMention{Offset: 9, Length: 11},
+                &tg.MessageEntityURL{Offset: 21, Length: 19},
+            },
+            want: messageContent{Mentions: []string{"Alpha_News"}, URLs: []string{"https://example.com"}},
+        },
+        {
+            name: "hashtags are lowercased and deduplicated",
+            entities: []tg.MessageEntityClass{
+                &tg.MessageEntityHashtag{Offset: 41, Length: 5},
+                &tg.MessageEntityHashtag{Offset: 47, Length: 5},
+            },
+            want: messageContent{Hashtags: []string{"defi"}},
+        },
+        {
+            name: "text url and mention name",
+            entities: []tg.MessageEntityClass{
+                &tg.MessageEntityTextURL{Offset: 0, Length: 2, URL: "https://t.me/alpha"},
+                &tg.MessageEntityMentionName{Offset: 0, Length: 2, UserID: 777},
+            },
+            want: messageContent{Mentions: []string{"777"}, URLs: []string{"https://t.me/alpha"}},
+        },
+        {
+            name: "out of range entities are ignored",
+            entities: []tg.MessageEntityClass{
+                &tg.MessageEntityURL{Offset: 50, Length: 10},
+                &tg.MessageEntityMention{Offset: -1, Length: 3},
+                &tg.MessageEntityHashtag{Offset: 5, Length: 0},
+            },
+            want: messageContent{},
+        },
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            var got messageContent
+            extractEntities(&got, text, tt.entities)
+            if !reflect.DeepEqual(got, tt.want) {
+                t.Errorf("got %+v, want %+v", got, tt.want)
+            }
+        })
+    }
+}
+
+func TestExtractMediaDocumentAttributes(t *testing.T) {
+    video := &tg.DocumentAttributeVideo{W: 512, H: 512}
+    tests := []struct {
+        name       string
+        attributes []tg.DocumentAttributeClass
+        want       string
+    }{
+        {"plain document", []tg.DocumentAttributeClass{&tg.DocumentAttributeFilename{FileName: "a.pdf"}}, MediaTypeDocument},
+        {"sticker", []tg.DocumentAttributeClass{&tg.DocumentAttributeSticker{}, &tg.DocumentAttributeImageSize{}}, MediaTypeSticker},
+        {"video sticker", []tg.DocumentAttributeClass{&tg.DocumentAttributeSticker{}, video}, MediaTypeSticker},
+        {"video sticker, video first", []tg.DocumentAttributeClass{video, &tg.DocumentAttributeSticker{}}, MediaTypeSticker},
+        {"video", []tg.DocumentAttributeClass{video, &tg.DocumentAttributeFilename{FileName: "a.mp4"}}, MediaTypeVideo},
+        {"animation", []tg.DocumentAttributeClass{&tg.DocumentAttributeAnimated{}, video}, MediaTypeAnimation},
+        {"animation, video first", []tg.DocumentAttributeClass{video, &tg.DocumentAttributeAnimated{}}, MediaTypeAnimation},
+        {"round video", []tg.DocumentAttributeClass{&tg.DocumentAttributeVideo{RoundMessage: true}}, MediaTypeVideoNote},
+        {"audio", []tg.DocumentAttributeClass{&tg.DocumentAttributeAudio{Title: "song"}}, MediaTypeAudio},
+        {"voice", []tg.DocumentAttributeClass{&tg.DocumentAttributeAudio{Voice: true}}, MediaTypeVoice},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            var got messageContent
+            extractMedia(&got, &tg.MessageMediaDocument{Document: &tg.Document{
+                Size:       1024,
+                MimeType:   "application/octet-stream",
+                Attributes: tt.attributes,
+            }})
+            if got.MediaType != tt.want {
+                t.Errorf("MediaType = %q, want %q", got.MediaType, tt.want)
+            }
+            if got.FileSize != 1024 {
+                t.Errorf("FileSize = %d, want 1024", got.FileSize)
+            }
+        })
+    }
+}
+
+func TestExtractMediaFileNameAndPhotoSize(t *testing.T) {
+    var doc messageContent
+    extractMedia(&doc, &tg.MessageMediaDocument{Document: &tg.Document{
+        MimeType:   "application/pdf",
+        Attributes: []tg.DocumentAttributeClass{&tg.DocumentAttributeFilename{FileName: "白皮书.pdf"}},
+    }})
+    if doc.FileName != "白皮书.pdf" || doc.MimeType != "application/pdf" {
+        t.Errorf("document = %+v, want file name and MIME type", doc)
+    }
+
+    var photo messageContent
+    extractMedia(&photo, &tg.MessageMediaPhoto{Photo: &tg.Photo{Sizes: []tg.PhotoSizeClass{
+        &tg.PhotoSize{Type: "m", Size: 2000},
+        &tg.PhotoSizeProgressive{Type: "y", Sizes: []int{5000, 9000}},
+        &tg.PhotoSize{Type: "x", Size: 7000},
+    }}})
+    if photo.MediaType != MediaTypePhoto || photo.FileSize != 9000 {
+        t.Errorf("photo = %+v, want photo of 9000 bytes", photo)
+    }
+}
@@ -0,0 +1,435 @@
+/*
+ * 文件功能描述：采集目标解析，将用户名、t.me链接和数字ID解析为可调用API的Telegram对等体，并按账号缓存access hash
+ * 主要类/接口说明：PeerResolver接口及其实现、ResolvedPeer已解析的群组/频道、ParsePeerTarget目标解析
+ * 修改历史记录：
+ * @author fcj
+ * @date 2023-11-15
+ * @version 1.0.0
+ * © Telegram Bot Services Team
+ */
+
+package service
+
+import (
+    "context"
+    "errors"
+    "fmt"
+    "log"
+    "net/url"
+    "regexp"
+    "strconv"
+    "strings"
+    "sync"
+    "time"
+
+    "github.com/gotd/td/telegram/query/dialogs"
+    "github.com/gotd/td/tg"
+)
+
+// channelChatIDOffset Bot API 中超级群组/频道ID的偏移：chat_id = -(1000000000000 + channel_id)
+const channelChatIDOffset = 1000000000000
+
+const (
+    // dialogsBatchSize 遍历对话列表时每页的数量
+    dialogsBatchSize = 100
+    // peerMissTTL 未找到的会话ID在此时间内不再遍历对话列表，避免定时采集反复全量遍历
+    peerMissTTL = 30 * time.Minute
+)
+
+// usernamePattern 合法的公开用户名：字母开头，5 到 32 个字符
+var usernamePattern = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_]{4,31}$`)
+
+// ErrPeerNotFound 账号无法访问目标群组或频道
+var ErrPeerNotFound = errors.New("peer not found")
+
+// ErrInvalidPeerTarget 采集目标格式不合法
+var ErrInvalidPeerTarget = errors.New("invalid peer target")
+
+// PeerKind 对等体类型
+type PeerKind string
+
+const (
+    // PeerKindChat 普通群组（InputPeerChat，无需access hash）
+    PeerKindChat PeerKind = "chat"
+    // PeerKindChannel 超级群组或频道（InputPeerChannel）
+    PeerKindChannel PeerKind = "channel"
+)
+
+// ResolvedPeer 已解析的群组或频道
+// @author fcj
+// @date 2023-11-15
+// @version 1.0.0
+type ResolvedPeer struct {
+    // ChatID Bot API 格式的会话ID：普通群组为 -id，超级群组/频道为 -100id，与机器人服务保持一致
+    ChatID     int64    `json:"chat_id"`
+    Kind       PeerKind `json:"kind"`
+    AccessHash int64    `json:"access_hash,omitempty"`
+    Username   string   `json:"username,omitempty"`
+    Title      string   `json:"title,omitempty"`
+    // Megagroup 频道类型中的超级群组
+    Megagroup bool `json:"megagroup,omitempty"`
+}
+
+// ChatType 返回 Bot API 的会话类型（group、supergroup、channel），与机器人服务索引的 TYPE 字段一致
+func (p *ResolvedPeer) ChatType() string {
+    switch {
+    case p.Kind == PeerKindChat:
+        return "group"
+    case p.Megagroup:
+        return "supergroup"
+    default:
+        return "channel"
+    }
+}
+
+// InputPeer 构建调用API所需的 InputPeer
+func (p *ResolvedPeer) InputPeer() tg.InputPeerClass {
+    if p.Kind == PeerKindChat {
+        return &tg.InputPeerChat{ChatID: -p.ChatID}
+    }
+    return &tg.InputPeerChannel{ChannelID: -p.ChatID - channelChatIDOffset, AccessHash: p.AccessHash}
+}
+
+// PeerTarget 解析后的采集目标，Username 与 ChatID 二选一
+type PeerTarget struct {
+    Username string
+    ChatID   int64
+}
+
+// ParsePeerTarget 解析采集目标，支持 @username、username、t.me/username、t.me/c/<id>/<msg> 链接以及数字ID
+// @param target 采集目标
+// @return PeerTarget 解析结果
+// @return error 格式不合法时返回 ErrInvalidPeerTarget
+func ParsePeerTarget(target string) (PeerTarget, error) {
+    target = strings.TrimSpace(target)
+    if chatID, err := strconv.ParseInt(target, 10, 64); err == nil && chatID != 0 {
+        return PeerTarget{ChatID: chatID}, nil
+    }
+
+    name := strings.TrimPrefix(target, "@")
+    if strings.Contains(target, "/") {
+        u, err := url.Parse(target)
+        if err == nil && u.Scheme == "" {
+            u, err = url.Parse("https://" + target)
+        }
+        if err != nil || (u.Host != "t.me" && u.Host != "www.t.me" && u.Host != "telegram.me") {
+            return PeerTarget{}, fmt.Errorf("%w: %q is not a t.me link", ErrInvalidPeerTarget, target)
+        }
+        segments := strings.Split(strings.Trim(u.Path, "/"), "/")
+        switch {
+        case segments[0] == "c" && len(segments) >= 2:
+            // 私有群组的消息链接 t.me/c/<channel_id>/<message_id>
+            channelID, err := strconv.ParseInt(segments[1], 10, 64)
+            if err != nil || channelID <= 0 {
+                return PeerTarget{}, fmt.Errorf("%w: %q has an invalid channel id", ErrInvalidPeerTarget, target)
+            }
+            return PeerTarget{ChatID: -channelChatIDOffset - channelID}, nil
+        case strings.HasPrefix(segments[0], "+") || segments[0] == "joinchat":
+            return PeerTarget{}, fmt.Errorf("%w: invite links are not supported, join the chat first and use its id", ErrInvalidPeerTarget)
+        case segments[0] == "s" && len(segments) >= 2:
+            // 频道预览链接 t.me/s/<username>
+            name = segments[1]
+        default:
+            name = segments[0]
+        }
+    }
+
+    if !usernamePattern.MatchString(name) {
+        return PeerTarget{}, fmt.Errorf("%w: %q", ErrInvalidPeerTarget, target)
+    }
+    return PeerTarget{Username: strings.ToLower(name)}, nil
+}
+
+// PeerResolver 定义采集目标解析接口
+// @author fcj
+// @date 2023-11-15
+// @version 1.0.0
+type PeerResolver interface {
+    // Resolve 解析用户名、链接或数字ID形式的采集目标
+    Resolve(ctx context.Context, phoneNumber string, api *tg.Client, target string) (*ResolvedPeer, error)
+
+    // ResolveID 按会话ID解析，优先使用缓存，未命中时遍历账号的对话列表
+    ResolveID(ctx context.Context, phoneNumber string, api *tg.Client, chatID int64) (*ResolvedPeer, error)
+}
+
+// peerCache 单个账号的内存缓存
+type peerCache struct {
+    byID       map[int64]ResolvedPeer
+    byUsername map[string]int64
+    misses     map[int64]time.Time
+}
+
+// peerResolverImpl 实现PeerResolver接口，内存缓存按账号从 PeerStore 懒加载
+// @author fcj
+// @date 2023-11-15
+// @version 1.0.0
+type peerResolverImpl struct {
+    store  PeerStore
+    mutex  sync.Mutex
+    caches map[string]*peerCache
+}
+
+// NewPeerResolver 创建采集目标解析器
+// @param store access hash 持久化存储
+// @return PeerResolver 解析器实例
+func NewPeerResolver(store PeerStore) PeerResolver {
+    return &peerResolverImpl{
+        store:  store,
+        caches: make(map[string]*peerCache),
+    }
+}
+
+// Resolve 解析用户名、链接或数字ID形式的采集目标
+func (r *peerResolverImpl) Resolve(ctx context.Context, phoneNumber string, api *tg.Client, target string) (*ResolvedPeer, error) {
+    parsed, err := ParsePeerTarget(target)
+    if err != nil {
+        return nil, err
+    }
+    if parsed.Username == "" {
+        return r.ResolveID(ctx, phoneNumber, api, parsed.ChatID)
+    }
+
+    if peer, ok := r.cached(ctx, phoneNumber, func(c *peerCache) (ResolvedPeer, bool) {
+        peer, ok := c.byID[c.byUsername[parsed.Username]]
+        return peer, ok
+    }); ok {
+        return peer, nil
+    }
+
+    resolved, err := api.ContactsResolveUsername(ctx, &tg.ContactsResolveUsernameRequest{Username: parsed.Username})
+    if err != nil {
+        return nil, fmt.Errorf("failed to resolve username %s: %w", parsed.Username, err)
+    }
+    var chatID int64
+    switch p := resolved.Peer.(type) {
+    case *tg.PeerChannel:
+        chatID = -channelChatIDOffset - p.ChannelID
+    case *tg.PeerChat:
+        chatID = -p.ChatID
+    default:
+        return nil, fmt.Errorf("%w: @%s is not a group or channel", ErrPeerNotFound, parsed.Username)
+    }
+
+    peers := peersFromChats(resolved.Chats)
+    r.remember(ctx, phoneNumber, peers)
+    for i := range peers {
+        if peers[i].ChatID == chatID {
+            return &peers[i], nil
+        }
+    }
+    return nil, fmt.Errorf("%w: @%s", ErrPeerNotFound, parsed.Username)
+}
+
+// ResolveID 按会话ID解析
+func (r *peerResolverImpl) ResolveID(ctx context.Context, phoneNumber string, api *tg.Client, chatID int64) (*ResolvedPeer, error) {
+    candidates := chatIDCandidates(chatID)
+    lookup := func(c *peerCache) (ResolvedPeer, bool) {
+        for _, id := range candidates {
+            if peer, ok := c.byID[id]; ok {
+                return peer, true
+            }
+        }
+        return ResolvedPeer{}, false
+    }
+
+    if peer, ok := r.cached(ctx, phoneNumber, lookup); ok {
+        return peer, nil
+    }
+    if r.recentlyMissed(phoneNumber, chatID) {
+        return nil, fmt.Errorf("%w: chat %d is not in the dialogs of %s", ErrPeerNotFound, chatID, phoneNumber)
+    }
+
+    // 未命中缓存时遍历对话列表，顺带缓存遇到的所有群组和频道
+    var found *ResolvedPeer
+    var batch []ResolvedPeer
+    iter := dialogs.NewQueryBuilder(api).GetDialogs().BatchSize(dialogsBatchSize).Iter()
+    for found == nil && iter.Next(ctx) {
+        elem := iter.Value()
+        var chats []tg.ChatClass
+        switch p := elem.Peer.(type) {
+        case *tg.InputPeerChannel:
+            if channel, ok := elem.Entities.Channel(p.ChannelID); ok {
+                chats = append(chats, channel)
+            }
+        case *tg.InputPeerChat:
+            if chat, ok := elem.Entities.Chat(p.ChatID); ok {
+                chats = append(chats, chat)
+            }
+        }
+        for _, peer := range peersFromChats(chats) {
+            batch = append(batch, peer)
+            for _, id := range candidates {
+                if peer.ChatID == id {
+                    p := peer
+                    found = &p
+                }
+            }
+        }
+    }
+    r.remember(ctx, phoneNumber, batch)
+    if err := iter.Err(); err != nil {
+        return nil, fmt.Errorf("failed to get dialogs: %w", err)
+    }
+    if found == nil {
+        r.mutex.Lock()
+        r.cache(phoneNumber).misses[chatID] = time.Now()
+        r.mutex.Unlock()
+        return nil, fmt.Errorf("%w: chat %d is not in the dialogs of %s", ErrPeerNotFound, chatID, phoneNumber)
+    }
+    return found, nil
+}
+
+// recentlyMissed 会话ID最近是否已遍历对话列表仍未找到
+func (r *peerResolverImpl) recentlyMissed(phoneNumber string, chatID int64) bool {
+    r.mutex.Lock()
+    defer r.mutex.Unlock()
+
+    c, exists := r.caches[phoneNumber]
+    if !exists {
+        return false
+    }
+    missedAt, missed := c.misses[chatID]
+    return missed && time.Since(missedAt) < peerMissTTL
+}
+
+// loadCache 首次访问账号时从持久化存储加载缓存；加载可能是一次 HTTP 请求，在锁外进行，
+// 不会阻塞其他账号的解析
+func (r *peerResolverImpl) loadCache(ctx context.Context, phoneNumber string) {
+    r.mutex.Lock()
+    _, exists := r.caches[phoneNumber]
+    r.mutex.Unlock()
+    if exists {
+        return
+    }
+
+    peers, err := r.store.Load(ctx, phoneNumber)
+    if err != nil {
+        // 加载失败时不缓存空结果，下次访问重试
+        log.Printf("Failed to load peer cache for phone %s: %v", phoneNumber, err)
+        return
+    }
+    c := newPeerCache()
+    c.add(peers)
+
+    r.mutex.Lock()
+    defer r.mutex.Unlock()
+    // 同一账号并发加载时保留先写入的缓存，其中可能已有更新的条目
+    if _, exists := r.caches[phoneNumber]; !exists {
+        r.caches[phoneNumber] = c
+    }
+}
+
+// cache 返回账号的内存缓存，尚未加载成功时返回不保存的空缓存；调用方需持有锁
+func (r *peerResolverImpl) cache(phoneNumber string) *peerCache {
+    if c, exists := r.caches[phoneNumber]; exists {
+        return c
+    }
+    return newPeerCache()
+}
+
+// newPeerCache 创建空的账号缓存
+func newPeerCache() *peerCache {
+    return &peerCache{
+        byID:       make(map[int64]ResolvedPeer),
+        byUsername: make(map[string]int64),
+        misses:     make(map[int64]time.Time),
+    }
+}
+
+// cached 在账号缓存中查找
+func (r *peerResolverImpl) cached(ctx context.Context, phoneNumber string, lookup func(c *peerCache) (ResolvedPeer, bool)) (*ResolvedPeer, bool) {
+    r.loadCache(ctx, phoneNumber)
+    r.mutex.Lock()
+    defer r.mutex.Unlock()
+
+    peer, ok := lookup(r.cache(phoneNumber))
+    if !ok {
+        return nil, false
+    }
+    return &peer, true
+}
+
+// remember 写入内存缓存并持久化，持久化失败只记录日志
+func (r *peerResolverImpl) remember(ctx context.Context, phoneNumber string, peers []ResolvedPeer) {
+    if len(peers) == 0 {
+        return
+    }
+    r.loadCache(ctx, phoneNumber)
+    r.mutex.Lock()
+    r.cache(phoneNumber).add(peers)
+    r.mutex.Unlock()
+
+    if err := r.store.Save(ctx, phoneNumber, peers); err != nil {
+        log.Printf("Failed to persist %d peers for phone %s: %v", len(peers), phoneNumber, err)
+    }
+}
+
+// add 加入缓存，用户名变更时移除旧的映射
+func (c *peerCache) add(peers []ResolvedPeer) {
+    for _, peer := range peers {
+        if old, exists := c.byID[peer.ChatID]; exists && old.Username != "" {
+            delete(c.byUsername, old.Username)
+        }
+        c.byID[peer.ChatID] = peer
+        delete(c.misses, peer.ChatID)
+        if peer.Username != "" {
+            c.byUsername[peer.Username] = peer.ChatID
+        }
+    }
+}
+
+// chatIDCandidates 返回会话ID可能对应的 Bot API 格式ID；兼容旧配置中不带 -100 前缀的正数ID
+func chatIDCandidates(chatID int64) []int64 {
+    if chatID > 0 {
+        return []int64{-channelChatIDOffset - chatID, -chatID}
+    }
+    return []int64{chatID}
+}
+
+// peersFromChats 将API返回的群组和频道转换为 ResolvedPeer，跳过不可访问的对象
+func peersFromChats(chats []tg.ChatClass) []ResolvedPeer {
+    var peers []ResolvedPeer
+    for _, chat := range chats {
+        switch c := chat.(type) {
+        case *tg.Chat:
+            if c.Deactivated {
+                // 已升级为超级群组的普通群组
+                continue
+            }
+            peers = append(peers, ResolvedPeer{
+                ChatID: -c.ID,
+                Kind:   PeerKindChat,
+                Title:  c.Title,
+            })
+        case *tg.Channel:
+            if c.Min {
+                // min 对象的 access hash 不能用于直接调用
+                continue
+            }
+            peers = append(peers, ResolvedPeer{
+                ChatID:     -channelChatIDOffset - c.ID,
+                Kind:       PeerKindChannel,
+                AccessHash: c.AccessHash,
+                Username:   strings.ToLower(c.Username),
+                Title:      c.Title,
+                Megagroup:  c.Megagroup,
+            })
+        }
+    }
+    return peers
+}
+
+/*
+ * 关键算法说明：
+ * 1. 目标解析：先按格式解析为用户名或 Bot API 格式的会话ID，再分别通过 contacts.resolveUsername 或 messages.getDialogs 查找
+ * 2. ID约定：普通群组为 -chat_id，超级群组/频道为 -100channel_id；旧配置中的正数ID同时尝试两种类型
+ * 3. 缓存：access hash 按账号缓存于内存并持久化到 PeerStore，遍历对话列表时顺带缓存所有群组和频道，减少后续API调用
+ *    账号首次访问时在锁外从 PeerStore 加载，一个账号加载缓慢或失败不影响其他账号的解析
+ * 4. 未命中：遍历对话列表仍未找到的会话ID在 30 分钟内直接返回 ErrPeerNotFound
+ *
+ * 待优化事项：
+ * 1. 缓存失效：频道被删除或账号退出群组后缓存不会自动清理
+ *
+ * 兼容性说明：
+ * 1. 邀请链接（t.me/+xxx、joinchat）需要先加入群组，暂不支持
+ */
@@ -0,0 +1,102 @@
+package service
+
+import (
+    "context"
+    "errors"
+    "testing"
+    "time"
+)
+
+func TestParsePeerTarget(t *testing.T) {
+    tests := []struct {
+        target string
+        want   PeerTarget
+    }{
+        {"@Crypto_News", PeerTarget{Username: "crypto_news"}},
+        {"crypto_news", PeerTarget{Username: "crypto_news"}},
+        {"  @crypto_news  ", PeerTarget{Username: "crypto_news"}},
+        {"t.me/crypto_news", PeerTarget{Username: "crypto_news"}},
+        {"https://t.me/crypto_news/123", PeerTarget{Username: "crypto_news"}},
+        {"https://telegram.me/crypto_news", PeerTarget{Username: "crypto_news"}},
+        {"https://t.me/s/crypto_news", PeerTarget{Username: "crypto_news"}},
+        {"https://t.me/c/1234567890/42", PeerTarget{ChatID: -1001234567890}},
+        {"t.me/c/1234567890", PeerTarget{ChatID: -1001234567890}},
+        {"-1001234567890", PeerTarget{ChatID: -1001234567890}},
+        {"-123456", PeerTarget{ChatID: -123456}},
+        // 旧配置中不带前缀的正数ID，解析时同时尝试两种类型
+        {"1234567890", PeerTarget{ChatID: 1234567890}},
+        {"abcde", PeerTarget{Username: "abcde"}},
+    }
+    for _, tt := range tests {
+        got, err := ParsePeerTarget(tt.target)
+        if err != nil {
+            t.Errorf("ParsePeerTarget(%q) error: %v", tt.target, err)
+            continue
+        }
+        if got != tt.want {
+            t.Errorf("ParsePeerTarget(%q) = %+v, want %+v", tt.target, got, tt.want)
+        }
+    }
+}
+
+func TestParsePeerTargetRejectsInvalid(t *testing.T) {
+    for _, target := range []string{
+        "",
+        "0",
+        "abcd",                      // 公开用户名至少 5 个字符
+        "1abcde",                    // 必须以字母开头
+        "crypto-news",
+        "https://t.me/+AbCdEfGhIjK", // 邀请链接
+        "https://t.me/joinchat/AbCdEfGhIjK",
+        "https://t.me/c/abc/1",
+        "https://example.com/crypto_news",
+    } {
+        if got, err := ParsePeerTarget(target); !errors.Is(err, ErrInvalidPeerTarget) {
+            t.Errorf("ParsePeerTarget(%q) = %+v, %v; want ErrInvalidPeerTarget", target, got, err)
+        }
+    }
+}
+
+// blockingPeerStore 指定账号的 Load 阻塞到 release 关闭
+type blockingPeerStore struct {
+    blockedPhone string
+    release      chan struct{}
+}
+
+func (s *blockingPeerStore) Load(ctx context.Context, phoneNumber string) ([]ResolvedPeer, error) {
+    if phoneNumber == s.blockedPhone {
+        <-s.release
+    }
+    return []ResolvedPeer{{ChatID: -1001, Kind: PeerKindChannel, Username: "crypto_news"}}, nil
+}
+
+func (s *blockingPeerStore) Save(ctx context.Context, phoneNumber string, peers []ResolvedPeer) error {
+    return nil
+}
+
+func TestPeerCacheLoadDoesNotBlockOtherAccounts(t *testing.T) {
+    store := &blockingPeerStore{blockedPhone: "+10000000001", release: make(chan struct{})}
+    defer close(store.release)
+    r := NewPeerResolver(store).(*peerResolverImpl)
+    lookup := func(c *peerCache) (ResolvedPeer, bool) {
+        peer, ok := c.byID[-1001]
+        return peer, ok
+    }
+
+    go r.cached(context.Background(), store.blockedPhone, lookup)
+    time.Sleep(10 * time.Millisecond)
+
+    done := make(chan bool)
+    go func() {
+        _, ok := r.cached(context.Background(), "+10000000002", lookup)
+        done <- ok
+    }()
+    select {
+    case ok := <-done:
+        if !ok {
+            t.Error("peer not found in the other account's cache")
+        }
+    case <-time.After(time.Second):
+        t.Fatal("loading one account's cache blocked another account")
+    }
+}
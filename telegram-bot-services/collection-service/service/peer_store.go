@@ -0,0 +1,272 @@
+/*
+ * 文件功能描述：access hash 持久化，按账号保存已解析的群组和频道，服务重启后无需重新解析
+ * 主要类/接口说明：PeerStore接口、文件实现及PocketBase实现
+ * 修改历史记录：
+ * @author fcj
+ * @date 2023-11-15
+ * @version 1.0.0
+ * © Telegram Bot Services Team
+ */
+
+package service
+
+import (
+    "context"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "net/http"
+    "os"
+    "path/filepath"
+    "sort"
+    "strconv"
+    "sync"
+
+    "github.com/go-resty/resty/v2"
+)
+
+const (
+    // peerFileExt 文件后端的缓存文件扩展名
+    peerFileExt = ".peers.json"
+)
+
+// PeerStore 定义已解析群组/频道的持久化接口；access hash 与账号绑定，因此按电话号码分别保存
+// @author fcj
+// @date 2023-11-15
+// @version 1.0.0
+type PeerStore interface {
+    // Load 读取账号缓存的所有群组和频道，不存在时返回空列表
+    Load(ctx context.Context, phoneNumber string) ([]ResolvedPeer, error)
+
+    // Save 写入或更新账号的群组和频道
+    Save(ctx context.Context, phoneNumber string, peers []ResolvedPeer) error
+}
+
+// filePeerStore 将缓存保存为 <dir>/<phone>.peers.json
+// @author fcj
+// @date 2023-11-15
+// @version 1.0.0
+type filePeerStore struct {
+    dir   string
+    mutex sync.Mutex
+}
+
+// NewFilePeerStore 创建文件缓存存储，通常与会话文件使用同一目录
+// @param dir 缓存文件目录
+// @return PeerStore 缓存存储
+// @return error 错误信息
+func NewFilePeerStore(dir string) (PeerStore, error) {
+    if dir == "" {
+        dir = "sessions"
+    }
+    if err := os.MkdirAll(dir, 0o700); err != nil {
+        return nil, fmt.Errorf("failed to create peer cache directory: %w", err)
+    }
+    return &filePeerStore{dir: dir}, nil
+}
+
+func (f *filePeerStore) path(phoneNumber string) string {
+    return filepath.Join(f.dir, phoneNumber+peerFileExt)
+}
+
+// Load 读取缓存文件
+func (f *filePeerStore) Load(ctx context.Context, phoneNumber string) ([]ResolvedPeer, error) {
+    if err := ValidatePhoneNumber(phoneNumber); err != nil {
+        return nil, err
+    }
+    f.mutex.Lock()
+    defer f.mutex.Unlock()
+
+    return f.load(phoneNumber)
+}
+
+func (f *filePeerStore) load(phoneNumber string) ([]ResolvedPeer, error) {
+    data, err := os.ReadFile(f.path(phoneNumber))
+    if errors.Is(err, os.ErrNotExist) {
+        return nil, nil
+    }
+    if err != nil {
+        return nil, fmt.Errorf("failed to read peer cache: %w", err)
+    }
+    var peers []ResolvedPeer
+    if err := json.Unmarshal(data, &peers); err != nil {
+        return nil, fmt.Errorf("failed to decode peer cache: %w", err)
+    }
+    return peers, nil
+}
+
+// Save 与已有缓存合并后整体写回，先写临时文件再重命名
+func (f *filePeerStore) Save(ctx context.Context, phoneNumber string, peers []ResolvedPeer) error {
+    if err := ValidatePhoneNumber(phoneNumber); err != nil {
+        return err
+    }
+    f.mutex.Lock()
+    defer f.mutex.Unlock()
+
+    existing, err := f.load(phoneNumber)
+    if err != nil {
+        return err
+    }
+    merged := make(map[int64]ResolvedPeer, len(existing)+len(peers))
+    for _, peer := range append(existing, peers...) {
+        merged[peer.ChatID] = peer
+    }
+    all := make([]ResolvedPeer, 0, len(merged))
+    for _, peer := range merged {
+        all = append(all, peer)
+    }
+    sort.Slice(all, func(i, j int) bool { return all[i].ChatID < all[j].ChatID })
+
+    data, err := json.MarshalIndent(all, "", "  ")
+    if err != nil {
+        return fmt.Errorf("failed to encode peer cache: %w", err)
+    }
+    tmp, err := os.CreateTemp(f.dir, phoneNumber+".*.tmp")
+    if err != nil {
+        return fmt.Errorf("failed to create temp peer cache file: %w", err)
+    }
+    defer os.Remove(tmp.Name())
+    if _, err := tmp.Write(data); err != nil {
+        tmp.Close()
+        return fmt.Errorf("failed to write peer cache: %w", err)
+    }
+    if err := tmp.Close(); err != nil {
+        return fmt.Errorf("failed to write peer cache: %w", err)
+    }
+    if err := os.Rename(tmp.Name(), f.path(phoneNumber)); err != nil {
+        return fmt.Errorf("failed to replace peer cache: %w", err)
+    }
+    return nil
+}
+
+// pocketBasePeerStore 将缓存保存到 PocketBase 的 telegram_peers 集合
+// @author fcj
+// @date 2023-11-15
+// @version 1.0.0
+type pocketBasePeerStore struct {
+    client  *resty.Client
+    baseURL string
+    token   string
+}
+
+// NewPocketBasePeerStore 创建PocketBase缓存存储
+// @param baseURL PocketBase 地址
+// @param token PocketBase 超级用户令牌（集合不对外开放）
+// @return PeerStore 缓存存储
+func NewPocketBasePeerStore(baseURL string, token string) PeerStore {
+    return &pocketBasePeerStore{
+        client:  resty.New(),
+        baseURL: baseURL,
+        token:   token,
+    }
+}
+
+// Load 分页读取账号的所有记录
+func (p *pocketBasePeerStore) Load(ctx context.Context, phoneNumber string) ([]ResolvedPeer, error) {
+    if err := ValidatePhoneNumber(phoneNumber); err != nil {
+        return nil, err
+    }
+    var peers []ResolvedPeer
+    for page := 1; ; page++ {
+        var result struct {
+            TotalPages int `json:"totalPages"`
+            Items      []struct {
+                ChatID     string `json:"chat_id"`
+                Kind       string `json:"kind"`
+                AccessHash string `json:"access_hash"`
+                Username   string `json:"username"`
+                Title      string `json:"title"`
+                Megagroup  bool   `json:"megagroup"`
+            } `json:"items"`
+        }
+        resp, err := p.client.R().
+            SetContext(ctx).
+            SetHeader("Authorization", "Bearer "+p.token).
+            SetQueryParams(map[string]string{
+                "page":    strconv.Itoa(page),
+                "perPage": "500",
+                // 电话号码已校验，不会造成过滤注入
+                "filter": fmt.Sprintf("phone='%s'", phoneNumber),
+            }).
+            SetResult(&result).
+            Get(p.baseURL + "/api/collections/telegram_peers/records")
+        if err != nil {
+            return nil, fmt.Errorf("failed to connect to PocketBase: %w", err)
+        }
+        if resp.IsError() {
+            return nil, fmt.Errorf("PocketBase returned status code: %d, body: %s", resp.StatusCode(), resp.Body())
+        }
+        for _, item := range result.Items {
+            chatID, err := strconv.ParseInt(item.ChatID, 10, 64)
+            if err != nil {
+                continue
+            }
+            // access hash 以文本保存，避免超出 JSON 数字精度
+            accessHash, _ := strconv.ParseInt(item.AccessHash, 10, 64)
+            peers = append(peers, ResolvedPeer{
+                ChatID:     chatID,
+                Kind:       PeerKind(item.Kind),
+                AccessHash: accessHash,
+                Username:   item.Username,
+                Title:      item.Title,
+                Megagroup:  item.Megagroup,
+            })
+        }
+        if page >= result.TotalPages {
+            break
+        }
+    }
+    return peers, nil
+}
+
+// Save 通过批量接口按确定的记录ID写入或更新
+func (p *pocketBasePeerStore) Save(ctx context.Context, phoneNumber string, peers []ResolvedPeer) error {
+    if err := ValidatePhoneNumber(phoneNumber); err != nil {
+        return err
+    }
+    for start := 0; start < len(peers); start += pocketBaseBatchSize {
+        end := start + pocketBaseBatchSize
+        if end > len(peers) {
+            end = len(peers)
+        }
+        requests := make([]map[string]interface{}, 0, end-start)
+        for _, peer := range peers[start:end] {
+            chatID := strconv.FormatInt(peer.ChatID, 10)
+            requests = append(requests, map[string]interface{}{
+                "method": http.MethodPut,
+                "url":    "/api/collections/telegram_peers/records",
+                "body": map[string]interface{}{
+                    "id":          pocketBaseRecordID(phoneNumber + "/" + chatID),
+                    "phone":       phoneNumber,
+                    "chat_id":     chatID,
+                    "kind":        string(peer.Kind),
+                    "access_hash": strconv.FormatInt(peer.AccessHash, 10),
+                    "username":    peer.Username,
+                    "title":       peer.Title,
+                    "megagroup":   peer.Megagroup,
+                },
+            })
+        }
+        resp, err := p.client.R().
+            SetContext(ctx).
+            SetHeader("Authorization", "Bearer "+p.token).
+            SetBody(map[string]interface{}{"requests": requests}).
+            Post(p.baseURL + "/api/batch")
+        if err != nil {
+            return fmt.Errorf("failed to connect to PocketBase: %w", err)
+        }
+        if resp.IsError() {
+            return fmt.Errorf("PocketBase returned status code: %d, body: %s", resp.StatusCode(), resp.Body())
+        }
+    }
+    return nil
+}
+
+/*
+ * 关键算法说明：
+ * 1. 按账号存储：access hash 只对获取它的账号有效，缓存以电话号码区分
+ * 2. 幂等写入：PocketBase 记录ID由电话号码和会话ID确定，通过批量接口的 PUT（upsert）写入，重复解析不会产生重复记录
+ *
+ * 兼容性说明：
+ * 1. PocketBase 后端依赖批量接口，需在管理端启用（messages 集合迁移已默认开启）
+ */
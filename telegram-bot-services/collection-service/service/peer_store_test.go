@@ -0,0 +1,60 @@
+package service
+
+import (
+    "context"
+    "encoding/json"
+    "net/http"
+    "net/http/httptest"
+    "testing"
+)
+
+// TestPocketBasePeerStoreKeepsMegagroup 超级群组重启后仍应为 supergroup，而不是 channel
+func TestPocketBasePeerStoreKeepsMegagroup(t *testing.T) {
+    var saved []map[string]interface{}
+    server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        w.Header().Set("Content-Type", "application/json")
+        switch r.URL.Path {
+        case "/api/batch":
+            var body struct {
+                Requests []struct {
+                    Body map[string]interface{} `json:"body"`
+                } `json:"requests"`
+            }
+            if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+                t.Errorf("decode batch: %v", err)
+            }
+            for _, req := range body.Requests {
+                saved = append(saved, req.Body)
+            }
+            w.Write([]byte(`[]`))
+        case "/api/collections/telegram_peers/records":
+            json.NewEncoder(w).Encode(map[string]interface{}{"totalPages": 1, "items": saved})
+        default:
+            http.NotFound(w, r)
+        }
+    }))
+    defer server.Close()
+
+    store := NewPocketBasePeerStore(server.URL, "token")
+    ctx := context.Background()
+    peers := []ResolvedPeer{
+        {ChatID: -1001, Kind: PeerKindChannel, AccessHash: 42, Title: "Group", Megagroup: true},
+        {ChatID: -1002, Kind: PeerKindChannel, AccessHash: 43, Title: "Channel"},
+    }
+    if err := store.Save(ctx, "+8613800000000", peers); err != nil {
+        t.Fatal(err)
+    }
+    loaded, err := store.Load(ctx, "+8613800000000")
+    if err != nil {
+        t.Fatal(err)
+    }
+    if len(loaded) != 2 {
+        t.Fatalf("loaded %d peers, want 2", len(loaded))
+    }
+    if got := loaded[0].ChatType(); got != "supergroup" {
+        t.Errorf("first peer type = %q, want supergroup", got)
+    }
+    if got := loaded[1].ChatType(); got != "channel" {
+        t.Errorf("second peer type = %q, want channel", got)
+    }
+}
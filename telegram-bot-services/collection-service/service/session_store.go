@@ -0,0 +1,253 @@
+/*
+ * 文件功能描述：MTProto会话持久化，按电话号码保存gotd会话数据，服务重启后无需重新登录
+ * 主要类/接口说明：SessionStore接口、加密文件实现及gotd session.Storage适配器
+ * 修改历史记录：
+ * @author fcj
+ * @date 2023-11-15
+ * @version 1.0.0
+ * © Telegram Bot Services Team
+ */
+
+package service
+
+import (
+    "context"
+    "crypto/aes"
+    "crypto/cipher"
+    "crypto/rand"
+    "crypto/sha256"
+    "errors"
+    "fmt"
+    "io"
+    "os"
+    "path/filepath"
+    "regexp"
+    "sort"
+    "strings"
+    "sync"
+
+    "github.com/gotd/td/session"
+)
+
+// sessionFileExt 会话文件扩展名
+const sessionFileExt = ".session"
+
+// phonePattern 合法的电话号码格式，同时防止路径穿越和PocketBase过滤注入
+var phonePattern = regexp.MustCompile(`^\+?[0-9]{5,20}$`)
+
+// ValidatePhoneNumber 校验电话号码格式
+// @param phoneNumber 电话号码
+// @return error 格式不合法时返回错误
+func ValidatePhoneNumber(phoneNumber string) error {
+    if !phonePattern.MatchString(phoneNumber) {
+        return fmt.Errorf("invalid phone number: %q", phoneNumber)
+    }
+    return nil
+}
+
+// SessionStore 定义MTProto会话的持久化接口
+// @author fcj
+// @date 2023-11-15
+// @version 1.0.0
+type SessionStore interface {
+    // Load 读取会话数据，不存在时返回 session.ErrNotFound
+    Load(ctx context.Context, phoneNumber string) ([]byte, error)
+
+    // Store 保存会话数据
+    Store(ctx context.Context, phoneNumber string, data []byte) error
+
+    // Delete 删除会话数据，不存在时不报错
+    Delete(ctx context.Context, phoneNumber string) error
+
+    // List 列出所有已保存会话的电话号码
+    List(ctx context.Context) ([]string, error)
+}
+
+// phoneSessionStorage 将SessionStore适配为单个账号的gotd session.Storage
+type phoneSessionStorage struct {
+    store       SessionStore
+    phoneNumber string
+}
+
+// LoadSession 实现 session.Storage
+func (p *phoneSessionStorage) LoadSession(ctx context.Context) ([]byte, error) {
+    return p.store.Load(ctx, p.phoneNumber)
+}
+
+// StoreSession 实现 session.Storage
+func (p *phoneSessionStorage) StoreSession(ctx context.Context, data []byte) error {
+    return p.store.Store(ctx, p.phoneNumber, data)
+}
+
+// sessionCipher 使用AES-256-GCM加密会话数据，密钥由口令经SHA-256派生
+type sessionCipher struct {
+    aead cipher.AEAD
+}
+
+// newSessionCipher 创建会话加密器
+// @param key 加密口令
+// @return *sessionCipher 加密器
+// @return error 错误信息
+func newSessionCipher(key string) (*sessionCipher, error) {
+    if key == "" {
+        return nil, errors.New("session encryption key is required")
+    }
+    sum := sha256.Sum256([]byte(key))
+    block, err := aes.NewCipher(sum[:])
+    if err != nil {
+        return nil, fmt.Errorf("failed to create cipher: %w", err)
+    }
+    aead, err := cipher.NewGCM(block)
+    if err != nil {
+        return nil, fmt.Errorf("failed to create GCM: %w", err)
+    }
+    return &sessionCipher{aead: aead}, nil
+}
+
+// seal 加密数据，输出为 nonce + 密文；电话号码作为附加数据，防止会话被挪用到其他账号
+func (c *sessionCipher) seal(phoneNumber string, plaintext []byte) ([]byte, error) {
+    nonce := make([]byte, c.aead.NonceSize())
+    if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+        return nil, fmt.Errorf("failed to generate nonce: %w", err)
+    }
+    return c.aead.Seal(nonce, nonce, plaintext, []byte(phoneNumber)), nil
+}
+
+// open 解密 seal 生成的数据
+func (c *sessionCipher) open(phoneNumber string, sealed []byte) ([]byte, error) {
+    nonceSize := c.aead.NonceSize()
+    if len(sealed) < nonceSize {
+        return nil, errors.New("session data is too short")
+    }
+    plaintext, err := c.aead.Open(nil, sealed[:nonceSize], sealed[nonceSize:], []byte(phoneNumber))
+    if err != nil {
+        return nil, fmt.Errorf("failed to decrypt session (wrong encryption key?): %w", err)
+    }
+    return plaintext, nil
+}
+
+// fileSessionStore 将加密后的会话保存为 <dir>/<phone>.session
+// @author fcj
+// @date 2023-11-15
+// @version 1.0.0
+type fileSessionStore struct {
+    dir    string
+    cipher *sessionCipher
+    mutex  sync.Mutex
+}
+
+// NewFileSessionStore 创建加密文件会话存储
+// @param dir 会话文件目录
+// @param key 加密口令
+// @return SessionStore 会话存储
+// @return error 错误信息
+func NewFileSessionStore(dir string, key string) (SessionStore, error) {
+    c, err := newSessionCipher(key)
+    if err != nil {
+        return nil, err
+    }
+    if dir == "" {
+        dir = "sessions"
+    }
+    if err := os.MkdirAll(dir, 0o700); err != nil {
+        return nil, fmt.Errorf("failed to create session directory: %w", err)
+    }
+    return &fileSessionStore{dir: dir, cipher: c}, nil
+}
+
+func (f *fileSessionStore) path(phoneNumber string) string {
+    return filepath.Join(f.dir, phoneNumber+sessionFileExt)
+}
+
+// Load 读取并解密会话文件
+func (f *fileSessionStore) Load(ctx context.Context, phoneNumber string) ([]byte, error) {
+    if err := ValidatePhoneNumber(phoneNumber); err != nil {
+        return nil, err
+    }
+    f.mutex.Lock()
+    defer f.mutex.Unlock()
+
+    sealed, err := os.ReadFile(f.path(phoneNumber))
+    if errors.Is(err, os.ErrNotExist) {
+        return nil, session.ErrNotFound
+    }
+    if err != nil {
+        return nil, fmt.Errorf("failed to read session file: %w", err)
+    }
+    return f.cipher.open(phoneNumber, sealed)
+}
+
+// Store 加密后写入临时文件再重命名，避免进程中断时留下损坏的会话文件
+func (f *fileSessionStore) Store(ctx context.Context, phoneNumber string, data []byte) error {
+    if err := ValidatePhoneNumber(phoneNumber); err != nil {
+        return err
+    }
+    sealed, err := f.cipher.seal(phoneNumber, data)
+    if err != nil {
+        return err
+    }
+    f.mutex.Lock()
+    defer f.mutex.Unlock()
+
+    tmp, err := os.CreateTemp(f.dir, phoneNumber+".*.tmp")
+    if err != nil {
+        return fmt.Errorf("failed to create temp session file: %w", err)
+    }
+    defer os.Remove(tmp.Name())
+    if _, err := tmp.Write(sealed); err != nil {
+        tmp.Close()
+        return fmt.Errorf("failed to write session file: %w", err)
+    }
+    if err := tmp.Close(); err != nil {
+        return fmt.Errorf("failed to write session file: %w", err)
+    }
+    if err := os.Rename(tmp.Name(), f.path(phoneNumber)); err != nil {
+        return fmt.Errorf("failed to replace session file: %w", err)
+    }
+    return nil
+}
+
+// Delete 删除会话文件
+func (f *fileSessionStore) Delete(ctx context.Context, phoneNumber string) error {
+    if err := ValidatePhoneNumber(phoneNumber); err != nil {
+        return err
+    }
+    f.mutex.Lock()
+    defer f.mutex.Unlock()
+
+    if err := os.Remove(f.path(phoneNumber)); err != nil && !errors.Is(err, os.ErrNotExist) {
+        return fmt.Errorf("failed to delete session file: %w", err)
+    }
+    return nil
+}
+
+// List 列出会话目录中的电话号码
+func (f *fileSessionStore) List(ctx context.Context) ([]string, error) {
+    entries, err := os.ReadDir(f.dir)
+    if err != nil {
+        return nil, fmt.Errorf("failed to read session directory: %w", err)
+    }
+    var phoneNumbers []string
+    for _, entry := range entries {
+        name := entry.Name()
+        if entry.IsDir() || !strings.HasSuffix(name, sessionFileExt) {
+            continue
+        }
+        phoneNumber := strings.TrimSuffix(name, sessionFileExt)
+        if ValidatePhoneNumber(phoneNumber) == nil {
+            phoneNumbers = append(phoneNumbers, phoneNumber)
+        }
+    }
+    sort.Strings(phoneNumbers)
+    return phoneNumbers, nil
+}
+
+/*
+ * 关键算法说明：
+ * 1. 会话适配：phoneSessionStorage 将按电话号码索引的存储适配为 gotd 的 session.Storage，gotd 在连接和认证后自动写入会话
+ * 2. 加密存储：会话中包含账号的授权密钥，落盘前使用 AES-256-GCM 加密，并以电话号码作为附加数据绑定账号
+ * 3. 原子写入：先写临时文件再重命名，保证会话文件始终完整
+ *
+ * 兼容性说明：
+ * 1. 更换加密口令后旧会话无法解密，需要重新登录
+ */
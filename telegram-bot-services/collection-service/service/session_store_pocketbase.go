@@ -0,0 +1,199 @@
+/*
+ * 文件功能描述：基于PocketBase的MTProto会话存储，适用于多实例或无持久磁盘的部署
+ * 主要类/接口说明：pocketBaseSessionStore 实现 SessionStore 接口
+ * 修改历史记录：
+ * @author fcj
+ * @date 2023-11-15
+ * @version 1.0.0
+ * © Telegram Bot Services Team
+ */
+
+package service
+
+import (
+    "context"
+    "crypto/sha1"
+    "encoding/base64"
+    "fmt"
+    "math/big"
+    "net/http"
+    "sort"
+    "strconv"
+
+    "github.com/go-resty/resty/v2"
+    "github.com/gotd/td/session"
+)
+
+// pocketBaseRecordIDLength PocketBase 记录ID长度（15位 [a-z0-9]）
+const pocketBaseRecordIDLength = 15
+
+// pocketBaseSessionStore 将加密后的会话保存到 PocketBase 的 telegram_sessions 集合
+// @author fcj
+// @date 2023-11-15
+// @version 1.0.0
+type pocketBaseSessionStore struct {
+    client  *resty.Client
+    baseURL string
+    token   string
+    cipher  *sessionCipher
+}
+
+// NewPocketBaseSessionStore 创建PocketBase会话存储
+// @param baseURL PocketBase 地址
+// @param token PocketBase 超级用户令牌（集合不对外开放）
+// @param key 加密口令
+// @return SessionStore 会话存储
+// @return error 错误信息
+func NewPocketBaseSessionStore(baseURL string, token string, key string) (SessionStore, error) {
+    c, err := newSessionCipher(key)
+    if err != nil {
+        return nil, err
+    }
+    return &pocketBaseSessionStore{
+        client:  resty.New(),
+        baseURL: baseURL,
+        token:   token,
+        cipher:  c,
+    }, nil
+}
+
+func (p *pocketBaseSessionStore) recordsURL() string {
+    return p.baseURL + "/api/collections/telegram_sessions/records"
+}
+
+// recordID 由电话号码确定记录ID，保存时无需先查询
+func (p *pocketBaseSessionStore) recordID(phoneNumber string) string {
+    return pocketBaseRecordID(phoneNumber)
+}
+
+// pocketBaseRecordID 由业务键派生确定的 PocketBase 记录ID
+func pocketBaseRecordID(key string) string {
+    sum := sha1.Sum([]byte(key))
+    id := new(big.Int).SetBytes(sum[:]).Text(36)
+    return id[len(id)-pocketBaseRecordIDLength:]
+}
+
+// Load 读取并解密会话
+func (p *pocketBaseSessionStore) Load(ctx context.Context, phoneNumber string) ([]byte, error) {
+    if err := ValidatePhoneNumber(phoneNumber); err != nil {
+        return nil, err
+    }
+    var record struct {
+        Phone string `json:"phone"`
+        Data  string `json:"data"`
+    }
+    resp, err := p.client.R().
+        SetContext(ctx).
+        SetHeader("Authorization", "Bearer "+p.token).
+        SetResult(&record).
+        Get(p.recordsURL() + "/" + p.recordID(phoneNumber))
+    if err != nil {
+        return nil, fmt.Errorf("failed to connect to PocketBase: %w", err)
+    }
+    if resp.StatusCode() == http.StatusNotFound {
+        return nil, session.ErrNotFound
+    }
+    if resp.IsError() {
+        return nil, fmt.Errorf("PocketBase returned status code: %d, body: %s", resp.StatusCode(), resp.Body())
+    }
+    sealed, err := base64.StdEncoding.DecodeString(record.Data)
+    if err != nil {
+        return nil, fmt.Errorf("failed to decode session data: %w", err)
+    }
+    return p.cipher.open(phoneNumber, sealed)
+}
+
+// Store 加密后保存会话，记录存在时更新，否则创建
+func (p *pocketBaseSessionStore) Store(ctx context.Context, phoneNumber string, data []byte) error {
+    if err := ValidatePhoneNumber(phoneNumber); err != nil {
+        return err
+    }
+    sealed, err := p.cipher.seal(phoneNumber, data)
+    if err != nil {
+        return err
+    }
+    id := p.recordID(phoneNumber)
+    body := map[string]interface{}{
+        "phone": phoneNumber,
+        "data":  base64.StdEncoding.EncodeToString(sealed),
+    }
+
+    resp, err := p.client.R().
+        SetContext(ctx).
+        SetHeader("Authorization", "Bearer "+p.token).
+        SetBody(body).
+        Patch(p.recordsURL() + "/" + id)
+    if err != nil {
+        return fmt.Errorf("failed to connect to PocketBase: %w", err)
+    }
+    if resp.StatusCode() == http.StatusNotFound {
+        body["id"] = id
+        resp, err = p.client.R().
+            SetContext(ctx).
+            SetHeader("Authorization", "Bearer "+p.token).
+            SetBody(body).
+            Post(p.recordsURL())
+        if err != nil {
+            return fmt.Errorf("failed to connect to PocketBase: %w", err)
+        }
+    }
+    if resp.IsError() {
+        return fmt.Errorf("PocketBase returned status code: %d, body: %s", resp.StatusCode(), resp.Body())
+    }
+    return nil
+}
+
+// Delete 删除会话记录
+func (p *pocketBaseSessionStore) Delete(ctx context.Context, phoneNumber string) error {
+    if err := ValidatePhoneNumber(phoneNumber); err != nil {
+        return err
+    }
+    resp, err := p.client.R().
+        SetContext(ctx).
+        SetHeader("Authorization", "Bearer "+p.token).
+        Delete(p.recordsURL() + "/" + p.recordID(phoneNumber))
+    if err != nil {
+        return fmt.Errorf("failed to connect to PocketBase: %w", err)
+    }
+    if resp.IsError() && resp.StatusCode() != http.StatusNotFound {
+        return fmt.Errorf("PocketBase returned status code: %d, body: %s", resp.StatusCode(), resp.Body())
+    }
+    return nil
+}
+
+// List 分页列出所有会话的电话号码
+func (p *pocketBaseSessionStore) List(ctx context.Context) ([]string, error) {
+    var phoneNumbers []string
+    for page := 1; ; page++ {
+        var result struct {
+            TotalPages int `json:"totalPages"`
+            Items      []struct {
+                Phone string `json:"phone"`
+            } `json:"items"`
+        }
+        resp, err := p.client.R().
+            SetContext(ctx).
+            SetHeader("Authorization", "Bearer "+p.token).
+            SetQueryParams(map[string]string{
+                "page":      strconv.Itoa(page),
+                "perPage":   "200",
+                "fields":    "phone",
+            }).
+            SetResult(&result).
+            Get(p.recordsURL())
+        if err != nil {
+            return nil, fmt.Errorf("failed to connect to PocketBase: %w", err)
+        }
+        if resp.IsError() {
+            return nil, fmt.Errorf("PocketBase returned status code: %d, body: %s", resp.StatusCode(), resp.Body())
+        }
+        for _, item := range result.Items {
+            phoneNumbers = append(phoneNumbers, item.Phone)
+        }
+        if page >= result.TotalPages {
+            break
+        }
+    }
+    sort.Strings(phoneNumbers)
+    return phoneNumbers, nil
+}
@@ -11,8 +11,13 @@
 package service
 
 import (
+    "context"
     "fmt"
     "log"
+    "net/http"
+    "time"
+
+    "telegram-bot-services/pkg/search"
 
     "github.com/go-resty/resty/v2"
 )
@@ -22,9 +27,28 @@ import (
 // @date 2023-11-15
 // @version 1.0.0
 type StorageConfig struct {
-    PocketBaseURL string
+    PocketBaseURL    string
+    PocketBaseToken  string
+    MeilisearchURL   string
+    MeilisearchToken string
+    // IndexName 消息索引名称，默认 messages，与机器人服务共用
+    IndexName string
 }
 
+const (
+    // chatListPageSize 列出收录群组时每页的记录数
+    chatListPageSize = 200
+    // chatSnapshotPageSize 读取每日快照时每页的记录数，覆盖 30 天的窗口
+    chatSnapshotPageSize = 100
+    // pocketBaseBatchSize PocketBase 批量接口单次请求的记录数，不超过其默认上限（50）；
+    // 消息和 access hash 缓存的批量写入共用
+    pocketBaseBatchSize = 50
+    // DefaultIndexName 消息索引的默认名称
+    DefaultIndexName = search.DefaultMessagesIndex
+    // taskTimeout 等待单个 Meilisearch 任务完成的最长时间
+    taskTimeout = time.Minute
+)
+
 // StorageService 定义存储服务接口
 // @author fcj
 // @date 2023-11-15
@@ -33,11 +57,65 @@ type StorageService interface {
     // SaveToPocketBase 保存消息到PocketBase
     SaveToPocketBase(data map[string]interface{}) error
     
-    // IndexToMeilisearch 将消息索引到Meilisearch
-    IndexToMeilisearch(data map[string]interface{}) error
+    // SaveMessages 通过PocketBase批量接口写入messages集合，记录须包含确定的 id，重复写入时更新
+    SaveMessages(records []map[string]interface{}) error
+    
+    // DeleteMessages 按记录ID删除messages集合中的消息，记录不存在时忽略
+    DeleteMessages(ids []string) error
+    
+    // ConfigureMessagesIndex 创建消息索引并设置可搜索、可过滤和可排序属性，等待任务完成
+    ConfigureMessagesIndex() error
+    
+    // IndexMessages 批量写入消息索引，文档须包含 id（<chat_id>_<message_id>），等待任务完成
+    IndexMessages(docs []search.Message) error
     
-    // SaveAndIndex 保存并索引消息
-    SaveAndIndex(data map[string]interface{}) error
+    // DeleteIndexedMessages 按文档ID从消息索引中删除，等待任务完成
+    DeleteIndexedMessages(ids []string) error
+    
+    // ListIndexedChats 列出 telegram_index 中收录的群组和频道（不含机器人）
+    ListIndexedChats() ([]IndexedChat, error)
+    
+    // UpdateIndexedChat 更新 telegram_index 记录及其 Meilisearch 文档的部分字段，等待索引任务完成
+    UpdateIndexedChat(chat IndexedChat, fields map[string]interface{}) error
+    
+    // SaveChatSnapshot 写入群组的每日快照，同一群组同一天重复写入时更新
+    SaveChatSnapshot(snapshot ChatSnapshot) error
+    
+    // ListChatSnapshots 按日期升序列出群组自 since（YYYY-MM-DD，含）以来的每日快照
+    ListChatSnapshots(chatID string, since string) ([]ChatSnapshot, error)
+}
+
+// IndexedChat telegram_index 中收录的群组或频道
+// @author fcj
+// @date 2023-11-15
+// @version 1.0.0
+type IndexedChat struct {
+    // RecordID PocketBase 记录ID
+    RecordID string `json:"id"`
+    // ChatID Bot API 格式的会话ID，也是 Meilisearch 文档ID
+    ChatID       string  `json:"chat_id"`
+    Type         string  `json:"type"`
+    Title    string `json:"title"`
+    Username string `json:"username"`
+}
+
+// ChatSnapshot chat_stats_daily 中群组某一天（UTC）的成员数和消息数快照
+// @author fcj
+// @date 2023-11-15
+// @version 1.0.0
+type ChatSnapshot struct {
+    // ChatID Bot API 格式的会话ID
+    ChatID string `json:"chat_id"`
+    // Date 快照日期，格式 YYYY-MM-DD
+    Date          string `json:"date"`
+    MembersCount  int    `json:"members_count"`
+    MessageCount  int    `json:"message_count"`
+    OnlineCount   int    `json:"online_count"`
+    ActiveMembers int    `json:"active_members"`
+    // MembersDelta 相对前一个快照的成员数变化
+    MembersDelta int `json:"members_delta"`
+    // MessagesDelta 相对前一个快照的消息数变化
+    MessagesDelta int `json:"messages_delta"`
 }
 
 // storageServiceImpl 实现StorageService接口
@@ -47,6 +125,7 @@ type StorageService interface {
 type storageServiceImpl struct {
     config StorageConfig
     client *resty.Client
+    meili  *search.Client
 }
 
 // NewStorageService 创建新的存储服务实例
@@ -56,9 +135,18 @@ type storageServiceImpl struct {
 // @param config 存储服务配置
 // @return StorageService 存储服务实例
 func NewStorageService(config StorageConfig) StorageService {
+    if config.IndexName == "" {
+        config.IndexName = DefaultIndexName
+    }
     return &storageServiceImpl{
         config: config,
         client: resty.New(),
+        meili: search.New(search.Config{
+            URL:           config.MeilisearchURL,
+            Key:           config.MeilisearchToken,
+            MessagesIndex: config.IndexName,
+            TaskTimeout:   taskTimeout,
+        }),
     }
 }
 
@@ -70,6 +158,7 @@ func NewStorageService(config StorageConfig) StorageService {
 // @return error 错误信息
 func (s *storageServiceImpl) SaveToPocketBase(data map[string]interface{}) error {
     resp, err := s.client.R().
+        SetHeader("Authorization", "Bearer "+s.config.PocketBaseToken).
         SetBody(data).
         Post(s.config.PocketBaseURL + "/api/collections/messages/records")
     if err != nil {
@@ -84,46 +173,245 @@ func (s *storageServiceImpl) SaveToPocketBase(data map[string]interface{}) error
     return nil
 }
 
-// IndexToMeilisearch 将消息索引到Meilisearch
+// SaveMessages 通过PocketBase批量接口写入messages集合
 // @author fcj
 // @date 2023-11-15
 // @version 1.0.0
-// @param data 需要索引的消息数据
+// @param records 消息记录，每条记录须包含确定的 id 字段
 // @return error 错误信息
-func (s *storageServiceImpl) IndexToMeilisearch(data map[string]interface{}) error {
-    // Meilisearch配置应从CollectionService获取，此处不再处理
+func (s *storageServiceImpl) SaveMessages(records []map[string]interface{}) error {
+    for start := 0; start < len(records); start += pocketBaseBatchSize {
+        end := start + pocketBaseBatchSize
+        if end > len(records) {
+            end = len(records)
+        }
+        requests := make([]map[string]interface{}, 0, end-start)
+        for _, record := range records[start:end] {
+            // PUT 为 upsert，重复采集同一条消息时更新而不是报唯一索引冲突
+            requests = append(requests, map[string]interface{}{
+                "method": http.MethodPut,
+                "url":    "/api/collections/messages/records",
+                "body":   record,
+            })
+        }
+        resp, err := s.client.R().
+            SetHeader("Authorization", "Bearer "+s.config.PocketBaseToken).
+            SetBody(map[string]interface{}{"requests": requests}).
+            Post(s.config.PocketBaseURL + "/api/batch")
+        if err != nil {
+            return fmt.Errorf("failed to connect to PocketBase: %w", err)
+        }
+        if resp.IsError() {
+            return fmt.Errorf("PocketBase batch returned status code: %d, body: %s", resp.StatusCode(), resp.Body())
+        }
+    }
     return nil
 }
 
-// SaveAndIndex 保存并索引消息
+// DeleteMessages 按记录ID删除消息
 // @author fcj
 // @date 2023-11-15
 // @version 1.0.0
-// @param data 需要保存和索引的消息数据
+// @param ids 记录ID列表
 // @return error 错误信息
-func (s *storageServiceImpl) SaveAndIndex(data map[string]interface{}) error {
-    // 保存到PocketBase
-    if err := s.SaveToPocketBase(data); err != nil {
-        return fmt.Errorf("failed to save to PocketBase: %w", err)
+func (s *storageServiceImpl) DeleteMessages(ids []string) error {
+    // 逐条删除：批量接口是事务性的，未采集过的消息（404）会导致整批失败
+    for _, id := range ids {
+        resp, err := s.client.R().
+            SetHeader("Authorization", "Bearer "+s.config.PocketBaseToken).
+            Delete(s.config.PocketBaseURL + "/api/collections/messages/records/" + id)
+        if err != nil {
+            return fmt.Errorf("failed to connect to PocketBase: %w", err)
+        }
+        if resp.IsError() && resp.StatusCode() != http.StatusNotFound {
+            return fmt.Errorf("PocketBase returned status code: %d, body: %s", resp.StatusCode(), resp.Body())
+        }
     }
-    
-    // 索引到Meilisearch
-    // Meilisearch的索引操作现在由CollectionService负责，StorageService不再直接处理
-    // 因此，这里不再调用s.IndexToMeilisearch(data)
+    return nil
+}
 
-    
+// ConfigureMessagesIndex 创建消息索引并设置属性，属性与机器人服务共用 search.MessageSettings
+// @author fcj
+// @date 2023-11-15
+// @version 1.0.0
+// @return error 错误信息
+func (s *storageServiceImpl) ConfigureMessagesIndex() error {
+    // 索引已存在（例如由机器人服务创建）时只更新设置
+    return s.meili.EnsureIndex(context.Background(), s.config.IndexName, search.MessageSettings)
+}
+
+// IndexMessages 按批提交文档到消息索引，并等待每批任务完成
+// @author fcj
+// @date 2023-11-15
+// @version 1.0.0
+// @param docs 消息文档
+// @return error 错误信息
+func (s *storageServiceImpl) IndexMessages(docs []search.Message) error {
+    return s.meili.AddDocuments(context.Background(), s.config.IndexName, docs)
+}
+
+// DeleteIndexedMessages 按文档ID从消息索引中删除
+// @author fcj
+// @date 2023-11-15
+// @version 1.0.0
+// @param ids 文档ID列表
+// @return error 错误信息
+func (s *storageServiceImpl) DeleteIndexedMessages(ids []string) error {
+    return s.meili.DeleteDocuments(context.Background(), s.config.IndexName, ids)
+}
+
+// ListIndexedChats 分页列出 telegram_index 中的群组和频道
+// @author fcj
+// @date 2023-11-15
+// @version 1.0.0
+// @return []IndexedChat 收录的群组和频道
+// @return error 错误信息
+func (s *storageServiceImpl) ListIndexedChats() ([]IndexedChat, error) {
+    var chats []IndexedChat
+    for page := 1; ; page++ {
+        var result struct {
+            Items      []IndexedChat `json:"items"`
+            TotalPages int           `json:"totalPages"`
+        }
+        resp, err := s.client.R().
+            SetHeader("Authorization", "Bearer "+s.config.PocketBaseToken).
+            SetQueryParams(map[string]string{
+                "page":      fmt.Sprint(page),
+                "perPage":   fmt.Sprint(chatListPageSize),
+                "filter":    "type!='bot'",
+                "sort":      "last_calculated",
+            }).
+            SetResult(&result).
+            Get(s.config.PocketBaseURL + "/api/collections/telegram_index/records")
+        if err != nil {
+            return nil, fmt.Errorf("failed to connect to PocketBase: %w", err)
+        }
+        if resp.IsError() {
+            return nil, fmt.Errorf("PocketBase returned status code: %d, body: %s", resp.StatusCode(), resp.Body())
+        }
+        chats = append(chats, result.Items...)
+        if page >= result.TotalPages {
+            return chats, nil
+        }
+    }
+}
+
+// UpdateIndexedChat 更新 telegram_index 记录，并以部分更新的方式写入 Meilisearch 文档
+// @author fcj
+// @date 2023-11-15
+// @version 1.0.0
+// @param chat 收录的群组或频道
+// @param fields 需要更新的字段
+// @return error 错误信息
+func (s *storageServiceImpl) UpdateIndexedChat(chat IndexedChat, fields map[string]interface{}) error {
+    resp, err := s.client.R().
+        SetHeader("Authorization", "Bearer "+s.config.PocketBaseToken).
+        SetBody(fields).
+        Patch(s.config.PocketBaseURL + "/api/collections/telegram_index/records/" + chat.RecordID)
+    if err != nil {
+        return fmt.Errorf("failed to connect to PocketBase: %w", err)
+    }
+    if resp.IsError() {
+        return fmt.Errorf("PocketBase returned status code: %d, body: %s", resp.StatusCode(), resp.Body())
+    }
+
+    // 没有会话ID的旧记录没有对应的 Meilisearch 文档
+    if chat.ChatID == "" {
+        return nil
+    }
+    doc := make(map[string]interface{}, len(fields)+1)
+    for key, value := range fields {
+        doc[key] = value
+    }
+    doc["id"] = chat.ChatID
+    // 部分更新只写入文档中给出的字段，保留机器人服务写入的其他字段
+    return s.meili.UpdateDocuments(context.Background(), search.DefaultChatsIndex, []map[string]interface{}{doc})
+}
+
+// SaveChatSnapshot 写入群组的每日快照
+// @author fcj
+// @date 2023-11-15
+// @version 1.0.0
+// @param snapshot 每日快照
+// @return error 错误信息
+func (s *storageServiceImpl) SaveChatSnapshot(snapshot ChatSnapshot) error {
+    record := map[string]interface{}{
+        // 记录ID由会话ID和日期确定，同一天多次刷新时覆盖为最新的数据
+        "id":             pocketBaseRecordID(snapshot.ChatID + "_" + snapshot.Date),
+        "chat_id":        snapshot.ChatID,
+        "date":           snapshot.Date,
+        "members_count":  snapshot.MembersCount,
+        "message_count":  snapshot.MessageCount,
+        "online_count":   snapshot.OnlineCount,
+        "active_members": snapshot.ActiveMembers,
+        "members_delta":  snapshot.MembersDelta,
+        "messages_delta": snapshot.MessagesDelta,
+    }
+    // 单条记录也使用批量接口的 PUT（upsert）
+    resp, err := s.client.R().
+        SetHeader("Authorization", "Bearer "+s.config.PocketBaseToken).
+        SetBody(map[string]interface{}{"requests": []map[string]interface{}{{
+            "method": http.MethodPut,
+            "url":    "/api/collections/chat_stats_daily/records",
+            "body":   record,
+        }}}).
+        Post(s.config.PocketBaseURL + "/api/batch")
+    if err != nil {
+        return fmt.Errorf("failed to connect to PocketBase: %w", err)
+    }
+    if resp.IsError() {
+        return fmt.Errorf("PocketBase batch returned status code: %d, body: %s", resp.StatusCode(), resp.Body())
+    }
     return nil
 }
 
+// ListChatSnapshots 按日期升序列出群组的每日快照
+// @author fcj
+// @date 2023-11-15
+// @version 1.0.0
+// @param chatID Bot API 格式的会话ID
+// @param since 起始日期（YYYY-MM-DD，含）
+// @return []ChatSnapshot 每日快照
+// @return error 错误信息
+func (s *storageServiceImpl) ListChatSnapshots(chatID string, since string) ([]ChatSnapshot, error) {
+    var snapshots []ChatSnapshot
+    for page := 1; ; page++ {
+        var result struct {
+            Items      []ChatSnapshot `json:"items"`
+            TotalPages int            `json:"totalPages"`
+        }
+        resp, err := s.client.R().
+            SetHeader("Authorization", "Bearer "+s.config.PocketBaseToken).
+            SetQueryParams(map[string]string{
+                "page":    fmt.Sprint(page),
+                "perPage": fmt.Sprint(chatSnapshotPageSize),
+                "filter":  fmt.Sprintf("chat_id='%s' && date>='%s'", chatID, since),
+                "sort":    "date",
+            }).
+            SetResult(&result).
+            Get(s.config.PocketBaseURL + "/api/collections/chat_stats_daily/records")
+        if err != nil {
+            return nil, fmt.Errorf("failed to connect to PocketBase: %w", err)
+        }
+        if resp.IsError() {
+            return nil, fmt.Errorf("PocketBase returned status code: %d, body: %s", resp.StatusCode(), resp.Body())
+        }
+        snapshots = append(snapshots, result.Items...)
+        if page >= result.TotalPages {
+            return snapshots, nil
+        }
+    }
+}
+
 /*
  * 关键算法说明：
- * 1. 数据存储：使用REST API将数据保存到PocketBase
- * 2. 数据索引：使用REST API将数据索引到Meilisearch
+ * 1. 数据存储：使用REST API将数据保存到PocketBase，采集的消息通过批量接口按确定的记录ID写入
+ * 2. 数据索引：文档按批提交到 Meilisearch 消息索引，主键为 <chat_id>_<message_id>，重复提交时覆盖
+ * 3. 任务确认：Meilisearch 写入通过共享的 pkg/search 客户端提交并等待任务完成，失败或超时时返回错误，调用方据此决定是否推进采集进度
  * 
  * 待优化事项：
- * 1. 批量操作：支持批量保存和索引
- * 2. 错误重试：添加重试机制处理临时网络故障
- * 3. 连接池：优化HTTP客户端连接池
+ * 1. 错误重试：添加重试机制处理临时网络故障
+ * 2. 连接池：优化HTTP客户端连接池
  * 
  * 兼容性说明：
  * 1. 依赖PocketBase和Meilisearch API
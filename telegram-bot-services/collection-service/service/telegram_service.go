@@ -12,14 +12,15 @@ package service
 
 import (
     "context"
+    "errors"
     "fmt"
     "log"
     "sync"
-    "time"
 
     "github.com/gotd/td/telegram/auth"
     tdapi "github.com/gotd/td/telegram"
     "github.com/gotd/td/tg"
+    "github.com/gotd/td/tgerr"
 )
 
 // TelegramConfig Telegram客户端配置
@@ -29,14 +30,48 @@ import (
 type TelegramConfig struct {
     AppID   int    `json:"app_id"`
     AppHash string `json:"app_hash"`
+    // History 历史消息增量采集与回填配置
+    History HistoryConfig `json:"-"`
+    // Media 媒体归档配置
+    Media MediaConfig `json:"-"`
 }
 
-// SessionData 会话数据结构
+// LoginStatus 登录流程状态：code_sent → (password_required →) logged_in
+type LoginStatus string
+
+const (
+    // LoginStatusCodeSent 验证码已发送，等待提交验证码
+    LoginStatusCodeSent LoginStatus = "code_sent"
+    // LoginStatusPasswordRequired 账号开启了两步验证，等待提交密码
+    LoginStatusPasswordRequired LoginStatus = "password_required"
+    // LoginStatusLoggedIn 登录成功
+    LoginStatusLoggedIn LoginStatus = "logged_in"
+    // LoginStatusSignUpRequired 号码未注册Telegram，采集账号不支持注册
+    LoginStatusSignUpRequired LoginStatus = "signup_required"
+    // LoginStatusCodeExpired 验证码已过期，需要重新发送
+    LoginStatusCodeExpired LoginStatus = "code_expired"
+    // LoginStatusCodeInvalid 验证码错误，可重新提交
+    LoginStatusCodeInvalid LoginStatus = "code_invalid"
+    // LoginStatusPasswordInvalid 两步验证密码错误，可重新提交
+    LoginStatusPasswordInvalid LoginStatus = "password_invalid"
+)
+
+// ErrNoLoginInProgress 没有处于对应步骤的登录流程
+var ErrNoLoginInProgress = errors.New("no login in progress")
+
+// LoginResult 登录步骤的结果
 // @author fcj
 // @date 2023-11-15
 // @version 1.0.0
-type SessionData struct {
-    Client *tdapi.Client
+type LoginResult struct {
+    Status       LoginStatus `json:"status"`
+    PasswordHint string      `json:"password_hint,omitempty"`
+}
+
+// loginState 进行中的登录流程
+type loginState struct {
+    status   LoginStatus
+    codeHash string
 }
 
 // TelegramService 定义Telegram客户端服务接口
@@ -48,19 +83,56 @@ type TelegramService interface {
     NewClient(phoneNumber string) (*tdapi.Client, error)
     
     // SendCode 发送验证码
-    SendCode(phoneNumber string) error
+    SendCode(phoneNumber string) (*LoginResult, error)
     
-    // Login 登录Telegram账号
-    Login(phoneNumber string, code string) error
+    // Login 使用验证码登录Telegram账号
+    Login(phoneNumber string, code string) (*LoginResult, error)
+    
+    // CheckPassword 提交两步验证密码
+    CheckPassword(phoneNumber string, password string) (*LoginResult, error)
     
     // IsAuthorized 检查客户端是否已认证
     IsAuthorized(phoneNumber string) (bool, error)
     
-    // CollectMessages 采集群组消息
+    // ResolvePeer 将用户名、t.me链接或数字ID解析为账号可访问的群组或频道
+    ResolvePeer(phoneNumber string, target string) (*ResolvedPeer, error)
+    
+    // CollectMessages 增量采集群组消息并继续回填历史，limit 为每页消息数（<=0 时使用配置）
     CollectMessages(phoneNumber string, chatID int64, limit int) error
     
     // CollectAllConfiguredMessages 采集所有配置的群组消息
     CollectAllConfiguredMessages(chatIDs []int64) error
+    
+    // WatchChats 设置账号通过实时更新采集的群组
+    WatchChats(phoneNumber string, chatIDs []int64)
+
+    // FetchChatMetadata 获取群组或频道的完整信息和近期活跃度
+    FetchChatMetadata(phoneNumber string, target string) (*ChatMetadata, error)
+    
+    // ClientHealth 返回所有账号的连接状态
+    ClientHealth() []ClientHealth
+
+    // Close 关闭所有账号连接
+    Close()
+
+    // RestoreSessions 为所有已持久化的会话启动连接，返回恢复的电话号码
+    RestoreSessions() ([]string, error)
+
+    // ListSessions 列出所有已持久化的会话
+    ListSessions() ([]SessionInfo, error)
+
+    // Logout 注销账号并删除持久化的会话
+    Logout(phoneNumber string) error
+}
+
+// SessionInfo 已持久化会话的概要信息
+// @author fcj
+// @date 2023-11-15
+// @version 1.0.0
+type SessionInfo struct {
+    PhoneNumber string        `json:"phone_number"`
+    Loaded      bool          `json:"loaded"`
+    Health      *ClientHealth `json:"health,omitempty"`
 }
 
 // telegramServiceImpl 实现TelegramService接口
@@ -69,8 +141,17 @@ type TelegramService interface {
 // @version 1.0.0
 type telegramServiceImpl struct {
     config      TelegramConfig
-    sessions    sync.Map
+    clients     ClientManager
+    logins      sync.Map // phoneNumber -> *loginState
     storageService StorageService
+    indexer     *indexBatcher
+    media       *mediaArchiver // 未启用媒体归档时为 nil
+    sessionStore SessionStore
+    peers       PeerResolver
+    cursors     CursorStore
+    updates     sync.Map // phoneNumber -> *updates.Manager
+    authorized  sync.Map // phoneNumber -> chan struct{}，登录成功时关闭
+    watched     sync.Map // phoneNumber -> map[int64]bool
 }
 
 // NewTelegramService 创建新的Telegram客户端服务实例
@@ -79,13 +160,26 @@ type telegramServiceImpl struct {
 // @version 1.0.0
 // @param config Telegram客户端配置
 // @param storageService 存储服务
+// @param sessionStore MTProto会话存储
+// @param peerStore 群组/频道 access hash 缓存存储
+// @param cursorStore 群组采集进度存储
+// @param mediaStore 媒体归档的对象存储，为 nil 时不归档媒体
+// @param ctx 服务上下文，取消时关闭所有账号连接
 // @return TelegramService Telegram客户端服务实例
-func NewTelegramService(config TelegramConfig, storageService StorageService) TelegramService {
-    return &telegramServiceImpl{
+func NewTelegramService(ctx context.Context, config TelegramConfig, storageService StorageService, sessionStore SessionStore, peerStore PeerStore, cursorStore CursorStore, mediaStore ObjectStore) TelegramService {
+    t := &telegramServiceImpl{
         config:      config,
-        sessions:    sync.Map{},
         storageService: storageService,
+        indexer:     newIndexBatcher(storageService),
+        sessionStore: sessionStore,
+        peers:       NewPeerResolver(peerStore),
+        cursors:     cursorStore,
+    }
+    if config.Media.Enabled && mediaStore != nil {
+        t.media = newMediaArchiver(config.Media, mediaStore)
     }
+    t.clients = NewClientManager(ctx, t.NewClient, t.runUpdates)
+    return t
 }
 
 // NewClient 创建新的Telegram客户端
@@ -96,125 +190,175 @@ func NewTelegramService(config TelegramConfig, storageService StorageService) Te
 // @return *tdapi.Client Telegram客户端实例
 // @return error 错误信息
 func (t *telegramServiceImpl) NewClient(phoneNumber string) (*tdapi.Client, error) {
-    if phoneNumber == "" {
-        return nil, fmt.Errorf("phone number cannot be empty")
+    if err := ValidatePhoneNumber(phoneNumber); err != nil {
+        return nil, err
     }
     
-    // 创建新的Telegram客户端
-    client := tdapi.NewClient(t.config.AppID, t.config.AppHash, tdapi.Options{})
+    // 创建新的Telegram客户端，会话（授权密钥等）由gotd自动写入持久化存储；更新交给账号的更新管理器处理
+    client := tdapi.NewClient(t.config.AppID, t.config.AppHash, tdapi.Options{
+        SessionStorage: &phoneSessionStorage{store: t.sessionStore, phoneNumber: phoneNumber},
+        UpdateHandler:  t.updateManager(phoneNumber),
+    })
     return client, nil
 }
 
-// SendCode 发送验证码
+// SendCode 发送验证码，开始登录流程
 // @author fcj
 // @date 2023-11-15
 // @version 1.0.0
 // @param phoneNumber 电话号码
+// @return *LoginResult 登录状态：code_sent，已登录时为 logged_in
 // @return error 错误信息
-func (t *telegramServiceImpl) SendCode(phoneNumber string) error {
-    // 检查是否已经有会话
-    sessionInterface, exists := t.sessions.Load(phoneNumber)
-    
-    // 创建或获取客户端
-    var client *tdapi.Client
+func (t *telegramServiceImpl) SendCode(phoneNumber string) (*LoginResult, error) {
+    if err := ValidatePhoneNumber(phoneNumber); err != nil {
+        return nil, err
+    }
     
-    if exists {
-        // 获取客户端实例
-        switch v := sessionInterface.(type) {
-        case *tdapi.Client:
-            client = v
-        case SessionData:
-            client = v.Client
-        default:
-            return fmt.Errorf("invalid session data type for phone %s: %T", phoneNumber, sessionInterface)
-        }
-        
-        // 检查客户端是否已认证
-        isAuthorized, err := t.IsAuthorized(phoneNumber)
-        if err == nil && isAuthorized {
-            return fmt.Errorf("already logged in")
-        }
-    } else {
-        // 创建新客户端
-        var err error
-        client, err = t.NewClient(phoneNumber)
-        if err != nil {
-            return fmt.Errorf("failed to create client: %w", err)
-        }
+    // 检查客户端是否已认证
+    isAuthorized, err := t.IsAuthorized(phoneNumber)
+    if err != nil {
+        return nil, err
+    }
+    if isAuthorized {
+        return &LoginResult{Status: LoginStatusLoggedIn}, nil
     }
     
-    // 发送验证码
-    err := client.Run(context.Background(), func(ctx context.Context) error {
-        _, err := client.Auth().SendCode(ctx, phoneNumber, auth.SendCodeOptions{})
+    // 发送验证码并记录 phone_code_hash；验证码与授权密钥绑定，后续步骤复用同一连接
+    var sentCode tg.AuthSentCodeClass
+    err = t.clients.Do(context.Background(), phoneNumber, func(ctx context.Context, client *tdapi.Client) error {
+        var err error
+        sentCode, err = client.Auth().SendCode(ctx, phoneNumber, auth.SendCodeOptions{})
         if err != nil {
             return fmt.Errorf("failed to send verification code: %w", err)
         }
         return nil
     })
-    
     if err != nil {
-        return err
+        return nil, err
     }
     
-    // 存储客户端
-    sessionData := SessionData{
-        Client: client,
+    switch code := sentCode.(type) {
+    case *tg.AuthSentCode:
+        t.logins.Store(phoneNumber, &loginState{
+            status:   LoginStatusCodeSent,
+            codeHash: code.PhoneCodeHash,
+        })
+        return &LoginResult{Status: LoginStatusCodeSent}, nil
+    case *tg.AuthSentCodeSuccess:
+        // 使用 future auth token 时服务端直接完成登录
+        t.logins.Delete(phoneNumber)
+        t.notifyAuthorized(phoneNumber)
+        return &LoginResult{Status: LoginStatusLoggedIn}, nil
+    default:
+        return nil, fmt.Errorf("unexpected sent code type: %T", sentCode)
     }
-    t.sessions.Store(phoneNumber, sessionData)
-    return nil
 }
 
-// Login 登录Telegram账号
+// Login 使用验证码登录Telegram账号
 // @author fcj
 // @date 2023-11-15
 // @version 1.0.0
 // @param phoneNumber 电话号码
 // @param code 验证码
+// @return *LoginResult 登录状态：logged_in、password_required、signup_required、code_expired 或 code_invalid
 // @return error 错误信息
-func (t *telegramServiceImpl) Login(phoneNumber string, code string) error {
-    // 获取会话
-    sessionInterface, exists := t.sessions.Load(phoneNumber)
-    if !exists {
-        return fmt.Errorf("no session found for phone %s", phoneNumber)
+func (t *telegramServiceImpl) Login(phoneNumber string, code string) (*LoginResult, error) {
+    state, err := t.pendingLogin(phoneNumber, LoginStatusCodeSent)
+    if err != nil {
+        return nil, err
     }
     
-    // 获取客户端实例
-    var client *tdapi.Client
-    switch v := sessionInterface.(type) {
-    case *tdapi.Client:
-        client = v
-    case SessionData:
-        client = v.Client
-    default:
-        return fmt.Errorf("invalid session data type for phone %s: %T", phoneNumber, sessionInterface)
+    result := &LoginResult{}
+    err = t.clients.Do(context.Background(), phoneNumber, func(ctx context.Context, client *tdapi.Client) error {
+        _, err := client.Auth().SignIn(ctx, phoneNumber, code, state.codeHash)
+        var signUpRequired *auth.SignUpRequired
+        switch {
+        case err == nil:
+            result.Status = LoginStatusLoggedIn
+        case errors.Is(err, auth.ErrPasswordAuthNeeded):
+            result.Status = LoginStatusPasswordRequired
+            // 读取密码提示，失败不影响流程
+            if password, err := client.API().AccountGetPassword(ctx); err == nil {
+                result.PasswordHint = password.Hint
+            }
+        case errors.As(err, &signUpRequired):
+            result.Status = LoginStatusSignUpRequired
+        case tgerr.Is(err, "PHONE_CODE_EXPIRED"):
+            result.Status = LoginStatusCodeExpired
+        case tgerr.Is(err, "PHONE_CODE_INVALID", "PHONE_CODE_EMPTY"):
+            result.Status = LoginStatusCodeInvalid
+        default:
+            return err
+        }
+        return nil
+    })
+    if err != nil {
+        return nil, fmt.Errorf("login failed: %w", err)
     }
     
-    if client == nil {
-        return fmt.Errorf("no valid client found for phone %s", phoneNumber)
+    switch result.Status {
+    case LoginStatusPasswordRequired:
+        t.logins.Store(phoneNumber, &loginState{status: LoginStatusPasswordRequired, codeHash: state.codeHash})
+    case LoginStatusCodeInvalid:
+        // 验证码错误时保留状态，允许重新提交
+    default:
+        // 登录成功或流程终止（需重新发送验证码）
+        t.logins.Delete(phoneNumber)
+    }
+    if result.Status == LoginStatusLoggedIn {
+        t.notifyAuthorized(phoneNumber)
+    }
+    return result, nil
+}
+
+// CheckPassword 提交两步验证密码完成登录
+// @author fcj
+// @date 2023-11-15
+// @version 1.0.0
+// @param phoneNumber 电话号码
+// @param password 两步验证（云端）密码
+// @return *LoginResult 登录状态：logged_in 或 password_invalid
+// @return error 错误信息
+func (t *telegramServiceImpl) CheckPassword(phoneNumber string, password string) (*LoginResult, error) {
+    if _, err := t.pendingLogin(phoneNumber, LoginStatusPasswordRequired); err != nil {
+        return nil, err
     }
     
-    // 创建认证流程
-    codeAuth := auth.CodeAuthenticatorFunc(func(ctx context.Context, sentCode *tg.AuthSentCode) (string, error) {
-        return code, nil
-    })
-    
-    flow := auth.NewFlow(
-        auth.CodeOnly(phoneNumber, codeAuth),
-        auth.SendCodeOptions{},
-    )
-    
-    // 运行认证流程
-    err := client.Run(context.Background(), func(ctx context.Context) error {
-        return flow.Run(ctx, client.Auth())
+    result := &LoginResult{}
+    err := t.clients.Do(context.Background(), phoneNumber, func(ctx context.Context, client *tdapi.Client) error {
+        _, err := client.Auth().Password(ctx, password)
+        switch {
+        case err == nil:
+            result.Status = LoginStatusLoggedIn
+        case errors.Is(err, auth.ErrPasswordInvalid):
+            result.Status = LoginStatusPasswordInvalid
+        default:
+            return err
+        }
+        return nil
     })
-    
     if err != nil {
-        return fmt.Errorf("login failed: %w", err)
+        return nil, fmt.Errorf("password check failed: %w", err)
     }
     
-    // 更新会话
-    t.sessions.Store(phoneNumber, client)
-    return nil
+    if result.Status == LoginStatusLoggedIn {
+        t.logins.Delete(phoneNumber)
+        t.notifyAuthorized(phoneNumber)
+    }
+    return result, nil
+}
+
+// pendingLogin 获取处于指定状态的登录流程
+func (t *telegramServiceImpl) pendingLogin(phoneNumber string, expected LoginStatus) (*loginState, error) {
+    stateInterface, exists := t.logins.Load(phoneNumber)
+    if !exists {
+        return nil, fmt.Errorf("%w for phone %s", ErrNoLoginInProgress, phoneNumber)
+    }
+    state := stateInterface.(*loginState)
+    if state.status != expected {
+        return nil, fmt.Errorf("%w for phone %s: login is in state %s", ErrNoLoginInProgress, phoneNumber, state.status)
+    }
+    return state, nil
 }
 
 // IsAuthorized 检查客户端是否已认证
@@ -225,30 +369,8 @@ func (t *telegramServiceImpl) Login(phoneNumber string, code string) error {
 // @return bool 是否已认证
 // @return error 错误信息
 func (t *telegramServiceImpl) IsAuthorized(phoneNumber string) (bool, error) {
-    // 获取会话
-    sessionInterface, exists := t.sessions.Load(phoneNumber)
-    if !exists {
-        return false, fmt.Errorf("no session found for phone %s", phoneNumber)
-    }
-    
-    // 获取客户端实例
-    var client *tdapi.Client
-    switch v := sessionInterface.(type) {
-    case *tdapi.Client:
-        client = v
-    case SessionData:
-        client = v.Client
-    default:
-        return false, fmt.Errorf("invalid session data type for phone %s: %T", phoneNumber, sessionInterface)
-    }
-    
-    if client == nil {
-        return false, fmt.Errorf("no valid client found for phone %s", phoneNumber)
-    }
-    
-    // 检查认证状态
     var isAuthorized bool
-    err := client.Run(context.Background(), func(ctx context.Context) error {
+    err := t.clients.Do(context.Background(), phoneNumber, func(ctx context.Context, client *tdapi.Client) error {
         status, err := client.Auth().Status(ctx)
         if err != nil {
             return err
@@ -260,91 +382,62 @@ func (t *telegramServiceImpl) IsAuthorized(phoneNumber string) (bool, error) {
     return isAuthorized, err
 }
 
-// CollectMessages 采集群组消息
+// ResolvePeer 将用户名、t.me链接或数字ID解析为账号可访问的群组或频道
 // @author fcj
 // @date 2023-11-15
 // @version 1.0.0
 // @param phoneNumber 电话号码
-// @param chatID 群组ID
-// @param limit 消息数量限制
-// @return error 错误信息
-func (t *telegramServiceImpl) CollectMessages(phoneNumber string, chatID int64, limit int) error {
-    // 获取会话
-    sessionInterface, exists := t.sessions.Load(phoneNumber)
-    if !exists {
-        return fmt.Errorf("no session found for phone %s", phoneNumber)
-    }
-    
-    // 获取客户端实例
-    var client *tdapi.Client
-    switch v := sessionInterface.(type) {
-    case *tdapi.Client:
-        client = v
-    case SessionData:
-        client = v.Client
-    default:
-        return fmt.Errorf("invalid session data type for phone %s: %T", phoneNumber, sessionInterface)
+// @param target 采集目标：@username、t.me 链接或 Bot API 格式的会话ID
+// @return *ResolvedPeer 解析结果
+// @return error 格式错误时为 ErrInvalidPeerTarget，账号无法访问时为 ErrPeerNotFound
+func (t *telegramServiceImpl) ResolvePeer(phoneNumber string, target string) (*ResolvedPeer, error) {
+    // 先校验格式，避免为无效目标建立连接
+    if _, err := ParsePeerTarget(target); err != nil {
+        return nil, err
     }
     
-    if client == nil {
-        return fmt.Errorf("no valid client found for phone %s", phoneNumber)
+    ctx := context.Background()
+    api, err := t.clients.API(ctx, phoneNumber)
+    if err != nil {
+        return nil, err
     }
-    
+    return t.peers.Resolve(ctx, phoneNumber, api, target)
+}
+
+// CollectMessages 增量采集群组消息并继续回填历史
+// @author fcj
+// @date 2023-11-15
+// @version 1.0.0
+// @param phoneNumber 电话号码
+// @param chatID 群组ID
+// @param limit 每页消息数，<=0 时使用配置的 PageSize
+// @return error 错误信息
+func (t *telegramServiceImpl) CollectMessages(phoneNumber string, chatID int64, limit int) error {
     // 检查认证状态
     isAuthorized, err := t.IsAuthorized(phoneNumber)
     if err != nil || !isAuthorized {
         return fmt.Errorf("client for phone %s is not authorized: %v", phoneNumber, err)
     }
     
-    // 采集消息
-    return client.Run(context.Background(), func(ctx context.Context) error {
-        api := client.API()
-        
-        // 构建请求获取历史消息
-        messages, err := api.MessagesGetHistory(ctx, &tg.MessagesGetHistoryRequest{
-            Peer: &tg.InputPeerChannel{
-                ChannelID:  chatID,
-                AccessHash: 0, // 需从管理端服务动态获取
-            },
-            Limit: limit,
-        })
-        if err != nil {
-            return fmt.Errorf("failed to get message history: %w", err)
-        }
-        
-        // 类型断言检查
-        channelMessages, ok := messages.(*tg.MessagesChannelMessages)
-        if !ok {
-            return fmt.Errorf("unexpected message type: %T", messages)
-        }
-        
-        log.Printf("Retrieved %d messages from chat %d", len(channelMessages.Messages), chatID)
-        
-        // 处理每条消息
-        for _, msg := range channelMessages.Messages {
-            message, ok := msg.(*tg.Message)
-            if !ok {
-                log.Printf("Skipping non-message type: %T", msg)
-                continue
-            }
-            
-            // 构建消息数据
-            data := map[string]interface{}{
-                "message_id": message.ID,
-                "chat_id":    chatID,
-                "chat_title": "Unknown", // 可通过 channels.GetFullChannel 获取
-                "text":       message.Message,
-                "sender_id":  fmt.Sprintf("user_%d", message.FromID),
-                "date":       time.Unix(int64(message.Date), 0).Format(time.RFC3339),
-            }
-            
-            // 保存到数据库和搜索引擎
-            if err := t.storageService.SaveAndIndex(data); err != nil {
-                log.Printf("Failed to save and index message %d: %v", message.ID, err)
-            }
-        }
-        return nil
-    })
+    return t.collectMessages(phoneNumber, chatID, limit)
+}
+
+// collectMessages 在账号的长连接上采集群组消息，调用方负责检查认证状态
+func (t *telegramServiceImpl) collectMessages(phoneNumber string, chatID int64, limit int) error {
+    ctx := context.Background()
+    api, err := t.clients.API(ctx, phoneNumber)
+    if err != nil {
+        return err
+    }
+    
+    // 解析会话ID对应的 InputPeer（含 access hash）
+    peer, err := t.peers.ResolveID(ctx, phoneNumber, api, chatID)
+    if err != nil {
+        return err
+    }
+    
+    // 从上次保存的进度继续增量采集和回填
+    return t.collectHistory(ctx, api, phoneNumber, peer, limit)
 }
 
 // CollectAllConfiguredMessages 采集所有配置的群组消息
@@ -358,48 +451,143 @@ func (t *telegramServiceImpl) CollectAllConfiguredMessages(chatIDs []int64) erro
         return fmt.Errorf("no chat IDs configured for collection")
     }
     
-    // 遍历所有会话进行消息采集
+    // 遍历所有账号进行消息采集，每个账号只检查一次认证状态
     var lastError error
-    t.sessions.Range(func(key, value interface{}) bool {
-        phoneNumber, ok := key.(string)
-        if !ok {
-            log.Printf("Invalid session key type: %T", key)
-            return true
-        }
-        
-        // 检查认证状态
+    for _, phoneNumber := range t.clients.PhoneNumbers() {
         isAuthorized, err := t.IsAuthorized(phoneNumber)
         if err != nil || !isAuthorized {
             log.Printf("Client for phone %s is not authorized: %v", phoneNumber, err)
-            return true
+            continue
         }
         
         log.Printf("Starting collection for phone %s", phoneNumber)
         for _, chatID := range chatIDs {
-            // 默认采集 100 条消息
-            if err := t.CollectMessages(phoneNumber, chatID, 100); err != nil {
+            // 每页消息数使用配置的 PageSize
+            if err := t.collectMessages(phoneNumber, chatID, 0); err != nil {
                 log.Printf("Failed to collect from chat %d: %v", chatID, err)
                 lastError = err
             } else {
                 log.Printf("Successfully collected messages from chat %d", chatID)
             }
         }
-        return true
-    })
+    }
     
     return lastError
 }
 
+// RestoreSessions 为所有已持久化的会话启动连接
+// @author fcj
+// @date 2023-11-15
+// @version 1.0.0
+// @return []string 恢复的电话号码
+// @return error 错误信息
+func (t *telegramServiceImpl) RestoreSessions() ([]string, error) {
+    phoneNumbers, err := t.sessionStore.List(context.Background())
+    if err != nil {
+        return nil, fmt.Errorf("failed to list stored sessions: %w", err)
+    }
+    
+    // 连接在后台建立，不阻塞服务启动
+    for _, phoneNumber := range phoneNumbers {
+        t.clients.Start(phoneNumber)
+    }
+    return phoneNumbers, nil
+}
+
+// ListSessions 列出所有已持久化的会话
+// @author fcj
+// @date 2023-11-15
+// @version 1.0.0
+// @return []SessionInfo 会话列表
+// @return error 错误信息
+func (t *telegramServiceImpl) ListSessions() ([]SessionInfo, error) {
+    phoneNumbers, err := t.sessionStore.List(context.Background())
+    if err != nil {
+        return nil, fmt.Errorf("failed to list stored sessions: %w", err)
+    }
+    
+    health := make(map[string]ClientHealth)
+    for _, h := range t.clients.Health() {
+        health[h.PhoneNumber] = h
+    }
+    
+    sessions := make([]SessionInfo, 0, len(phoneNumbers))
+    for _, phoneNumber := range phoneNumbers {
+        info := SessionInfo{PhoneNumber: phoneNumber}
+        if h, loaded := health[phoneNumber]; loaded {
+            info.Loaded = true
+            info.Health = &h
+        }
+        sessions = append(sessions, info)
+    }
+    return sessions, nil
+}
+
+// Logout 注销账号并删除持久化的会话
+// @author fcj
+// @date 2023-11-15
+// @version 1.0.0
+// @param phoneNumber 电话号码
+// @return error 错误信息
+func (t *telegramServiceImpl) Logout(phoneNumber string) error {
+    if err := ValidatePhoneNumber(phoneNumber); err != nil {
+        return err
+    }
+    
+    for _, loaded := range t.clients.PhoneNumbers() {
+        if loaded != phoneNumber {
+            continue
+        }
+        // 通知Telegram注销授权；失败时仍删除本地会话，授权密钥随之失效
+        err := t.clients.Do(context.Background(), phoneNumber, func(ctx context.Context, client *tdapi.Client) error {
+            _, err := client.API().AuthLogOut(ctx)
+            return err
+        })
+        if err != nil {
+            log.Printf("Failed to log out phone %s from Telegram: %v", phoneNumber, err)
+        }
+    }
+    // 先停止连接再删除会话，避免连接关闭时重新写入
+    t.clients.Stop(phoneNumber)
+    t.logins.Delete(phoneNumber)
+    t.updates.Delete(phoneNumber)
+    
+    if err := t.sessionStore.Delete(context.Background(), phoneNumber); err != nil {
+        return fmt.Errorf("failed to delete stored session: %w", err)
+    }
+    return nil
+}
+
+// ClientHealth 返回所有账号的连接状态
+// @author fcj
+// @date 2023-11-15
+// @version 1.0.0
+// @return []ClientHealth 连接状态列表
+func (t *telegramServiceImpl) ClientHealth() []ClientHealth {
+    return t.clients.Health()
+}
+
+// Close 关闭所有账号连接
+// @author fcj
+// @date 2023-11-15
+// @version 1.0.0
+func (t *telegramServiceImpl) Close() {
+    t.clients.Close()
+    // 连接关闭后不再产生更新，提交剩余的索引操作
+    t.indexer.Close()
+}
+
 /*
  * 关键算法说明：
- * 1. 会话管理：使用sync.Map存储和管理多个用户会话
+ * 1. 连接管理：每个账号由 ClientManager 维持一个长期运行的客户端，所有API调用复用该连接
  * 2. 认证流程：实现Telegram客户端的认证流程
- * 3. 消息采集：通过Telegram API获取群组历史消息
+ * 3. 消息采集：通过 PeerResolver 解析会话ID及其 access hash，再按 CursorStore 中的进度增量采集和回填
+ * 4. 实时采集：连接就绪且登录后运行更新管理器，配置群组的新消息、编辑和删除实时写入存储
+ * 5. 会话持久化：客户端通过 SessionStore 保存 MTProto 会话，启动时由 RestoreSessions 恢复并在后台连接
  * 
  * 待优化事项：
- * 1. 会话持久化：将会话数据持久化到数据库
- * 2. 错误重试：添加重试机制处理临时网络故障
- * 3. 并发控制：优化并发采集性能
+ * 1. 错误重试：添加重试机制处理临时网络故障
+ * 2. 并发控制：优化并发采集性能
  * 
  * 兼容性说明：
  * 1. 依赖gotd/td库与Telegram API交互
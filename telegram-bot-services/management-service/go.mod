@@ -5,7 +5,11 @@ go 1.25.0
 require (
 	github.com/go-resty/resty/v2 v2.16.5
 	github.com/kelseyhightower/envconfig v1.4.0
+	github.com/pocketbase/dbx v1.11.0
 	github.com/pocketbase/pocketbase v0.29.3
+	github.com/spf13/cobra v1.9.1
+	github.com/wangbin/jiebago v0.3.2
+	telegram-bot-services/pkg v0.0.0
 )
 
 require (
@@ -23,10 +27,8 @@ require (
 	github.com/mattn/go-colorable v0.1.14 // indirect
 	github.com/mattn/go-isatty v0.0.20 // indirect
 	github.com/ncruces/go-strftime v0.1.9 // indirect
-	github.com/pocketbase/dbx v1.11.0 // indirect
 	github.com/remyoudompheng/bigfft v0.0.0-20230129092748-24d4a6f8daec // indirect
 	github.com/spf13/cast v1.9.2 // indirect
-	github.com/spf13/cobra v1.9.1 // indirect
 	github.com/spf13/pflag v1.0.7 // indirect
 	github.com/stretchr/testify v1.10.0 // indirect
 	golang.org/x/crypto v0.41.0 // indirect
@@ -43,3 +45,5 @@ require (
 	modernc.org/memory v1.11.0 // indirect
 	modernc.org/sqlite v1.38.2 // indirect
 )
+
+replace telegram-bot-services/pkg => ../pkg
@@ -22,6 +22,21 @@ type Config struct {
 	MeilisearchKey string       `json:"meilisearch_key" envconfig:"MEILISEARCH_KEY"`
 	PocketBaseURL  string       `json:"pocketbase_url" envconfig:"POCKETBASE_URL"`
 	BotServiceURL  string       `json:"bot_service_url" envconfig:"BOT_SERVICE_URL"`
+	// SearchChatWeight、SearchMessageWeight 综合搜索时群组和消息结果的权重，为 0 时使用默认值 1.0 和 0.9
+	SearchChatWeight    float64 `json:"search_chat_weight" envconfig:"SEARCH_CHAT_WEIGHT"`
+	SearchMessageWeight float64 `json:"search_message_weight" envconfig:"SEARCH_MESSAGE_WEIGHT"`
+	// SuggestionsSchedule 增量更新搜索建议索引的 cron 表达式，为空时不定时更新
+	SuggestionsSchedule string `json:"suggestions_schedule" envconfig:"SUGGESTIONS_SCHEDULE"`
+	// SuggestionsReconcileSchedule 清理已删除群组的关键词的 cron 表达式，为空时不定时清理
+	SuggestionsReconcileSchedule string `json:"suggestions_reconcile_schedule" envconfig:"SUGGESTIONS_RECONCILE_SCHEDULE"`
+	// SuggestionsDict 搜索建议分词词典路径
+	SuggestionsDict string `json:"suggestions_dict" envconfig:"SUGGESTIONS_DICT"`
+	// SuggestionsUserDicts 用户词典路径，收录 Telegram 圈内用语
+	SuggestionsUserDicts []string `json:"suggestions_user_dicts" envconfig:"SUGGESTIONS_USER_DICTS"`
+	// SuggestionsStopwords 内置中英文停用词之外的停用词文件
+	SuggestionsStopwords []string `json:"suggestions_stopwords" envconfig:"SUGGESTIONS_STOPWORDS"`
+	// SuggestionsMinFrequency 关键词至少出现在多少个群组中才作为建议返回，默认 2
+	SuggestionsMinFrequency int `json:"suggestions_min_frequency" envconfig:"SUGGESTIONS_MIN_FREQUENCY"`
 }
 
 var (
@@ -71,4 +86,4 @@ func Get() *Config {
 		panic("config not loaded")
 	}
 	return cfg
-}
\ No newline at end of file
+}
@@ -1,18 +1,30 @@
 package main
 
 import (
+	"errors"
 	"log"
 	"net/http"
 
 	"management-service/internal/config"
 	_ "management-service/migrations"
 	"management-service/service"
+	"management-service/suggest"
+	"management-service/suggest/tokenizer"
+	"telegram-bot-services/pkg/query"
+	"telegram-bot-services/pkg/search"
 
+	"github.com/pocketbase/dbx"
 	"github.com/pocketbase/pocketbase"
 	"github.com/pocketbase/pocketbase/apis"
 	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/types"
+	"github.com/spf13/cobra"
 )
 
+// minDictionaryEntries is far below the ~350k entries of the jieba dictionary but well above any
+// placeholder file, so a missing or truncated dictionary is reported at startup.
+const minDictionaryEntries = 10000
+
 func main() {
 	app := pocketbase.New()
 
@@ -24,9 +36,15 @@ func main() {
 
 	// Initialize services
 	searchSvc, botInfoSvc, webhookSvc := initServices(cfg)
+	dailySvc := service.NewDailyService(app)
+	searchLogSvc := service.NewSearchLogService(app)
 
-	// Register API routes
-	registerAPIs(app, searchSvc, botInfoSvc, webhookSvc)
+	// Register record hooks and API routes
+	registerHooks(app)
+	registerCleanup(app, searchLogSvc)
+	registerAPIs(app, searchSvc, botInfoSvc, webhookSvc, dailySvc, searchLogSvc)
+	registerSuggestions(app, cfg)
+	registerReindex(app, cfg)
 
 	if err := app.Start(); err != nil {
 		log.Fatal(err)
@@ -37,6 +55,8 @@ func initServices(cfg *config.Config) (service.SearchService, service.BotInfoSer
 	searchConfig := service.SearchConfig{
 		MeilisearchURL: cfg.MeilisearchURL,
 		MeilisearchKey: cfg.MeilisearchKey,
+		ChatWeight:     cfg.SearchChatWeight,
+		MessageWeight:  cfg.SearchMessageWeight,
 	}
 	searchService := service.NewSearchService(searchConfig)
 
@@ -49,7 +69,144 @@ func initServices(cfg *config.Config) (service.SearchService, service.BotInfoSer
 	return searchService, botInfoService, webhookService
 }
 
-func registerAPIs(app *pocketbase.PocketBase, searchService service.SearchService, botInfoService service.BotInfoService, webhookService service.WebhookService) {
+func registerHooks(app *pocketbase.PocketBase) {
+	// Stamp when a chat is first indexed; the bot's upsert and the enrichment job only PATCH afterwards.
+	app.OnRecordCreate("telegram_index").BindFunc(func(e *core.RecordEvent) error {
+		if e.Record.GetDateTime("indexed_at").IsZero() {
+			e.Record.Set("indexed_at", types.NowDateTime())
+		}
+		return e.Next()
+	})
+}
+
+// registerCleanup purges expired bot-service callback states every hour (the bot only deletes
+// the expired states that are clicked again) and search logs past the trending windows every day.
+func registerCleanup(app *pocketbase.PocketBase, searchLogSvc service.SearchLogService) {
+	app.Cron().MustAdd("purge_callback_states", "0 * * * *", func() {
+		result, err := app.DB().Delete("callback_states",
+			dbx.NewExp("expires_at < {:now}", dbx.Params{"now": types.NowDateTime().String()})).Execute()
+		if err != nil {
+			log.Printf("ERROR: Failed to purge expired callback states: %v", err)
+			return
+		}
+		if n, _ := result.RowsAffected(); n > 0 {
+			log.Printf("Purged %d expired callback states", n)
+		}
+	})
+	app.Cron().MustAdd("purge_search_logs", "30 3 * * *", func() {
+		n, err := searchLogSvc.Purge()
+		if err != nil {
+			log.Printf("ERROR: Failed to purge search logs: %v", err)
+			return
+		}
+		if n > 0 {
+			log.Printf("Purged %d old search logs", n)
+		}
+	})
+}
+
+// registerSuggestions keeps the suggestions index in sync with telegram_index on a cron schedule
+// and adds a "suggestions" command for one-off updates or a full rebuild.
+func registerSuggestions(app *pocketbase.PocketBase, cfg *config.Config) {
+	dictPath := cfg.SuggestionsDict
+	if dictPath == "" {
+		dictPath = "configs/dict.txt"
+	}
+	// A truncated dictionary makes jieba cut Chinese into single characters, which are then
+	// dropped as too short, so the suggestions would silently lose almost every Chinese keyword.
+	// Only the suggestions are disabled; the rest of the service doesn't need the dictionary.
+	entries, err := tokenizer.DictionaryEntries(dictPath)
+	if err != nil {
+		log.Printf("ERROR: Search suggestions disabled, dictionary unavailable (see README): %v", err)
+		return
+	}
+	if entries < minDictionaryEntries {
+		log.Printf("ERROR: Search suggestions disabled, dictionary %s has only %d entries, expected the full jieba dictionary (see README)", dictPath, entries)
+		return
+	}
+	builder, err := suggest.NewBuilder(app, suggest.Config{
+		MeilisearchURL: cfg.MeilisearchURL,
+		MeilisearchKey: cfg.MeilisearchKey,
+		Tokenizer: tokenizer.Config{
+			DictPath:      dictPath,
+			UserDictPaths: cfg.SuggestionsUserDicts,
+			StopwordPaths: cfg.SuggestionsStopwords,
+		},
+		MinFrequency: cfg.SuggestionsMinFrequency,
+	})
+	if err != nil {
+		log.Printf("WARN: Search suggestions builder disabled: %v", err)
+		return
+	}
+
+	var rebuild, reconcile bool
+	command := &cobra.Command{
+		Use:   "suggestions",
+		Short: "Update the search suggestions index from telegram_index",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			run := builder.Update
+			switch {
+			case rebuild:
+				run = builder.Rebuild
+			case reconcile:
+				run = builder.Reconcile
+			}
+			stats, err := run()
+			if err != nil {
+				return err
+			}
+			log.Printf("Suggestions updated: %+v", stats)
+			return nil
+		},
+	}
+	command.Flags().BoolVar(&rebuild, "rebuild", false, "rebuild the index from scratch and swap it in")
+	command.Flags().BoolVar(&reconcile, "reconcile", false, "remove the keywords of chats deleted from telegram_index")
+	app.RootCmd.AddCommand(command)
+
+	if cfg.SuggestionsSchedule != "" {
+		app.Cron().MustAdd("suggestions", cfg.SuggestionsSchedule, func() {
+			stats, err := builder.Update()
+			if err != nil {
+				log.Printf("ERROR: Failed to update search suggestions: %v", err)
+				return
+			}
+			log.Printf("Suggestions updated: %+v", stats)
+		})
+	}
+	if cfg.SuggestionsReconcileSchedule != "" {
+		app.Cron().MustAdd("suggestions_reconcile", cfg.SuggestionsReconcileSchedule, func() {
+			stats, err := builder.Reconcile()
+			if err != nil {
+				log.Printf("ERROR: Failed to reconcile search suggestions: %v", err)
+				return
+			}
+			log.Printf("Suggestions reconciled: %+v", stats)
+		})
+	}
+}
+
+// registerReindex adds a "reindex-messages" command that rebuilds the messages index from the
+// messages collection, e.g. to migrate documents written with the old upper-case field names.
+func registerReindex(app *pocketbase.PocketBase, cfg *config.Config) {
+	app.RootCmd.AddCommand(&cobra.Command{
+		Use:   "reindex-messages",
+		Short: "Rebuild the messages search index from the messages collection",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			reindexer := service.NewReindexService(app, service.ReindexConfig{
+				MeilisearchURL: cfg.MeilisearchURL,
+				MeilisearchKey: cfg.MeilisearchKey,
+			})
+			total, err := reindexer.ReindexMessages()
+			if err != nil {
+				return err
+			}
+			log.Printf("Messages reindexed: %d documents", total)
+			return nil
+		},
+	})
+}
+
+func registerAPIs(app *pocketbase.PocketBase, searchService service.SearchService, botInfoService service.BotInfoService, webhookService service.WebhookService, dailyService service.DailyService, searchLogService service.SearchLogService) {
 	app.OnServe().BindFunc(func(se *core.ServeEvent) error {
 		// Middleware to require admin authentication.
 		// This function will be executed before each handler in the group.
@@ -66,29 +223,90 @@ func registerAPIs(app *pocketbase.PocketBase, searchService service.SearchServic
 
 		// Register search API
 		apiGroup.GET("/search", func(e *core.RequestEvent) error {
-			q := e.Request.URL.Query().Get("q")
-			p := e.Request.URL.Query().Get("page")
-			l := e.Request.URL.Query().Get("limit")
-			f := e.Request.URL.Query().Get("filter")
-			results, err := searchService.Search(q, p, l, f)
+			params := e.Request.URL.Query()
+			q := params.Get("q")
+			p := params.Get("page")
+			l := params.Get("limit")
+			f := params.Get("filter")
+			opts := search.Options{
+				Sort:   params.Get("sort"),
+				From:   params.Get("from"),
+				To:     params.Get("to"),
+				Facets: params["facets"],
+			}
+			results, err := searchService.Search(q, p, l, f, opts)
+			if errors.Is(err, query.ErrInvalidQuery) {
+				return apis.NewBadRequestError(err.Error(), nil)
+			}
 			if err != nil {
 				return apis.NewApiError(http.StatusInternalServerError, "Failed to perform search", err)
 			}
+			// Only the first page counts as a new search
+			if p == "" || p == "1" {
+				entry := service.SearchLogEntry{Query: q, Filter: f, Hits: results.TotalHits, Source: "web"}
+				if e.Auth != nil {
+					entry.UserID = e.Auth.Id
+				}
+				if err := searchLogService.Log(entry); err != nil {
+					log.Printf("Failed to log search: %v", err)
+				}
+			}
 			return e.JSON(http.StatusOK, results)
 		})
 
 		// Register search suggestions API
 		apiGroup.GET("/search/suggestions", func(e *core.RequestEvent) error {
-			suggestions := []string{"suggestion1", "suggestion2", "suggestion3"}
+			query := e.Request.URL.Query()
+			suggestions, err := searchService.Suggest(query.Get("q"), query.Get("limit"), query["recent"])
+			if err != nil {
+				return apis.NewApiError(http.StatusInternalServerError, "Failed to get search suggestions", err)
+			}
 			return e.JSON(http.StatusOK, suggestions)
 		})
 
 		// Register trending searches API
 		apiGroup.GET("/search/trending", func(e *core.RequestEvent) error {
-			trending := []string{"trending1", "trending2", "trending3"}
+			query := e.Request.URL.Query()
+			window := query.Get("window")
+			if window == "" {
+				window = query.Get("timeRange")
+			}
+			trending, err := searchLogService.Trending(window, query.Get("limit"))
+			if errors.Is(err, service.ErrInvalidTrendingQuery) {
+				return apis.NewBadRequestError(err.Error(), nil)
+			}
+			if err != nil {
+				return apis.NewApiError(http.StatusInternalServerError, "Failed to get trending searches", err)
+			}
 			return e.JSON(http.StatusOK, trending)
 		})
 
+		// Register daily new chats API
+		apiGroup.GET("/daily/new", func(e *core.RequestEvent) error {
+			query := e.Request.URL.Query()
+			result, err := dailyService.NewChats(query.Get("from"), query.Get("to"), query.Get("type"), query.Get("page"), query.Get("limit"))
+			if errors.Is(err, service.ErrInvalidDailyQuery) {
+				return apis.NewBadRequestError(err.Error(), nil)
+			}
+			if err != nil {
+				return apis.NewApiError(http.StatusInternalServerError, "Failed to list new chats", err)
+			}
+			return e.JSON(http.StatusOK, result)
+		})
+
+		// Register daily stats API
+		apiGroup.GET("/daily/stats", func(e *core.RequestEvent) error {
+			query := e.Request.URL.Query()
+			result, err := dailyService.Stats(query.Get("from"), query.Get("to"), query.Get("type"), query.Get("limit"))
+			if errors.Is(err, service.ErrInvalidDailyQuery) {
+				return apis.NewBadRequestError(err.Error(), nil)
+			}
+			if err != nil {
+				return apis.NewApiError(http.StatusInternalServerError, "Failed to get daily stats", err)
+			}
+			return e.JSON(http.StatusOK, result)
+		})
+
 		// Register bots API
 		apiGroup.GET("/bots", func(e *core.RequestEvent) error {
 			bots, err := botInfoService.GetAllBotInfos()
@@ -0,0 +1,35 @@
+package migrations
+
+import (
+	"fmt"
+
+	"github.com/pocketbase/pocketbase/core"
+	m "github.com/pocketbase/pocketbase/migrations"
+)
+
+func init() {
+	m.Register(func(app core.App) error {
+		// Persistent backend for bot-service search pagination state (short callback IDs)
+		if _, err := app.FindCollectionByNameOrId("callback_states"); err == nil {
+			return nil // already exists, skip
+		}
+
+		collection := core.NewBaseCollection("callback_states")
+		collection.Fields.Add(&core.TextField{
+			Name:     "state_id", // opaque id embedded in inline button callback data
+			Required: true,
+			Max:      32,
+		})
+		collection.Fields.Add(&core.JSONField{ Name: "payload" })
+		collection.Fields.Add(&core.DateField{ Name: "expires_at", Required: true })
+		collection.AddIndex("idx_callback_states_state_id", true, "state_id", "")
+
+		return app.Save(collection)
+	}, func(app core.App) error {
+		collection, err := app.FindCollectionByNameOrId("callback_states")
+		if err != nil {
+			return fmt.Errorf("failed to find collection: %w", err)
+		}
+		return app.Delete(collection)
+	})
+}
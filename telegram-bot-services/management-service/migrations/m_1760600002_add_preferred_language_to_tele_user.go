@@ -0,0 +1,34 @@
+package migrations
+
+import (
+	"fmt"
+
+	"github.com/pocketbase/pocketbase/core"
+	m "github.com/pocketbase/pocketbase/migrations"
+)
+
+// Add tele_user.preferred_language: the bot reply language chosen with /lang,
+// which takes precedence over the Telegram client's language_code.
+func init() {
+	m.Register(func(app core.App) error {
+		col, err := app.FindCollectionByNameOrId("tele_user")
+		if err != nil {
+			return fmt.Errorf("tele_user collection not found: %w", err)
+		}
+		col.Fields.Add(&core.TextField{
+			Name: "preferred_language",
+			Max:  10,
+		})
+		if err := app.Save(col); err != nil {
+			return fmt.Errorf("save tele_user: %w", err)
+		}
+		return nil
+	}, func(app core.App) error {
+		col, err := app.FindCollectionByNameOrId("tele_user")
+		if err != nil {
+			return nil
+		}
+		col.Fields.RemoveByName("preferred_language")
+		return app.Save(col)
+	})
+}
@@ -0,0 +1,48 @@
+package migrations
+
+import (
+	"fmt"
+
+	"github.com/pocketbase/pocketbase/core"
+	m "github.com/pocketbase/pocketbase/migrations"
+)
+
+func init() {
+	m.Register(func(app core.App) error {
+		// Create the messages collection shared by bot-service and collection-service ingestion
+		if _, err := app.FindCollectionByNameOrId("messages"); err != nil {
+			collection := core.NewBaseCollection("messages")
+			collection.Fields.Add(&core.TextField{ Name: "chat_id", Required: true }) // text to avoid numeric precision issues
+			collection.Fields.Add(&core.NumberField{ Name: "message_id", Required: true, OnlyInt: true })
+			collection.Fields.Add(&core.TextField{ Name: "chat_title" })
+			collection.Fields.Add(&core.TextField{ Name: "chat_username" })
+			collection.Fields.Add(&core.TextField{ Name: "chat_type", Max: 32 })
+			collection.Fields.Add(&core.TextField{ Name: "sender_id" })
+			collection.Fields.Add(&core.TextField{ Name: "sender_name" })
+			collection.Fields.Add(&core.BoolField{ Name: "sender_is_bot" })
+			collection.Fields.Add(&core.TextField{ Name: "text" })
+			collection.Fields.Add(&core.DateField{ Name: "date" })
+			collection.Fields.Add(&core.TextField{ Name: "source", Max: 32 }) // bot | collector
+			collection.AddIndex("idx_messages_chat_message", true, "chat_id, message_id", "")
+			if err := app.Save(collection); err != nil {
+				return fmt.Errorf("save messages: %w", err)
+			}
+		}
+
+		// Ingestion writes messages through the batch API, which is disabled by default
+		settings := app.Settings()
+		if !settings.Batch.Enabled {
+			settings.Batch.Enabled = true
+			if err := app.Save(settings); err != nil {
+				return fmt.Errorf("enable batch api: %w", err)
+			}
+		}
+		return nil
+	}, func(app core.App) error {
+		collection, err := app.FindCollectionByNameOrId("messages")
+		if err != nil {
+			return fmt.Errorf("failed to find collection: %w", err)
+		}
+		return app.Delete(collection)
+	})
+}
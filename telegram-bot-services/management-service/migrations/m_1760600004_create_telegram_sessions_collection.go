@@ -0,0 +1,36 @@
+package migrations
+
+import (
+	"fmt"
+
+	"github.com/pocketbase/pocketbase/core"
+	m "github.com/pocketbase/pocketbase/migrations"
+)
+
+func init() {
+	m.Register(func(app core.App) error {
+		// Encrypted MTProto sessions for collection-service accounts (session.backend = "pocketbase").
+		// No API rules are set, so only superusers can read or write them.
+		if _, err := app.FindCollectionByNameOrId("telegram_sessions"); err == nil {
+			return nil // already exists, skip
+		}
+
+		collection := core.NewBaseCollection("telegram_sessions")
+		collection.Fields.Add(&core.TextField{ Name: "phone", Required: true, Max: 32 })
+		collection.Fields.Add(&core.TextField{
+			Name:     "data", // base64(nonce + AES-GCM ciphertext)
+			Required: true,
+			Max:      65535,
+		})
+		collection.Fields.Add(&core.AutodateField{ Name: "updated", OnCreate: true, OnUpdate: true })
+		collection.AddIndex("idx_telegram_sessions_phone", true, "phone", "")
+
+		return app.Save(collection)
+	}, func(app core.App) error {
+		collection, err := app.FindCollectionByNameOrId("telegram_sessions")
+		if err != nil {
+			return fmt.Errorf("failed to find collection: %w", err)
+		}
+		return app.Delete(collection)
+	})
+}
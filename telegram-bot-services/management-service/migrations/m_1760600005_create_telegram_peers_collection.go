@@ -0,0 +1,36 @@
+package migrations
+
+import (
+	"fmt"
+
+	"github.com/pocketbase/pocketbase/core"
+	m "github.com/pocketbase/pocketbase/migrations"
+)
+
+func init() {
+	m.Register(func(app core.App) error {
+		// Per-account access-hash cache for collection-service peer resolution (session.backend = "pocketbase").
+		// Access hashes are only valid for the account that fetched them, so rows are keyed by phone + chat_id.
+		if _, err := app.FindCollectionByNameOrId("telegram_peers"); err == nil {
+			return nil // already exists, skip
+		}
+
+		collection := core.NewBaseCollection("telegram_peers")
+		collection.Fields.Add(&core.TextField{ Name: "phone", Required: true, Max: 32 })
+		collection.Fields.Add(&core.TextField{ Name: "chat_id", Required: true, Max: 32 }) // Bot API style id, -100... for channels
+		collection.Fields.Add(&core.TextField{ Name: "kind", Required: true, Max: 16 })    // chat | channel
+		collection.Fields.Add(&core.TextField{ Name: "access_hash", Max: 32 })             // text to avoid numeric precision issues
+		collection.Fields.Add(&core.TextField{ Name: "username", Max: 64 })
+		collection.Fields.Add(&core.TextField{ Name: "title" })
+		collection.Fields.Add(&core.AutodateField{ Name: "updated", OnCreate: true, OnUpdate: true })
+		collection.AddIndex("idx_telegram_peers_phone_chat", true, "phone, chat_id", "")
+
+		return app.Save(collection)
+	}, func(app core.App) error {
+		collection, err := app.FindCollectionByNameOrId("telegram_peers")
+		if err != nil {
+			return fmt.Errorf("failed to find collection: %w", err)
+		}
+		return app.Delete(collection)
+	})
+}
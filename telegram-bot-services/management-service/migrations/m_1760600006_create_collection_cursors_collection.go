@@ -0,0 +1,36 @@
+package migrations
+
+import (
+	"fmt"
+
+	"github.com/pocketbase/pocketbase/core"
+	m "github.com/pocketbase/pocketbase/migrations"
+)
+
+func init() {
+	m.Register(func(app core.App) error {
+		// Per-chat history checkpoints for collection-service incremental collection and backfill.
+		// Basic-group message ids are numbered per account, so rows are keyed by phone + chat_id.
+		if _, err := app.FindCollectionByNameOrId("collection_cursors"); err == nil {
+			return nil // already exists, skip
+		}
+
+		collection := core.NewBaseCollection("collection_cursors")
+		collection.Fields.Add(&core.TextField{ Name: "phone", Required: true, Max: 32 })
+		collection.Fields.Add(&core.TextField{ Name: "chat_id", Required: true, Max: 32 }) // Bot API style id
+		collection.Fields.Add(&core.NumberField{ Name: "last_message_id", OnlyInt: true })   // newest collected message
+		collection.Fields.Add(&core.NumberField{ Name: "oldest_message_id", OnlyInt: true }) // oldest backfilled message
+		collection.Fields.Add(&core.NumberField{ Name: "backfilled", OnlyInt: true })
+		collection.Fields.Add(&core.BoolField{ Name: "backfill_done" })
+		collection.Fields.Add(&core.AutodateField{ Name: "updated", OnCreate: true, OnUpdate: true })
+		collection.AddIndex("idx_collection_cursors_phone_chat", true, "phone, chat_id", "")
+
+		return app.Save(collection)
+	}, func(app core.App) error {
+		collection, err := app.FindCollectionByNameOrId("collection_cursors")
+		if err != nil {
+			return fmt.Errorf("failed to find collection: %w", err)
+		}
+		return app.Delete(collection)
+	})
+}
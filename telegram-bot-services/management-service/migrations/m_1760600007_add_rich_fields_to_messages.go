@@ -0,0 +1,60 @@
+package migrations
+
+import (
+	"fmt"
+
+	"github.com/pocketbase/pocketbase/core"
+	m "github.com/pocketbase/pocketbase/migrations"
+)
+
+// richMessageFields are extracted by collection-service from MTProto messages:
+// media, entities, forward/reply headers and engagement counters.
+var richMessageFields = []core.Field{
+	&core.TextField{ Name: "media_type", Max: 32 }, // photo | video | animation | video_note | audio | voice | sticker | document | poll | webpage | location | contact | other
+	&core.TextField{ Name: "file_name" },
+	&core.NumberField{ Name: "file_size", OnlyInt: true },
+	&core.TextField{ Name: "mime_type", Max: 255 },
+	&core.JSONField{ Name: "urls" },
+	&core.JSONField{ Name: "mentions" },
+	&core.JSONField{ Name: "hashtags" },
+	&core.TextField{ Name: "forward_from_id" }, // text to avoid numeric precision issues
+	&core.TextField{ Name: "forward_from_name" },
+	&core.DateField{ Name: "forward_date" },
+	&core.NumberField{ Name: "reply_to_message_id", OnlyInt: true },
+	&core.NumberField{ Name: "views", OnlyInt: true },
+	&core.NumberField{ Name: "forwards", OnlyInt: true },
+	&core.NumberField{ Name: "reactions", OnlyInt: true },
+	&core.DateField{ Name: "edit_date" },
+	&core.TextField{ Name: "grouped_id" }, // album id, text to avoid numeric precision issues
+}
+
+func init() {
+	m.Register(func(app core.App) error {
+		collection, err := app.FindCollectionByNameOrId("messages")
+		if err != nil {
+			return fmt.Errorf("messages collection not found: %w", err)
+		}
+		for _, field := range richMessageFields {
+			if collection.Fields.GetByName(field.GetName()) == nil {
+				collection.Fields.Add(field)
+			}
+		}
+		collection.AddIndex("idx_messages_media_type", false, "media_type", "")
+		collection.AddIndex("idx_messages_grouped_id", false, "chat_id, grouped_id", "")
+		if err := app.Save(collection); err != nil {
+			return fmt.Errorf("save messages: %w", err)
+		}
+		return nil
+	}, func(app core.App) error {
+		collection, err := app.FindCollectionByNameOrId("messages")
+		if err != nil {
+			return nil
+		}
+		collection.RemoveIndex("idx_messages_media_type")
+		collection.RemoveIndex("idx_messages_grouped_id")
+		for _, field := range richMessageFields {
+			collection.Fields.RemoveByName(field.GetName())
+		}
+		return app.Save(collection)
+	})
+}
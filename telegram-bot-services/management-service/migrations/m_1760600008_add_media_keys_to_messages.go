@@ -0,0 +1,33 @@
+package migrations
+
+import (
+	"fmt"
+
+	"github.com/pocketbase/pocketbase/core"
+	m "github.com/pocketbase/pocketbase/migrations"
+)
+
+// Add messages.media_key / thumbnail_key: object keys of media archived by
+// collection-service to S3-compatible storage (content-addressed, shared by duplicates).
+func init() {
+	m.Register(func(app core.App) error {
+		collection, err := app.FindCollectionByNameOrId("messages")
+		if err != nil {
+			return fmt.Errorf("messages collection not found: %w", err)
+		}
+		collection.Fields.Add(&core.TextField{ Name: "media_key", Max: 255 })
+		collection.Fields.Add(&core.TextField{ Name: "thumbnail_key", Max: 255 })
+		if err := app.Save(collection); err != nil {
+			return fmt.Errorf("save messages: %w", err)
+		}
+		return nil
+	}, func(app core.App) error {
+		collection, err := app.FindCollectionByNameOrId("messages")
+		if err != nil {
+			return nil
+		}
+		collection.Fields.RemoveByName("media_key")
+		collection.Fields.RemoveByName("thumbnail_key")
+		return app.Save(collection)
+	})
+}
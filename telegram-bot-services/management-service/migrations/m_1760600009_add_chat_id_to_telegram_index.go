@@ -0,0 +1,43 @@
+package migrations
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/pocketbase/pocketbase/core"
+	m "github.com/pocketbase/pocketbase/migrations"
+)
+
+// Add telegram_index.chat_id (Bot API style id, also the Meilisearch document id) so the bot's
+// upsert filter and collection-service enrichment can match records, and allow the
+// "supergroup" type that both services write.
+func init() {
+	m.Register(func(app core.App) error {
+		col, err := app.FindCollectionByNameOrId("telegram_index")
+		if err != nil {
+			return fmt.Errorf("telegram_index collection not found: %w", err)
+		}
+		if col.Fields.GetByName("chat_id") == nil {
+			col.Fields.Add(&core.TextField{ Name: "chat_id", Max: 32 }) // text to avoid numeric precision issues
+		}
+		col.AddIndex("idx_telegram_index_chat_id", false, "chat_id", "")
+		if typeField, ok := col.Fields.GetByName("type").(*core.SelectField); ok && !slices.Contains(typeField.Values, "supergroup") {
+			typeField.Values = append(typeField.Values, "supergroup")
+		}
+		if err := app.Save(col); err != nil {
+			return fmt.Errorf("save telegram_index: %w", err)
+		}
+		return nil
+	}, func(app core.App) error {
+		col, err := app.FindCollectionByNameOrId("telegram_index")
+		if err != nil {
+			return nil
+		}
+		col.RemoveIndex("idx_telegram_index_chat_id")
+		col.Fields.RemoveByName("chat_id")
+		if typeField, ok := col.Fields.GetByName("type").(*core.SelectField); ok {
+			typeField.Values = slices.DeleteFunc(typeField.Values, func(v string) bool { return v == "supergroup" })
+		}
+		return app.Save(col)
+	})
+}
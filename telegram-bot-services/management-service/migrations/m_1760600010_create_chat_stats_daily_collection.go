@@ -0,0 +1,60 @@
+package migrations
+
+import (
+	"fmt"
+
+	"github.com/pocketbase/pocketbase/core"
+	m "github.com/pocketbase/pocketbase/migrations"
+)
+
+// Daily per-chat member/message snapshots written by collection-service enrichment, plus the
+// 7-day and 30-day member deltas it derives from them and pushes back into telegram_index.
+func init() {
+	m.Register(func(app core.App) error {
+		if _, err := app.FindCollectionByNameOrId("chat_stats_daily"); err != nil {
+			collection := core.NewBaseCollection("chat_stats_daily")
+			collection.Fields.Add(&core.TextField{ Name: "chat_id", Required: true, Max: 32 }) // Bot API style id
+			collection.Fields.Add(&core.TextField{ Name: "date", Required: true, Max: 10 })    // UTC day, YYYY-MM-DD
+			collection.Fields.Add(&core.NumberField{ Name: "members_count", OnlyInt: true })
+			collection.Fields.Add(&core.NumberField{ Name: "message_count", OnlyInt: true })
+			collection.Fields.Add(&core.NumberField{ Name: "online_count", OnlyInt: true })
+			collection.Fields.Add(&core.NumberField{ Name: "active_members", OnlyInt: true })
+			collection.Fields.Add(&core.NumberField{ Name: "members_delta", OnlyInt: true })  // vs previous snapshot
+			collection.Fields.Add(&core.NumberField{ Name: "messages_delta", OnlyInt: true }) // vs previous snapshot
+			collection.Fields.Add(&core.AutodateField{ Name: "updated", OnCreate: true, OnUpdate: true })
+			collection.AddIndex("idx_chat_stats_daily_chat_date", true, "chat_id, date", "")
+			collection.AddIndex("idx_chat_stats_daily_date", false, "date", "")
+			if err := app.Save(collection); err != nil {
+				return fmt.Errorf("save chat_stats_daily: %w", err)
+			}
+		}
+
+		col, err := app.FindCollectionByNameOrId("telegram_index")
+		if err != nil {
+			return fmt.Errorf("telegram_index collection not found: %w", err)
+		}
+		if col.Fields.GetByName("members_change_7d") == nil {
+			col.Fields.Add(&core.NumberField{ Name: "members_change_7d", OnlyInt: true })
+		}
+		if col.Fields.GetByName("members_change_30d") == nil {
+			col.Fields.Add(&core.NumberField{ Name: "members_change_30d", OnlyInt: true })
+		}
+		if err := app.Save(col); err != nil {
+			return fmt.Errorf("save telegram_index: %w", err)
+		}
+		return nil
+	}, func(app core.App) error {
+		if col, err := app.FindCollectionByNameOrId("telegram_index"); err == nil {
+			col.Fields.RemoveByName("members_change_7d")
+			col.Fields.RemoveByName("members_change_30d")
+			if err := app.Save(col); err != nil {
+				return fmt.Errorf("save telegram_index: %w", err)
+			}
+		}
+		collection, err := app.FindCollectionByNameOrId("chat_stats_daily")
+		if err != nil {
+			return nil
+		}
+		return app.Delete(collection)
+	})
+}
@@ -0,0 +1,37 @@
+package migrations
+
+import (
+	"fmt"
+
+	"github.com/pocketbase/pocketbase/core"
+	m "github.com/pocketbase/pocketbase/migrations"
+)
+
+func init() {
+	m.Register(func(app core.App) error {
+		// One row per search from bot-service (messages, pagination/filter callbacks) and the
+		// management-service web API; trending searches are aggregated from it.
+		if _, err := app.FindCollectionByNameOrId("search_logs"); err == nil {
+			return nil // already exists, skip
+		}
+
+		collection := core.NewBaseCollection("search_logs")
+		collection.Fields.Add(&core.TextField{ Name: "query", Required: true, Max: 256 })
+		collection.Fields.Add(&core.TextField{ Name: "normalized", Max: 256 }) // lowercased, whitespace collapsed
+		collection.Fields.Add(&core.TextField{ Name: "filter", Max: 32 })
+		collection.Fields.Add(&core.NumberField{ Name: "hits", OnlyInt: true })
+		collection.Fields.Add(&core.TextField{ Name: "user_id", Max: 32 })
+		collection.Fields.Add(&core.TextField{ Name: "source", Max: 16 }) // bot | callback | web
+		collection.Fields.Add(&core.AutodateField{ Name: "created", OnCreate: true })
+		collection.AddIndex("idx_search_logs_created", false, "created", "")
+		collection.AddIndex("idx_search_logs_normalized_created", false, "normalized, created", "")
+
+		return app.Save(collection)
+	}, func(app core.App) error {
+		collection, err := app.FindCollectionByNameOrId("search_logs")
+		if err != nil {
+			return fmt.Errorf("failed to find collection: %w", err)
+		}
+		return app.Delete(collection)
+	})
+}
@@ -0,0 +1,45 @@
+package migrations
+
+import (
+	"fmt"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+	m "github.com/pocketbase/pocketbase/migrations"
+	"github.com/pocketbase/pocketbase/tools/types"
+)
+
+// Add telegram_index.updated, stamped by PocketBase on every save, so the suggestions builder can
+// read only the records changed since its last run. updated_at is written by the bot and
+// collection-service with their own clocks and not on every update, so it can't serve as a cursor.
+func init() {
+	m.Register(func(app core.App) error {
+		col, err := app.FindCollectionByNameOrId("telegram_index")
+		if err != nil {
+			return fmt.Errorf("telegram_index collection not found: %w", err)
+		}
+		if col.Fields.GetByName("updated") == nil {
+			col.Fields.Add(&core.AutodateField{ Name: "updated", OnCreate: true, OnUpdate: true })
+		}
+		col.AddIndex("idx_telegram_index_updated", false, "updated, id", "")
+		if err := app.Save(col); err != nil {
+			return fmt.Errorf("save telegram_index: %w", err)
+		}
+		// Existing records have no value yet; the next suggestions rebuild reads them all anyway
+		_, err = app.DB().Update("telegram_index",
+			dbx.Params{"updated": types.NowDateTime().String()},
+			dbx.HashExp{"updated": ""}).Execute()
+		if err != nil {
+			return fmt.Errorf("backfill telegram_index.updated: %w", err)
+		}
+		return nil
+	}, func(app core.App) error {
+		col, err := app.FindCollectionByNameOrId("telegram_index")
+		if err != nil {
+			return nil
+		}
+		col.RemoveIndex("idx_telegram_index_updated")
+		col.Fields.RemoveByName("updated")
+		return app.Save(col)
+	})
+}
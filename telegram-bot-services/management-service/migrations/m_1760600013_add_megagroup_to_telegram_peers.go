@@ -0,0 +1,43 @@
+package migrations
+
+import (
+	"fmt"
+
+	"github.com/pocketbase/pocketbase/core"
+	m "github.com/pocketbase/pocketbase/migrations"
+)
+
+// Add telegram_peers.megagroup so cached supergroups keep their "supergroup" chat type after a
+// collection-service restart. Rows and messages cached before this migration are corrected from
+// telegram_index, where the bot records supergroups; run reindex-messages afterwards to update the
+// type of the messages already in Meilisearch.
+func init() {
+	m.Register(func(app core.App) error {
+		col, err := app.FindCollectionByNameOrId("telegram_peers")
+		if err != nil {
+			return fmt.Errorf("telegram_peers collection not found: %w", err)
+		}
+		if col.Fields.GetByName("megagroup") == nil {
+			col.Fields.Add(&core.BoolField{ Name: "megagroup" })
+		}
+		if err := app.Save(col); err != nil {
+			return fmt.Errorf("save telegram_peers: %w", err)
+		}
+
+		supergroups := "SELECT chat_id FROM telegram_index WHERE type = 'supergroup' AND chat_id != ''"
+		if _, err := app.DB().NewQuery("UPDATE telegram_peers SET megagroup = TRUE WHERE kind = 'channel' AND chat_id IN (" + supergroups + ")").Execute(); err != nil {
+			return fmt.Errorf("backfill telegram_peers.megagroup: %w", err)
+		}
+		if _, err := app.DB().NewQuery("UPDATE messages SET chat_type = 'supergroup' WHERE chat_type = 'channel' AND chat_id IN (" + supergroups + ")").Execute(); err != nil {
+			return fmt.Errorf("backfill messages.chat_type: %w", err)
+		}
+		return nil
+	}, func(app core.App) error {
+		col, err := app.FindCollectionByNameOrId("telegram_peers")
+		if err != nil {
+			return nil
+		}
+		col.Fields.RemoveByName("megagroup")
+		return app.Save(col)
+	})
+}
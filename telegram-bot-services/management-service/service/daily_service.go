@@ -0,0 +1,346 @@
+/*
+ * 文件功能描述：每日动态服务，提供新收录的群组/频道列表和成员增长、活跃度排行
+ * 主要类/接口说明：DailyService接口及其实现，数据来自 telegram_index 和 chat_stats_daily 集合
+ * 修改历史记录：
+ * @author fcj
+ * @date 2023-11-15
+ * @version 1.0.0
+ * © Telegram Bot Services Team
+ */
+
+package service
+
+import (
+    "errors"
+    "fmt"
+    "strconv"
+    "time"
+
+    "github.com/pocketbase/dbx"
+    "github.com/pocketbase/pocketbase/core"
+)
+
+const (
+    // dailyDateLayout 日期参数格式
+    dailyDateLayout = "2006-01-02"
+    // dailyMaxRangeDays 日期范围的最大天数
+    dailyMaxRangeDays = 90
+    // dailyDefaultLimit 默认每页（每个排行）的条数
+    dailyDefaultLimit = 20
+    // dailyMaxLimit 每页（每个排行）的最大条数
+    dailyMaxLimit = 100
+)
+
+// dailyChatTypes 按类型分组时的类型及顺序
+var dailyChatTypes = []string{"group", "supergroup", "channel", "bot"}
+
+// ErrInvalidDailyQuery 日期范围、类型或分页参数无效
+var ErrInvalidDailyQuery = errors.New("invalid daily query")
+
+// DailyChat 新收录的群组、频道或机器人
+// @author fcj
+// @date 2023-11-15
+// @version 1.0.0
+type DailyChat struct {
+    ChatID       string `json:"chat_id"`
+    Type         string `json:"type"`
+    Title        string `json:"title"`
+    Username     string `json:"username"`
+    Description  string `json:"description"`
+    MembersCount int    `json:"members_count"`
+    IsVerified   bool   `json:"is_verified"`
+    IndexedAt    string `json:"indexed_at"`
+}
+
+// DailyNewGroup 某一类型的新收录列表
+// @author fcj
+// @date 2023-11-15
+// @version 1.0.0
+type DailyNewGroup struct {
+    Type       string      `json:"type"`
+    TotalItems int         `json:"totalItems"`
+    TotalPages int         `json:"totalPages"`
+    Items      []DailyChat `json:"items"`
+}
+
+// DailyNewResult 新收录列表，按类型分组，每组使用相同的分页参数
+// @author fcj
+// @date 2023-11-15
+// @version 1.0.0
+type DailyNewResult struct {
+    From    string          `json:"from"`
+    To      string          `json:"to"`
+    Page    int             `json:"page"`
+    PerPage int             `json:"perPage"`
+    Groups  []DailyNewGroup `json:"groups"`
+}
+
+// DailyStatsEntry 日期范围内某个群组的统计
+// @author fcj
+// @date 2023-11-15
+// @version 1.0.0
+type DailyStatsEntry struct {
+    ChatID       string `json:"chat_id" db:"chat_id"`
+    Type         string `json:"type" db:"type"`
+    Title        string `json:"title" db:"title"`
+    Username     string `json:"username" db:"username"`
+    MembersCount int    `json:"members_count" db:"members_count"`
+    // MembersDelta 日期范围内的成员数变化
+    MembersDelta int `json:"members_delta" db:"members_delta"`
+    // MessagesDelta 日期范围内的新消息数
+    MessagesDelta int `json:"messages_delta" db:"messages_delta"`
+    // ActiveMembers 日期范围内快照的最大活跃成员数
+    ActiveMembers    int     `json:"active_members" db:"active_members"`
+    MembersChange7d  int     `json:"members_change_7d" db:"members_change_7d"`
+    MembersChange30d int     `json:"members_change_30d" db:"members_change_30d"`
+    GrowthRate       float64 `json:"growth_rate" db:"growth_rate"`
+}
+
+// DailyStatsResult 日期范围内的增长排行和活跃排行
+// @author fcj
+// @date 2023-11-15
+// @version 1.0.0
+type DailyStatsResult struct {
+    From    string            `json:"from"`
+    To      string            `json:"to"`
+    Growing []DailyStatsEntry `json:"growing"`
+    Active  []DailyStatsEntry `json:"active"`
+}
+
+// DailyService 定义每日动态服务接口
+// @author fcj
+// @date 2023-11-15
+// @version 1.0.0
+type DailyService interface {
+    // NewChats 列出日期范围内首次收录的群组、频道和机器人，按类型分组分页
+    NewChats(from string, to string, chatType string, page string, limit string) (*DailyNewResult, error)
+
+    // Stats 返回日期范围内成员增长最多和消息最多的群组
+    Stats(from string, to string, chatType string, limit string) (*DailyStatsResult, error)
+}
+
+// dailyServiceImpl 实现DailyService接口，直接查询 PocketBase 数据库
+// @author fcj
+// @date 2023-11-15
+// @version 1.0.0
+type dailyServiceImpl struct {
+    app core.App
+}
+
+// NewDailyService 创建每日动态服务
+// @author fcj
+// @date 2023-11-15
+// @version 1.0.0
+// @param app PocketBase 应用
+// @return DailyService 每日动态服务实例
+func NewDailyService(app core.App) DailyService {
+    return &dailyServiceImpl{app: app}
+}
+
+// NewChats 列出日期范围内首次收录的群组、频道和机器人
+// @author fcj
+// @date 2023-11-15
+// @version 1.0.0
+// @param from 起始日期（YYYY-MM-DD，含），默认前一天
+// @param to 结束日期（YYYY-MM-DD，含），默认当天
+// @param chatType 类型：group、supergroup、channel、bot，为空时返回全部类型
+// @param page 页码，默认 1
+// @param limit 每页条数，默认 20
+// @return *DailyNewResult 按类型分组的新收录列表
+// @return error 错误信息
+func (s *dailyServiceImpl) NewChats(from string, to string, chatType string, page string, limit string) (*DailyNewResult, error) {
+    start, end, err := parseDailyRange(from, to)
+    if err != nil {
+        return nil, err
+    }
+    types, err := parseDailyTypes(chatType)
+    if err != nil {
+        return nil, err
+    }
+    pageNum, err := parseDailyInt("page", page, 1, 1, 0)
+    if err != nil {
+        return nil, err
+    }
+    perPage, err := parseDailyInt("limit", limit, dailyDefaultLimit, 1, dailyMaxLimit)
+    if err != nil {
+        return nil, err
+    }
+
+    // indexed_at 为 PocketBase 日期字段（UTC），结束日期取次日零点（不含）
+    params := dbx.Params{
+        "from": start.Format(dailyDateLayout) + " 00:00:00.000Z",
+        "to":   end.AddDate(0, 0, 1).Format(dailyDateLayout) + " 00:00:00.000Z",
+    }
+    result := &DailyNewResult{
+        From:    start.Format(dailyDateLayout),
+        To:      end.Format(dailyDateLayout),
+        Page:    pageNum,
+        PerPage: perPage,
+        Groups:  []DailyNewGroup{},
+    }
+    for _, t := range types {
+        params["type"] = t
+        total, err := s.app.CountRecords("telegram_index",
+            dbx.NewExp("indexed_at >= {:from} AND indexed_at < {:to} AND type = {:type}", params))
+        if err != nil {
+            return nil, fmt.Errorf("failed to count new chats: %w", err)
+        }
+        if total == 0 {
+            continue
+        }
+        records, err := s.app.FindRecordsByFilter("telegram_index",
+            "indexed_at >= {:from} && indexed_at < {:to} && type = {:type}",
+            "-indexed_at", perPage, (pageNum-1)*perPage, params)
+        if err != nil {
+            return nil, fmt.Errorf("failed to list new chats: %w", err)
+        }
+        group := DailyNewGroup{
+            Type:       t,
+            TotalItems: int(total),
+            TotalPages: (int(total) + perPage - 1) / perPage,
+            Items:      make([]DailyChat, 0, len(records)),
+        }
+        for _, record := range records {
+            group.Items = append(group.Items, DailyChat{
+                ChatID:       record.GetString("chat_id"),
+                Type:         record.GetString("type"),
+                Title:        record.GetString("title"),
+                Username:     record.GetString("username"),
+                Description:  record.GetString("description"),
+                MembersCount: record.GetInt("members_count"),
+                IsVerified:   record.GetBool("is_verified"),
+                IndexedAt:    record.GetDateTime("indexed_at").String(),
+            })
+        }
+        result.Groups = append(result.Groups, group)
+    }
+    return result, nil
+}
+
+// Stats 返回日期范围内成员增长最多和消息最多的群组
+// @author fcj
+// @date 2023-11-15
+// @version 1.0.0
+// @param from 起始日期（YYYY-MM-DD，含），默认前一天
+// @param to 结束日期（YYYY-MM-DD，含），默认当天
+// @param chatType 类型：group、supergroup、channel，为空时返回全部类型
+// @param limit 每个排行的条数，默认 20
+// @return *DailyStatsResult 增长排行和活跃排行
+// @return error 错误信息
+func (s *dailyServiceImpl) Stats(from string, to string, chatType string, limit string) (*DailyStatsResult, error) {
+    start, end, err := parseDailyRange(from, to)
+    if err != nil {
+        return nil, err
+    }
+    if _, err := parseDailyTypes(chatType); err != nil {
+        return nil, err
+    }
+    size, err := parseDailyInt("limit", limit, dailyDefaultLimit, 1, dailyMaxLimit)
+    if err != nil {
+        return nil, err
+    }
+
+    params := dbx.Params{
+        "from":  start.Format(dailyDateLayout),
+        "to":    end.Format(dailyDateLayout),
+        "type":  chatType,
+        "limit": size,
+    }
+    result := &DailyStatsResult{
+        From: start.Format(dailyDateLayout),
+        To:   end.Format(dailyDateLayout),
+    }
+    if result.Growing, err = s.rankStats(params, "members_delta"); err != nil {
+        return nil, err
+    }
+    if result.Active, err = s.rankStats(params, "messages_delta"); err != nil {
+        return nil, err
+    }
+    return result, nil
+}
+
+// rankStats 汇总日期范围内的快照，按 orderBy（members_delta 或 messages_delta）降序返回变化为正的群组
+func (s *dailyServiceImpl) rankStats(params dbx.Params, orderBy string) ([]DailyStatsEntry, error) {
+    query := `SELECT s.chat_id AS chat_id, t.type AS type, t.title AS title, t.username AS username,
+            t.members_count AS members_count, t.members_change_7d AS members_change_7d,
+            t.members_change_30d AS members_change_30d, t.growth_rate AS growth_rate,
+            SUM(s.members_delta) AS members_delta, SUM(s.messages_delta) AS messages_delta,
+            MAX(s.active_members) AS active_members
+        FROM chat_stats_daily s
+        JOIN telegram_index t ON t.chat_id = s.chat_id
+        WHERE s.date >= {:from} AND s.date <= {:to} AND ({:type} = '' OR t.type = {:type})
+        GROUP BY s.chat_id
+        HAVING ` + orderBy + ` > 0
+        ORDER BY ` + orderBy + ` DESC
+        LIMIT {:limit}`
+
+    entries := []DailyStatsEntry{}
+    if err := s.app.DB().NewQuery(query).Bind(params).All(&entries); err != nil {
+        return nil, fmt.Errorf("failed to aggregate chat stats: %w", err)
+    }
+    return entries, nil
+}
+
+// parseDailyRange 解析日期范围，默认为前一天到当天（UTC）
+func parseDailyRange(from string, to string) (time.Time, time.Time, error) {
+    end := time.Now().UTC().Truncate(24 * time.Hour)
+    if to != "" {
+        parsed, err := time.Parse(dailyDateLayout, to)
+        if err != nil {
+            return time.Time{}, time.Time{}, fmt.Errorf("%w: to must be YYYY-MM-DD", ErrInvalidDailyQuery)
+        }
+        end = parsed
+    }
+    start := end.AddDate(0, 0, -1)
+    if from != "" {
+        parsed, err := time.Parse(dailyDateLayout, from)
+        if err != nil {
+            return time.Time{}, time.Time{}, fmt.Errorf("%w: from must be YYYY-MM-DD", ErrInvalidDailyQuery)
+        }
+        start = parsed
+    }
+    if start.After(end) {
+        return time.Time{}, time.Time{}, fmt.Errorf("%w: from is after to", ErrInvalidDailyQuery)
+    }
+    if end.Sub(start) > dailyMaxRangeDays*24*time.Hour {
+        return time.Time{}, time.Time{}, fmt.Errorf("%w: range exceeds %d days", ErrInvalidDailyQuery, dailyMaxRangeDays)
+    }
+    return start, end, nil
+}
+
+// parseDailyTypes 解析类型参数，为空时返回全部类型
+func parseDailyTypes(chatType string) ([]string, error) {
+    if chatType == "" {
+        return dailyChatTypes, nil
+    }
+    for _, t := range dailyChatTypes {
+        if t == chatType {
+            return []string{chatType}, nil
+        }
+    }
+    return nil, fmt.Errorf("%w: unknown type %q", ErrInvalidDailyQuery, chatType)
+}
+
+// parseDailyInt 解析整数参数，为空时返回默认值；maxValue 为 0 表示不限制上限
+func parseDailyInt(name string, value string, defaultValue int, minValue int, maxValue int) (int, error) {
+    if value == "" {
+        return defaultValue, nil
+    }
+    n, err := strconv.Atoi(value)
+    if err != nil || n < minValue || (maxValue > 0 && n > maxValue) {
+        return 0, fmt.Errorf("%w: %s out of range", ErrInvalidDailyQuery, name)
+    }
+    return n, nil
+}
+
+/*
+ * 关键算法说明：
+ * 1. 新收录：按 telegram_index.indexed_at（首次创建记录时写入）筛选，每个类型单独计数和分页
+ * 2. 排行：chat_stats_daily 中每天的 members_delta、messages_delta 为相对前一个快照的变化，按日期范围求和后排序
+ *
+ * 待优化事项：
+ * 1. 缓存：排行数据每天变化不大，可按日期范围缓存
+ *
+ * 兼容性说明：
+ * 1. 依赖采集服务的元数据补全任务写入 chat_stats_daily，未开启补全时排行为空
+ */
@@ -0,0 +1,202 @@
+/*
+ * 文件功能描述：消息索引重建服务，从 PocketBase messages 集合重新生成 Meilisearch 消息索引
+ * 主要类/接口说明：ReindexService接口及其实现，文档结构为 search.Message，用于字段命名调整后迁移旧文档
+ * 修改历史记录：
+ * @author fcj
+ * @date 2023-11-15
+ * @version 1.0.0
+ * © Telegram Bot Services Team
+ */
+
+package service
+
+import (
+    "context"
+    "fmt"
+    "strconv"
+
+    "telegram-bot-services/pkg/search"
+
+    "github.com/pocketbase/pocketbase/core"
+)
+
+const (
+    // reindexPageSize 每次从 messages 集合读取的记录数
+    reindexPageSize = 1000
+    // reindexSuffix 重建时临时索引的后缀，完成后与正式索引交换
+    reindexSuffix = "_rebuild"
+)
+
+// ReindexConfig 重建服务配置
+// @author fcj
+// @date 2023-11-15
+// @version 1.0.0
+type ReindexConfig struct {
+    MeilisearchURL string
+    MeilisearchKey string
+    // IndexName 消息索引名称，默认 messages
+    IndexName string
+}
+
+// ReindexService 定义消息索引重建接口
+// @author fcj
+// @date 2023-11-15
+// @version 1.0.0
+type ReindexService interface {
+    // ReindexMessages 把 messages 集合的全部记录写入临时索引，完成后与正式索引交换，返回写入的文档数
+    ReindexMessages() (int, error)
+}
+
+// reindexServiceImpl 实现ReindexService接口，直接读取 PocketBase 数据库
+// @author fcj
+// @date 2023-11-15
+// @version 1.0.0
+type reindexServiceImpl struct {
+    app       core.App
+    meili     *search.Client
+    indexName string
+}
+
+// NewReindexService 创建消息索引重建服务
+// @author fcj
+// @date 2023-11-15
+// @version 1.0.0
+// @param app PocketBase 应用
+// @param config 重建服务配置
+// @return ReindexService 重建服务实例
+func NewReindexService(app core.App, config ReindexConfig) ReindexService {
+    if config.IndexName == "" {
+        config.IndexName = search.DefaultMessagesIndex
+    }
+    return &reindexServiceImpl{
+        app: app,
+        meili: search.New(search.Config{
+            URL:           config.MeilisearchURL,
+            Key:           config.MeilisearchKey,
+            MessagesIndex: config.IndexName,
+        }),
+        indexName: config.IndexName,
+    }
+}
+
+// ReindexMessages 重建消息索引，重建期间正式索引照常可用
+// @author fcj
+// @date 2023-11-15
+// @version 1.0.0
+// @return int 写入的文档数
+// @return error 错误信息
+func (s *reindexServiceImpl) ReindexMessages() (int, error) {
+    ctx := context.Background()
+    tmp := s.indexName + reindexSuffix
+    if err := s.meili.DeleteIndex(ctx, tmp); err != nil {
+        return 0, err
+    }
+    if err := s.meili.CreateIndex(ctx, tmp, search.MessageSettings); err != nil {
+        return 0, err
+    }
+
+    total := 0
+    for offset := 0; ; offset += reindexPageSize {
+        records, err := s.app.FindRecordsByFilter("messages", "", "id", reindexPageSize, offset)
+        if err != nil {
+            return total, fmt.Errorf("failed to list messages: %w", err)
+        }
+        if len(records) == 0 {
+            break
+        }
+        docs := make([]search.Message, 0, len(records))
+        for _, record := range records {
+            docs = append(docs, messageDocument(record))
+        }
+        if err := s.meili.AddDocuments(ctx, tmp, docs); err != nil {
+            return total, err
+        }
+        total += len(docs)
+    }
+
+    // 交换要求两个索引都存在
+    exists, err := s.meili.IndexExists(ctx, s.indexName)
+    if err != nil {
+        return total, fmt.Errorf("failed to check index %s: %w", s.indexName, err)
+    }
+    if !exists {
+        if err := s.meili.CreateIndex(ctx, s.indexName, search.MessageSettings); err != nil {
+            return total, err
+        }
+    }
+    if err := s.meili.SwapIndexes(ctx, [2]string{s.indexName, tmp}); err != nil {
+        return total, err
+    }
+    // 交换后临时索引中是旧文档
+    if err := s.meili.DeleteIndex(ctx, tmp); err != nil {
+        return total, err
+    }
+    return total, nil
+}
+
+// messageDocument 将 messages 集合的记录转换为消息索引的文档，与机器人服务和采集服务写入的文档相同
+func messageDocument(record *core.Record) search.Message {
+    chatID := parseID(record.GetString("chat_id"))
+    messageID := record.GetInt("message_id")
+    var urls, mentions, hashtags []string
+    _ = record.UnmarshalJSONField("urls", &urls)
+    _ = record.UnmarshalJSONField("mentions", &mentions)
+    _ = record.UnmarshalJSONField("hashtags", &hashtags)
+    mediaType := record.GetString("media_type")
+    return search.Message{
+        ID:               search.MessageDocumentID(chatID, messageID),
+        ChatID:           chatID,
+        MessageID:        messageID,
+        Title:            record.GetString("chat_title"),
+        Username:         record.GetString("chat_username"),
+        Type:             record.GetString("chat_type"),
+        SenderID:         parseID(record.GetString("sender_id")),
+        SenderName:       record.GetString("sender_name"),
+        Date:             unixTime(record, "date"),
+        Text:             record.GetString("text"),
+        MediaType:        mediaType,
+        HasMedia:         mediaType != "",
+        FileName:         record.GetString("file_name"),
+        FileSize:         int64(record.GetInt("file_size")),
+        MimeType:         record.GetString("mime_type"),
+        URLs:             urls,
+        HasLink:          len(urls) > 0,
+        Mentions:         mentions,
+        Hashtags:         hashtags,
+        ForwardFromID:    parseID(record.GetString("forward_from_id")),
+        ForwardFromName:  record.GetString("forward_from_name"),
+        IsForward:        !record.GetDateTime("forward_date").IsZero(),
+        ReplyToMessageID: record.GetInt("reply_to_message_id"),
+        Views:            record.GetInt("views"),
+        Forwards:         record.GetInt("forwards"),
+        Reactions:        record.GetInt("reactions"),
+        EditDate:         unixTime(record, "edit_date"),
+        GroupedID:        parseID(record.GetString("grouped_id")),
+        MediaKey:         record.GetString("media_key"),
+        ThumbnailKey:     record.GetString("thumbnail_key"),
+    }
+}
+
+// parseID 解析保存为文本的数字ID，为空或无效时返回 0
+func parseID(s string) int64 {
+    id, _ := strconv.ParseInt(s, 10, 64)
+    return id
+}
+
+// unixTime 返回日期字段的 Unix 时间，未设置时返回 0
+func unixTime(record *core.Record, key string) int64 {
+    date := record.GetDateTime(key)
+    if date.IsZero() {
+        return 0
+    }
+    return date.Time().Unix()
+}
+
+/*
+ * 关键算法说明：
+ * 1. 重建：记录按 id 分页读取，转换后写入临时索引，全部写入后与正式索引原子交换，再删除临时索引
+ * 2. 迁移：消息索引的字段改为小写（chat_id、message_id、date 等）后，旧的大写字段文档通过重建替换
+ *
+ * 待优化事项：
+ * 1. 重建期间新写入正式索引的消息不会进入临时索引；这些消息已保存在 messages 集合中，再运行一次重建即可补齐
+ */
@@ -0,0 +1,270 @@
+/*
+ * 文件功能描述：搜索日志服务，记录网页端的搜索请求，并按时间窗口统计热门搜索
+ * 主要类/接口说明：SearchLogService接口及其实现，数据保存在 search_logs 集合（机器人服务也写入该集合）
+ * 修改历史记录：
+ * @author fcj
+ * @date 2023-11-15
+ * @version 1.0.0
+ * © Telegram Bot Services Team
+ */
+
+package service
+
+import (
+    "errors"
+    "fmt"
+    "maps"
+    "slices"
+    "strings"
+    "time"
+    "unicode/utf8"
+
+    "github.com/pocketbase/dbx"
+    "github.com/pocketbase/pocketbase/core"
+)
+
+const (
+    // searchLogsCollection 搜索日志集合
+    searchLogsCollection = "search_logs"
+    // maxLoggedQueryLength 记录的查询最大字符数，与集合字段长度一致
+    maxLoggedQueryLength = 256
+    // pocketBaseDateLayout PocketBase 日期字段的存储格式
+    pocketBaseDateLayout = "2006-01-02 15:04:05.000Z"
+    // defaultTrendingLimit 默认返回的热门搜索数
+    defaultTrendingLimit = 10
+    // maxTrendingLimit 最多返回的热门搜索数
+    maxTrendingLimit = 50
+    // defaultTrendingWindow 默认统计窗口
+    defaultTrendingWindow = "24h"
+)
+
+// trendingWindows 支持的统计窗口
+var trendingWindows = map[string]time.Duration{
+    "1h":  time.Hour,
+    "24h": 24 * time.Hour,
+    "7d":  7 * 24 * time.Hour,
+}
+
+// searchLogRetention 搜索日志保留时长：最长统计窗口的两倍，趋势需要当前窗口和上一个等长窗口
+var searchLogRetention = 2 * slices.Max(slices.Collect(maps.Values(trendingWindows)))
+
+// ErrInvalidTrendingQuery 统计窗口或数量参数无效
+var ErrInvalidTrendingQuery = errors.New("invalid trending query")
+
+// SearchLogEntry 一次搜索
+// @author fcj
+// @date 2023-11-15
+// @version 1.0.0
+type SearchLogEntry struct {
+    Query  string
+    Filter string
+    Hits   int
+    UserID string
+    // Source 搜索来源：bot、callback（机器人翻页或切换筛选）、web
+    Source string
+}
+
+// TrendingKeyword 热门搜索
+// @author fcj
+// @date 2023-11-15
+// @version 1.0.0
+type TrendingKeyword struct {
+    Keyword string `json:"keyword"`
+    // Trend 与上一个等长窗口相比：hot（新出现或翻倍）、up、stable、down
+    Trend string `json:"trend"`
+    Count int    `json:"count"`
+    Rank  int    `json:"rank"`
+    // Category 该关键词最常用的筛选类型，未筛选时为 all
+    Category string `json:"category"`
+}
+
+// SearchLogService 定义搜索日志服务接口
+// @author fcj
+// @date 2023-11-15
+// @version 1.0.0
+type SearchLogService interface {
+    // Log 记录一次搜索
+    Log(entry SearchLogEntry) error
+
+    // Trending 返回统计窗口内搜索次数最多的查询
+    Trending(window string, limit string) ([]TrendingKeyword, error)
+
+    // Purge 删除超过保留时长（14 天）的搜索日志，返回删除的记录数
+    Purge() (int64, error)
+}
+
+// searchLogServiceImpl 实现SearchLogService接口，直接读写 PocketBase 数据库
+// @author fcj
+// @date 2023-11-15
+// @version 1.0.0
+type searchLogServiceImpl struct {
+    app core.App
+    now func() time.Time
+}
+
+// NewSearchLogService 创建搜索日志服务
+// @author fcj
+// @date 2023-11-15
+// @version 1.0.0
+// @param app PocketBase 应用
+// @return SearchLogService 搜索日志服务实例
+func NewSearchLogService(app core.App) SearchLogService {
+    return &searchLogServiceImpl{app: app, now: time.Now}
+}
+
+// Log 记录一次搜索
+// @author fcj
+// @date 2023-11-15
+// @version 1.0.0
+// @param entry 搜索
+// @return error 错误信息
+func (s *searchLogServiceImpl) Log(entry SearchLogEntry) error {
+    query := truncateRunes(strings.TrimSpace(entry.Query), maxLoggedQueryLength)
+    if query == "" {
+        return nil
+    }
+    collection, err := s.app.FindCachedCollectionByNameOrId(searchLogsCollection)
+    if err != nil {
+        return fmt.Errorf("search_logs collection not found: %w", err)
+    }
+    record := core.NewRecord(collection)
+    record.Set("query", query)
+    record.Set("normalized", NormalizeQuery(query))
+    record.Set("filter", entry.Filter)
+    record.Set("hits", entry.Hits)
+    record.Set("user_id", entry.UserID)
+    record.Set("source", entry.Source)
+    if err := s.app.Save(record); err != nil {
+        return fmt.Errorf("failed to save search log: %w", err)
+    }
+    return nil
+}
+
+// Trending 返回统计窗口内搜索次数最多的查询
+// @author fcj
+// @date 2023-11-15
+// @version 1.0.0
+// @param window 统计窗口：1h、24h（默认）、7d
+// @param limit 返回数量，默认 10，最多 50
+// @return []TrendingKeyword 按搜索次数降序排列的热门搜索
+// @return error 错误信息
+func (s *searchLogServiceImpl) Trending(window string, limit string) ([]TrendingKeyword, error) {
+    if window == "" {
+        window = defaultTrendingWindow
+    }
+    duration, ok := trendingWindows[window]
+    if !ok {
+        return nil, fmt.Errorf("%w: window must be 1h, 24h or 7d", ErrInvalidTrendingQuery)
+    }
+    size := defaultTrendingLimit
+    if limit != "" {
+        size = atoi(limit)
+        if size < 1 || size > maxTrendingLimit {
+            return nil, fmt.Errorf("%w: limit must be between 1 and %d", ErrInvalidTrendingQuery, maxTrendingLimit)
+        }
+    }
+
+    // 同时统计当前窗口和上一个等长窗口的次数，用于判断趋势；翻页和切换筛选不算新的搜索
+    now := s.now().UTC()
+    start := now.Add(-duration)
+    var rows []struct {
+        Keyword  string `db:"keyword"`
+        Count    int    `db:"count"`
+        Previous int    `db:"previous"`
+        Category string `db:"category"`
+    }
+    err := s.app.DB().NewQuery(`SELECT l.normalized AS keyword,
+            SUM(CASE WHEN l.created >= {:start} THEN 1 ELSE 0 END) AS count,
+            SUM(CASE WHEN l.created < {:start} THEN 1 ELSE 0 END) AS previous,
+            (SELECT f.filter FROM search_logs f
+                WHERE f.normalized = l.normalized AND f.created >= {:start} AND f.source != 'callback'
+                GROUP BY f.filter ORDER BY COUNT(*) DESC LIMIT 1) AS category
+        FROM search_logs l
+        WHERE l.created >= {:previousStart} AND l.source != 'callback' AND l.normalized != ''
+        GROUP BY l.normalized
+        HAVING count > 0
+        ORDER BY count DESC, keyword
+        LIMIT {:limit}`).
+        Bind(dbx.Params{
+            "start":         start.Format(pocketBaseDateLayout),
+            "previousStart": start.Add(-duration).Format(pocketBaseDateLayout),
+            "limit":         size,
+        }).
+        All(&rows)
+    if err != nil {
+        return nil, fmt.Errorf("failed to aggregate search logs: %w", err)
+    }
+
+    trending := make([]TrendingKeyword, 0, len(rows))
+    for i, row := range rows {
+        category := row.Category
+        if category == "" {
+            category = "all"
+        }
+        trending = append(trending, TrendingKeyword{
+            Keyword:  row.Keyword,
+            Trend:    trendDirection(row.Count, row.Previous),
+            Count:    row.Count,
+            Rank:     i + 1,
+            Category: category,
+        })
+    }
+    return trending, nil
+}
+
+// Purge 删除超过保留时长的搜索日志，日志包含用户ID，不再参与统计后不再保留
+// @author fcj
+// @date 2023-11-15
+// @version 1.0.0
+// @return int64 删除的记录数
+// @return error 错误信息
+func (s *searchLogServiceImpl) Purge() (int64, error) {
+    cutoff := s.now().UTC().Add(-searchLogRetention)
+    result, err := s.app.DB().Delete(searchLogsCollection,
+        dbx.NewExp("created < {:cutoff}", dbx.Params{"cutoff": cutoff.Format(pocketBaseDateLayout)})).Execute()
+    if err != nil {
+        return 0, fmt.Errorf("failed to purge search logs: %w", err)
+    }
+    n, _ := result.RowsAffected()
+    return n, nil
+}
+
+// trendDirection 比较当前窗口和上一个窗口的搜索次数
+func trendDirection(count int, previous int) string {
+    switch {
+    case previous == 0 || count >= 2*previous:
+        return "hot"
+    case float64(count) > 1.2*float64(previous):
+        return "up"
+    case float64(count) < 0.8*float64(previous):
+        return "down"
+    default:
+        return "stable"
+    }
+}
+
+// NormalizeQuery 规范化查询用于统计：转为小写、去掉首尾空白并合并连续空白
+// @param query 原始查询
+// @return string 规范化后的查询
+func NormalizeQuery(query string) string {
+    return strings.Join(strings.Fields(strings.ToLower(query)), " ")
+}
+
+// truncateRunes 按字符截断字符串
+func truncateRunes(s string, max int) string {
+    if utf8.RuneCountInString(s) <= max {
+        return s
+    }
+    return string([]rune(s)[:max])
+}
+
+/*
+ * 关键算法说明：
+ * 1. 统计：按规范化后的查询分组，只统计 bot 和 web 来源，callback（翻页、切换筛选）只记录不计数
+ * 2. 趋势：与上一个等长窗口比较，上一个窗口没有出现或次数翻倍为 hot，增加超过 20% 为 up，减少超过 20% 为 down，否则为 stable
+ *
+ * 3. 清理：只保留最长统计窗口两倍（14 天）内的日志，管理服务每天定时删除更早的记录
+ *
+ * 待优化事项：
+ * 1. 统计直接扫描 search_logs，日志量很大时可按小时预聚合
+ */
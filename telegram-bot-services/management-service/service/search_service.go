@@ -1,6 +1,6 @@
 /*
  * 文件功能描述：搜索服务，处理搜索请求和结果处理
- * 主要类/接口说明：SearchService接口及其实现
+ * 主要类/接口说明：SearchService接口及其实现（搜索、搜索建议）
  * 修改历史记录：
  * @author fcj
  * @date 2023-11-15
@@ -11,23 +11,24 @@
 package service
 
 import (
-    "encoding/json"
+    "context"
     "fmt"
-    "net/url"
     "strconv"
+    "strings"
 
-    "github.com/go-resty/resty/v2"
+    "telegram-bot-services/pkg/search"
 )
 
-// SearchResult 搜索结果结构
-// @author fcj
-// @date 2023-11-15
-// @version 1.0.0
-type SearchResult struct {
-    Hits       []map[string]interface{} `json:"hits"`
-    TotalHits  int                      `json:"totalHits"`
-    TotalPages int                      `json:"totalPages"`
-}
+const (
+    // suggestionsIndexName 搜索建议索引，由 suggest.Builder 维护
+    suggestionsIndexName = "suggestions"
+    // defaultSuggestionLimit 默认返回的建议数
+    defaultSuggestionLimit = 10
+    // maxSuggestionLimit 最多返回的建议数
+    maxSuggestionLimit = 50
+    // defaultSearchLimit 默认每页结果数
+    defaultSearchLimit = 5
+)
 
 // SearchConfig 搜索服务配置
 // @author fcj
@@ -36,6 +37,9 @@ type SearchResult struct {
 type SearchConfig struct {
     MeilisearchURL string
     MeilisearchKey string
+    // ChatWeight、MessageWeight 综合搜索时群组和消息结果的权重，为 0 时使用 search 包的默认值
+    ChatWeight    float64
+    MessageWeight float64
 }
 
 // SearchService 定义搜索服务接口
@@ -44,7 +48,10 @@ type SearchConfig struct {
 // @version 1.0.0
 type SearchService interface {
     // Search 执行搜索操作
-    Search(query string, page string, limit string, filter string) (*SearchResult, error)
+    Search(q string, page string, limit string, filter string, opts search.Options) (*search.Result, error)
+
+    // Suggest 返回以 query 为前缀的搜索建议，用户最近的查询排在前面
+    Suggest(query string, limit string, recent []string) ([]string, error)
 }
 
 // searchServiceImpl 实现SearchService接口
@@ -53,7 +60,7 @@ type SearchService interface {
 // @version 1.0.0
 type searchServiceImpl struct {
     config SearchConfig
-    client *resty.Client
+    search *search.Client
 }
 
 // NewSearchService 创建新的搜索服务实例
@@ -65,7 +72,12 @@ type searchServiceImpl struct {
 func NewSearchService(config SearchConfig) SearchService {
     return &searchServiceImpl{
         config: config,
-        client: resty.New(),
+        search: search.New(search.Config{
+            URL:           config.MeilisearchURL,
+            Key:           config.MeilisearchKey,
+            ChatWeight:    config.ChatWeight,
+            MessageWeight: config.MessageWeight,
+        }),
     }
 }
 
@@ -73,68 +85,94 @@ func NewSearchService(config SearchConfig) SearchService {
 // @author fcj
 // @date 2023-11-15
 // @version 1.0.0
-// @param query 搜索查询
+// @param q 搜索查询，支持短语、排除词和 type:、members:、lang:、in:、after:、before: 等限定条件（见 query 包）
 // @param page 页码
 // @param limit 每页结果数
-// @param filter 过滤条件
-// @return *SearchResult 搜索结果
-// @return error 错误信息
-func (s *searchServiceImpl) Search(query string, page string, limit string, filter string) (*SearchResult, error) {
+// @param filter 搜索范围：group、channel、bot 搜索群组索引，message 搜索消息索引，all 或为空时综合搜索群组和消息
+// @param opts 排序、时间范围和分面；群组索引不支持时间范围，消息索引只支持 relevance、newest 排序和 type 分面，综合搜索只按相关度排序
+// @return *search.Result 搜索结果，群组和消息使用同一结构，以 kind 区分
+// @return error 错误信息，查询语法错误或参数不支持时可用 errors.Is(err, query.ErrInvalidQuery) 判断
+func (s *searchServiceImpl) Search(q string, page string, limit string, filter string, opts search.Options) (*search.Result, error) {
     // 设置默认值
-    if page == "" {
-        page = "1"
+    size := atoi(limit)
+    if size < 1 {
+        size = defaultSearchLimit
     }
-    if limit == "" {
-        limit = "5"
+    if filter == "" {
+        filter = search.ScopeAll
     }
 
-    // 构建查询参数
-    params := url.Values{}
-    params.Set("q", query)
-    params.Set("offset", fmt.Sprintf("%d", (atoi(page)-1)*atoi(limit)))
-    params.Set("limit", limit)
-
-    // 根据过滤条件设置筛选参数
-    switch filter {
-    case "group":
-        params.Set("filter", "chat_type = 'group'")
-    case "channel":
-        params.Set("filter", "chat_type = 'channel'")
-    case "bot":
-        params.Set("filter", "sender_id LIKE '%bot%'")
-    case "message", "":
-        // 默认不添加筛选
+    return s.search.Search(context.Background(), search.Request{
+        Query:       q,
+        Scope:       filter,
+        Page:        atoi(page),
+        HitsPerPage: size,
+        Options:     opts,
+    })
+}
+
+// Suggest 返回搜索建议
+// @author fcj
+// @date 2023-11-15
+// @version 1.0.0
+// @param query 已输入的查询前缀，为空时返回最常见的关键词
+// @param limit 返回的建议数，默认 10，最多 50
+// @param recent 用户最近的查询（由客户端提供），与前缀匹配的优先返回
+// @return []string 去重后的建议
+// @return error 错误信息
+func (s *searchServiceImpl) Suggest(query string, limit string, recent []string) ([]string, error) {
+    size := atoi(limit)
+    if size <= 0 {
+        size = defaultSuggestionLimit
+    }
+    if size > maxSuggestionLimit {
+        size = maxSuggestionLimit
+    }
+    query = strings.TrimSpace(query)
+    prefix := strings.ToLower(query)
+
+    suggestions := make([]string, 0, size)
+    seen := make(map[string]bool)
+    add := func(suggestion string) {
+        suggestion = strings.TrimSpace(suggestion)
+        key := strings.ToLower(suggestion)
+        if suggestion == "" || seen[key] || len(suggestions) >= size {
+            return
+        }
+        seen[key] = true
+        suggestions = append(suggestions, suggestion)
     }
 
-    // 执行搜索请求
-    resp, err := s.client.R().
-        SetHeader("Authorization", "Bearer "+s.config.MeilisearchKey).
-        SetQueryParamsFromValues(params).
-        Get(s.config.MeilisearchURL + "/indexes/messages/search")
-    if err != nil {
-        return nil, fmt.Errorf("search failed: %w", err)
+    // 用户最近的查询优先
+    for _, r := range recent {
+        if strings.HasPrefix(strings.ToLower(strings.TrimSpace(r)), prefix) {
+            add(r)
+        }
+    }
+    if len(suggestions) >= size {
+        return suggestions, nil
     }
 
-    // 解析搜索结果
+    // 索引的排序规则按 typo 之后的 frequency、popularity 降序排列，最后一个词按前缀匹配；
+    // 只返回达到最少群组数的关键词（visible），多取一些结果，去重后再截断
     var result struct {
-        Hits      []map[string]interface{} `json:"hits"`
-        TotalHits int                      `json:"totalHits"`
+        Hits []struct {
+            Query string `json:"query"`
+        } `json:"hits"`
     }
-    if err := json.Unmarshal(resp.Body(), &result); err != nil {
-        return nil, fmt.Errorf("failed to parse search result: %w", err)
+    err := s.search.SearchIndex(context.Background(), suggestionsIndexName, map[string]interface{}{
+        "q":                    query,
+        "limit":                size * 2,
+        "filter":               "visible = true",
+        "attributesToRetrieve": []string{"query"},
+    }, &result)
+    if err != nil {
+        return nil, fmt.Errorf("suggestions search failed: %w", err)
     }
-
-    // 计算总页数
-    totalPages := (result.TotalHits + atoi(limit) - 1) / atoi(limit)
-
-    // 构建搜索结果
-    searchResult := &SearchResult{
-        Hits:       result.Hits,
-        TotalHits:  result.TotalHits,
-        TotalPages: totalPages,
+    for _, hit := range result.Hits {
+        add(hit.Query)
     }
-
-    return searchResult, nil
+    return suggestions, nil
 }
 
 // atoi 将字符串转换为整数
@@ -150,8 +188,10 @@ func atoi(s string) int {
 
 /*
  * 关键算法说明：
- * 1. 搜索分页：使用offset和limit实现分页
- * 2. 过滤条件：根据不同的过滤条件构建Meilisearch查询
+ * 1. 搜索由 search 包完成，bot-service 使用同一实现：单一范围查询对应索引，综合搜索用 Meilisearch 联邦多索引搜索按权重合并群组和消息
+ * 2. 过滤条件：排序、时间范围和分面参数由 query 包按索引字段校验和转换，综合搜索时跳过不支持查询条件的索引
+ * 3. 高亮摘要：Meilisearch 用私用区字符标记匹配词，先转义原文再换成 <mark>，结果中的 HTML 总是安全的
+ * 4. 搜索建议：用户最近的查询优先，其余来自 suggestions 索引（按关键词出现的群组数和群组规模排序），忽略大小写去重
  * 
  * 待优化事项：
  * 1. 错误处理：改进错误处理和恢复机制
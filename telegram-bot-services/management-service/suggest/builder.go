@@ -0,0 +1,620 @@
+/*
+ * 文件功能描述：搜索建议索引构建器，从 telegram_index 增量维护 suggestions 索引中每个关键词的文档频率和热度
+ * 主要类/接口说明：Builder 构建器（Update 增量更新、Reconcile 清理已删除的群组、Rebuild 全量重建）、Suggestion 建议文档、Stats 运行统计
+ * 修改历史记录：
+ * @author fcj
+ * @date 2023-11-15
+ * @version 1.0.0
+ * © Telegram Bot Services Team
+ */
+
+package suggest
+
+import (
+    "context"
+    "fmt"
+    "log"
+    "math"
+    "sort"
+    "sync"
+    "time"
+
+    "management-service/suggest/tokenizer"
+
+    "telegram-bot-services/pkg/search"
+
+    "github.com/pocketbase/dbx"
+    "github.com/pocketbase/pocketbase/core"
+    "github.com/pocketbase/pocketbase/tools/types"
+)
+
+const (
+    // SourceCollection 生成建议的源集合，与 Meilisearch 的群组索引同名
+    SourceCollection = search.DefaultChatsIndex
+    // SuggestionsIndex 搜索建议索引
+    SuggestionsIndex = "suggestions"
+    // StateIndex 记录每个源文档贡献的关键词，用于增量更新；另有一个 cursorID 文档记录增量更新的位置
+    StateIndex = "suggestion_sources"
+    // cursorID 状态索引中记录增量更新位置的文档主键，不会与会话ID冲突
+    cursorID = "_cursor"
+    // rebuildSuffix 全量重建时临时索引的后缀，完成后与正式索引交换
+    rebuildSuffix = "_rebuild"
+
+    // pageSize 每次从源集合读取的记录数
+    pageSize = 1000
+    // fetchBatchSize 按主键批量读取时每批的数量
+    fetchBatchSize = 100
+    // writeBatchSize 每次写入的文档数
+    writeBatchSize = 1000
+    // defaultMinFrequency 关键词至少出现在多少个群组中才作为建议返回
+    defaultMinFrequency = 2
+    // cursorOverlap 增量更新从上次位置之前这段时间开始读取，覆盖运行期间尚未提交的写入；重复读到的记录指纹相同会被跳过
+    cursorOverlap = time.Minute
+    // pocketBaseDateLayout PocketBase 日期字段的存储格式
+    pocketBaseDateLayout = "2006-01-02 15:04:05.000Z"
+)
+
+// suggestionsSettings 建议索引设置：前缀匹配的结果按关键词出现的群组数、热度排序
+var suggestionsSettings = search.Settings{
+    SearchableAttributes: []string{"query"},
+    FilterableAttributes: []string{"id", "visible"},
+    SortableAttributes:   []string{"frequency", "popularity"},
+    // Placed after typo so that exact prefix matches are ordered by how common the keyword is
+    RankingRules: []string{"words", "typo", "frequency:desc", "popularity:desc", "proximity", "attribute", "sort", "exactness"},
+}
+
+// stateSettings 状态索引设置，只需按主键查询
+var stateSettings = search.Settings{
+    SearchableAttributes: []string{"id"},
+    FilterableAttributes: []string{"id"},
+}
+
+// Config 构建器配置
+// @author fcj
+// @date 2023-11-15
+// @version 1.0.0
+type Config struct {
+    MeilisearchURL string
+    MeilisearchKey string
+    // Tokenizer 分词词典和停用词配置
+    Tokenizer tokenizer.Config
+    // MinFrequency 关键词至少出现在多少个群组中才作为建议返回，默认 2；
+    // 低于阈值的关键词仍然保留计数，只是不可见
+    MinFrequency int
+}
+
+// Suggestion 建议索引中的文档：Frequency 为包含该关键词的群组数，
+// Popularity 为这些群组的 log10(成员数+1) 之和，Visible 表示 Frequency 达到阈值
+// @author fcj
+// @date 2023-11-15
+// @version 1.0.0
+type Suggestion struct {
+    ID         string  `json:"id"`
+    Query      string  `json:"query"`
+    Frequency  int     `json:"frequency"`
+    Popularity float64 `json:"popularity"`
+    Visible    bool    `json:"visible"`
+}
+
+// Stats 一次运行的统计
+// @author fcj
+// @date 2023-11-15
+// @version 1.0.0
+type Stats struct {
+    // Rebuilt 是否执行了全量重建
+    Rebuilt bool
+    // Scanned 读取的源文档数，清理时为状态索引中的文档数
+    Scanned int
+    // Changed 新增或内容变化的源文档数，全量重建时为全部文档
+    Changed int
+    // Deleted 已从源集合删除的文档数，只有清理时统计
+    Deleted int
+    // Keywords 更新或删除的关键词数，全量重建时为关键词总数
+    Keywords int
+}
+
+// Builder 搜索建议构建器，同一实例的运行互斥
+// @author fcj
+// @date 2023-11-15
+// @version 1.0.0
+type Builder struct {
+    app          core.App
+    meili        *search.Client
+    tokenizer    *tokenizer.Tokenizer
+    minFrequency int
+    mu           sync.Mutex
+}
+
+// keywordDelta 关键词计数的变化量
+type keywordDelta struct {
+    frequency  int
+    popularity float64
+}
+
+// cursorState 状态索引中的增量更新位置：上次运行开始时的时间，此后保存的记录下次运行时读取
+type cursorState struct {
+    ID      string `json:"id"`
+    Updated string `json:"updated"`
+}
+
+// NewBuilder 创建构建器并加载分词词典和停用词
+// @author fcj
+// @date 2023-11-15
+// @version 1.0.0
+// @param app PocketBase 应用，从中读取 telegram_index
+// @param config 构建器配置
+// @return *Builder 构建器
+// @return error 词典或停用词加载失败时返回错误
+func NewBuilder(app core.App, config Config) (*Builder, error) {
+    tk, err := tokenizer.New(config.Tokenizer)
+    if err != nil {
+        return nil, err
+    }
+    minFrequency := config.MinFrequency
+    if minFrequency <= 0 {
+        minFrequency = defaultMinFrequency
+    }
+    return &Builder{
+        app: app,
+        meili: search.New(search.Config{
+            URL:       config.MeilisearchURL,
+            Key:       config.MeilisearchKey,
+            BatchSize: writeBatchSize,
+        }),
+        tokenizer:    tk,
+        minFrequency: minFrequency,
+    }, nil
+}
+
+// Update 增量更新：只读取上次运行后保存过的 telegram_index 记录，调整新增或变化的群组相关关键词的计数；
+// 已删除的群组由 Reconcile 处理。建议索引、状态索引或更新位置不存在时执行全量重建
+// @author fcj
+// @date 2023-11-15
+// @version 1.0.0
+// @return Stats 运行统计
+// @return error 错误信息
+func (b *Builder) Update() (Stats, error) {
+    b.mu.Lock()
+    defer b.mu.Unlock()
+
+    ctx := context.Background()
+    ready, err := b.indexesExist(ctx)
+    if err != nil {
+        return Stats{}, err
+    }
+    if !ready {
+        return b.rebuild(ctx)
+    }
+    var cursors []cursorState
+    if err := b.meili.FetchDocuments(ctx, StateIndex, []string{cursorID}, &cursors); err != nil {
+        return Stats{}, err
+    }
+    if len(cursors) == 0 {
+        log.Printf("INFO: Index %s has no update cursor, rebuilding suggestions", StateIndex)
+        return b.rebuild(ctx)
+    }
+
+    // 本次运行开始后保存的记录可能读到也可能读不到，下次从这里开始重新读取
+    next := types.NowDateTime().String()
+    var stats Stats
+    seen := make(map[string]struct{})
+    err = b.scanSources(scanStart(cursors[0].Updated), func(docs []sourceDocument) error {
+        stats.Scanned += len(docs)
+        states := make([]sourceState, 0, len(docs))
+        for _, doc := range docs {
+            if _, ok := seen[doc.ID]; ok {
+                continue
+            }
+            seen[doc.ID] = struct{}{}
+            states = append(states, newSourceState(b.tokenizer, doc))
+        }
+        changed, keywords, err := b.applyChanges(ctx, states, nil)
+        stats.Changed += changed
+        stats.Keywords += keywords
+        return err
+    })
+    if err != nil {
+        return stats, err
+    }
+    if err := b.meili.AddDocuments(ctx, StateIndex, []cursorState{{ID: cursorID, Updated: next}}); err != nil {
+        return stats, err
+    }
+    return stats, nil
+}
+
+// Reconcile 清理已删除的群组：逐页读取状态索引，减去 telegram_index 中已不存在的群组的贡献。
+// 删除很少发生，由单独的定时任务低频运行
+// @author fcj
+// @date 2023-11-15
+// @version 1.0.0
+// @return Stats 运行统计
+// @return error 错误信息
+func (b *Builder) Reconcile() (Stats, error) {
+    b.mu.Lock()
+    defer b.mu.Unlock()
+
+    ctx := context.Background()
+    ready, err := b.indexesExist(ctx)
+    if err != nil {
+        return Stats{}, err
+    }
+    if !ready {
+        return b.rebuild(ctx)
+    }
+
+    // 状态索引只由持有锁的构建器写入，读取期间按偏移分页不会漏读；删除在读完后进行
+    var stats Stats
+    var deleted []string
+    for offset := 0; ; offset += pageSize {
+        var states []sourceState
+        if err := b.meili.GetDocuments(ctx, StateIndex, offset, pageSize, []string{"id"}, &states); err != nil {
+            return stats, err
+        }
+        if len(states) == 0 {
+            break
+        }
+        ids := make([]string, 0, len(states))
+        for _, state := range states {
+            if state.ID != cursorID {
+                ids = append(ids, state.ID)
+            }
+        }
+        stats.Scanned += len(ids)
+        missing, err := b.missingSources(ids)
+        if err != nil {
+            return stats, err
+        }
+        deleted = append(deleted, missing...)
+    }
+
+    sort.Strings(deleted)
+    for start := 0; start < len(deleted); start += writeBatchSize {
+        end := min(start+writeBatchSize, len(deleted))
+        _, keywords, err := b.applyChanges(ctx, nil, deleted[start:end])
+        if err != nil {
+            return stats, err
+        }
+        stats.Deleted += end - start
+        stats.Keywords += keywords
+    }
+    return stats, nil
+}
+
+// Rebuild 全量重建：把全部源文档写入临时索引，完成后与正式索引原子交换，重建期间建议照常可用
+// @author fcj
+// @date 2023-11-15
+// @version 1.0.0
+// @return Stats 运行统计
+// @return error 错误信息
+func (b *Builder) Rebuild() (Stats, error) {
+    b.mu.Lock()
+    defer b.mu.Unlock()
+    return b.rebuild(context.Background())
+}
+
+// rebuild 执行全量重建，调用方需持有锁
+func (b *Builder) rebuild(ctx context.Context) (Stats, error) {
+    stats := Stats{Rebuilt: true}
+    tmpSuggestions := SuggestionsIndex + rebuildSuffix
+    tmpState := StateIndex + rebuildSuffix
+    for _, uid := range []string{tmpSuggestions, tmpState} {
+        if err := b.meili.DeleteIndex(ctx, uid); err != nil {
+            return stats, err
+        }
+    }
+    if err := b.meili.CreateIndex(ctx, tmpSuggestions, suggestionsSettings); err != nil {
+        return stats, err
+    }
+    if err := b.meili.CreateIndex(ctx, tmpState, stateSettings); err != nil {
+        return stats, err
+    }
+
+    // 源记录逐页处理，内存中只保留关键词计数；重建期间保存的记录由下一次增量更新读取
+    next := types.NowDateTime().String()
+    counts := make(map[string]*keywordDelta)
+    seen := make(map[string]struct{})
+    err := b.scanSources("", func(docs []sourceDocument) error {
+        stats.Scanned += len(docs)
+        states := make([]sourceState, 0, len(docs))
+        for _, doc := range docs {
+            if _, ok := seen[doc.ID]; ok {
+                continue
+            }
+            seen[doc.ID] = struct{}{}
+            state := newSourceState(b.tokenizer, doc)
+            addContribution(counts, nil, &state)
+            states = append(states, state)
+        }
+        stats.Changed += len(states)
+        if len(states) == 0 {
+            return nil
+        }
+        return b.meili.AddDocuments(ctx, tmpState, states)
+    })
+    if err != nil {
+        return stats, err
+    }
+    if err := b.meili.AddDocuments(ctx, tmpState, []cursorState{{ID: cursorID, Updated: next}}); err != nil {
+        return stats, err
+    }
+
+    suggestions := make([]Suggestion, 0, len(counts))
+    for keyword, delta := range counts {
+        if suggestion, ok := mergeSuggestion(nil, keyword, delta, b.minFrequency); ok {
+            suggestions = append(suggestions, *suggestion)
+        }
+    }
+    sort.Slice(suggestions, func(i, j int) bool { return suggestions[i].Query < suggestions[j].Query })
+    if err := b.meili.AddDocuments(ctx, tmpSuggestions, suggestions); err != nil {
+        return stats, err
+    }
+    stats.Keywords = len(suggestions)
+
+    // 交换要求两个索引都存在
+    for uid, settings := range map[string]search.Settings{SuggestionsIndex: suggestionsSettings, StateIndex: stateSettings} {
+        exists, err := b.meili.IndexExists(ctx, uid)
+        if err != nil {
+            return stats, fmt.Errorf("failed to check index %s: %w", uid, err)
+        }
+        if !exists {
+            if err := b.meili.CreateIndex(ctx, uid, settings); err != nil {
+                return stats, err
+            }
+        }
+    }
+    if err := b.meili.SwapIndexes(ctx, [2]string{SuggestionsIndex, tmpSuggestions}, [2]string{StateIndex, tmpState}); err != nil {
+        return stats, err
+    }
+    // 交换后临时索引中是旧数据
+    for _, uid := range []string{tmpSuggestions, tmpState} {
+        if err := b.meili.DeleteIndex(ctx, uid); err != nil {
+            log.Printf("WARN: Failed to delete old index %s: %v", uid, err)
+        }
+    }
+    return stats, nil
+}
+
+// indexesExist 检查建议索引和状态索引是否都存在
+func (b *Builder) indexesExist(ctx context.Context) (bool, error) {
+    for _, uid := range []string{SuggestionsIndex, StateIndex} {
+        exists, err := b.meili.IndexExists(ctx, uid)
+        if err != nil {
+            return false, fmt.Errorf("failed to check index %s: %w", uid, err)
+        }
+        if !exists {
+            log.Printf("INFO: Index %s does not exist, rebuilding suggestions", uid)
+            return false, nil
+        }
+    }
+    return true, nil
+}
+
+// scanSources 按 (updated, id) 顺序逐页读取 updated 不早于 since 的 telegram_index 记录，since 为空时读取全部；
+// 按上一页最后一条记录的位置分页，读取期间有记录写入也不会漏读或重复读取之前的记录
+func (b *Builder) scanSources(since string, fn func(docs []sourceDocument) error) error {
+    lastUpdated, lastID := since, ""
+    for {
+        var docs []sourceDocument
+        err := b.app.DB().Select(sourceColumns...).
+            From(SourceCollection).
+            Where(dbx.NewExp("chat_id != '' AND (updated > {:updated} OR (updated = {:updated} AND id > {:id}))",
+                dbx.Params{"updated": lastUpdated, "id": lastID})).
+            OrderBy("updated", "id").
+            Limit(pageSize).
+            All(&docs)
+        if err != nil {
+            return fmt.Errorf("failed to list %s: %w", SourceCollection, err)
+        }
+        if len(docs) == 0 {
+            return nil
+        }
+        if err := fn(docs); err != nil {
+            return err
+        }
+        last := docs[len(docs)-1]
+        lastUpdated, lastID = last.Updated, last.RecordID
+    }
+}
+
+// missingSources 返回 ids 中在 telegram_index 里已没有记录的会话ID
+func (b *Builder) missingSources(ids []string) ([]string, error) {
+    if len(ids) == 0 {
+        return nil, nil
+    }
+    values := make([]interface{}, 0, len(ids))
+    for _, id := range ids {
+        values = append(values, id)
+    }
+    var existing []string
+    err := b.app.DB().Select("chat_id").
+        From(SourceCollection).
+        Where(dbx.In("chat_id", values...)).
+        Column(&existing)
+    if err != nil {
+        return nil, fmt.Errorf("failed to look up %s: %w", SourceCollection, err)
+    }
+    found := make(map[string]struct{}, len(existing))
+    for _, id := range existing {
+        found[id] = struct{}{}
+    }
+    var missing []string
+    for _, id := range ids {
+        if _, ok := found[id]; !ok {
+            missing = append(missing, id)
+        }
+    }
+    return missing, nil
+}
+
+// scanStart 增量更新的起始位置：上次记录的位置往前 cursorOverlap，无法解析时从头读取
+func scanStart(cursor string) string {
+    t, err := time.Parse(pocketBaseDateLayout, cursor)
+    if err != nil {
+        return ""
+    }
+    return t.Add(-cursorOverlap).Format(pocketBaseDateLayout)
+}
+
+// applyChanges 跳过指纹未变化的文档，撤销变化和删除文档的旧贡献、加上新贡献，更新受影响的关键词，最后写入状态索引
+// 先更新建议再写状态：中途失败时重新运行全量重建即可恢复一致
+// @return int 新增或变化的文档数
+// @return int 更新或删除的关键词数
+func (b *Builder) applyChanges(ctx context.Context, states []sourceState, deletedIDs []string) (int, int, error) {
+    if len(states) == 0 && len(deletedIDs) == 0 {
+        return 0, 0, nil
+    }
+    ids := make([]string, 0, len(states)+len(deletedIDs))
+    for _, state := range states {
+        ids = append(ids, state.ID)
+    }
+    ids = append(ids, deletedIDs...)
+    previous := make(map[string]*sourceState, len(ids))
+    for start := 0; start < len(ids); start += fetchBatchSize {
+        end := min(start+fetchBatchSize, len(ids))
+        var fetched []sourceState
+        if err := b.meili.FetchDocuments(ctx, StateIndex, ids[start:end], &fetched); err != nil {
+            return 0, 0, err
+        }
+        for i := range fetched {
+            previous[fetched[i].ID] = &fetched[i]
+        }
+    }
+
+    changed := changedStates(states, previous)
+    if len(changed) == 0 && len(deletedIDs) == 0 {
+        return 0, 0, nil
+    }
+    deltas := make(map[string]*keywordDelta)
+    for i := range changed {
+        addContribution(deltas, previous[changed[i].ID], &changed[i])
+    }
+    for _, id := range deletedIDs {
+        addContribution(deltas, previous[id], nil)
+    }
+    if err := b.applyDeltas(ctx, deltas); err != nil {
+        return 0, 0, err
+    }
+
+    if len(changed) > 0 {
+        if err := b.meili.AddDocuments(ctx, StateIndex, changed); err != nil {
+            return 0, 0, err
+        }
+    }
+    if len(deletedIDs) > 0 {
+        if err := b.meili.DeleteDocuments(ctx, StateIndex, deletedIDs); err != nil {
+            return 0, 0, err
+        }
+    }
+    return len(changed), len(deltas), nil
+}
+
+// changedStates 返回状态索引中没有或指纹不同的文档
+func changedStates(states []sourceState, previous map[string]*sourceState) []sourceState {
+    var changed []sourceState
+    for _, state := range states {
+        if p, ok := previous[state.ID]; ok && p.Fingerprint == state.Fingerprint {
+            continue
+        }
+        changed = append(changed, state)
+    }
+    return changed
+}
+
+// applyDeltas 读取受影响关键词的当前计数，加上变化量后写回，计数归零的关键词删除
+func (b *Builder) applyDeltas(ctx context.Context, deltas map[string]*keywordDelta) error {
+    keywords := make([]string, 0, len(deltas))
+    for keyword := range deltas {
+        keywords = append(keywords, keyword)
+    }
+    sort.Strings(keywords)
+
+    for start := 0; start < len(keywords); start += fetchBatchSize {
+        batch := keywords[start:min(start+fetchBatchSize, len(keywords))]
+        ids := make([]string, 0, len(batch))
+        for _, keyword := range batch {
+            ids = append(ids, suggestionID(keyword))
+        }
+        var current []Suggestion
+        if err := b.meili.FetchDocuments(ctx, SuggestionsIndex, ids, &current); err != nil {
+            return err
+        }
+        byID := make(map[string]*Suggestion, len(current))
+        for i := range current {
+            byID[current[i].ID] = &current[i]
+        }
+
+        var upserts []Suggestion
+        var removals []string
+        for _, keyword := range batch {
+            id := suggestionID(keyword)
+            if suggestion, ok := mergeSuggestion(byID[id], keyword, deltas[keyword], b.minFrequency); ok {
+                upserts = append(upserts, *suggestion)
+            } else if byID[id] != nil {
+                removals = append(removals, id)
+            }
+        }
+        if len(upserts) > 0 {
+            if err := b.meili.AddDocuments(ctx, SuggestionsIndex, upserts); err != nil {
+                return err
+            }
+        }
+        if len(removals) > 0 {
+            if err := b.meili.DeleteDocuments(ctx, SuggestionsIndex, removals); err != nil {
+                return err
+            }
+        }
+    }
+    return nil
+}
+
+// addContribution 从 deltas 中减去 previous 的贡献并加上 current 的贡献，任一方可为 nil
+func addContribution(deltas map[string]*keywordDelta, previous, current *sourceState) {
+    apply := func(state *sourceState, sign int) {
+        if state == nil {
+            return
+        }
+        for _, keyword := range state.Keywords {
+            delta, ok := deltas[keyword]
+            if !ok {
+                delta = &keywordDelta{}
+                deltas[keyword] = delta
+            }
+            delta.frequency += sign
+            delta.popularity += float64(sign) * state.Weight
+        }
+    }
+    apply(previous, -1)
+    apply(current, 1)
+}
+
+// mergeSuggestion 在当前计数（可为 nil）上加变化量并按阈值设置是否可见，计数不为正时返回 false 表示应删除
+func mergeSuggestion(current *Suggestion, keyword string, delta *keywordDelta, minFrequency int) (*Suggestion, bool) {
+    merged := Suggestion{ID: suggestionID(keyword), Query: keyword}
+    if current != nil {
+        merged.Frequency = current.Frequency
+        merged.Popularity = current.Popularity
+    }
+    merged.Frequency += delta.frequency
+    merged.Popularity = math.Max(math.Round((merged.Popularity+delta.popularity)*100)/100, 0)
+    if merged.Frequency <= 0 {
+        return nil, false
+    }
+    merged.Visible = merged.Frequency >= minFrequency
+    return &merged, true
+}
+
+/*
+ * 关键算法说明：
+ * 1. 状态：suggestion_sources 索引为每个源文档保存关键词、权重和指纹，另保存上次增量更新开始的时间（_cursor 文档）
+ * 2. 增量：按 PocketBase 自动维护的 updated 字段只读取上次开始时间（往前 1 分钟）之后保存的记录，按 (updated, id) 分页；
+ *    读到的文档按主键取出旧状态，指纹相同的跳过，变化的先减去旧贡献再加上新贡献；
+ *    受影响的关键词按主键读出当前计数，加上变化量后写回，计数归零则删除
+ * 3. 全量重建：写入 suggestions_rebuild 和 suggestion_sources_rebuild，通过 swap-indexes 一次交换两对索引，
+ *    建议索引在重建期间始终可用
+ * 4. 阈值：所有关键词都保留计数以便增量更新，出现的群组数低于 MinFrequency 的标记为不可见，搜索建议只返回可见的关键词；
+ *    修改分词配置或阈值后需要全量重建，已有关键词才会按新配置重新计算
+ * 5. 清理：增量更新不处理删除；Reconcile 逐页读取状态索引，按会话ID批量查询 telegram_index，只减去确实不存在的群组的贡献
+ *
+ * 待优化事项：
+ * 1. 同一会话ID有多条 telegram_index 记录时只取先读到的一条，增量更新和全量重建可能取到不同的记录
+ */
@@ -0,0 +1,92 @@
+package suggest
+
+import (
+    "testing"
+
+    "management-service/suggest/tokenizer"
+)
+
+func TestAddContributionReplacesPreviousKeywords(t *testing.T) {
+    deltas := make(map[string]*keywordDelta)
+    previous := &sourceState{Keywords: []string{"crypto", "news"}, Weight: 3}
+    current := &sourceState{Keywords: []string{"crypto", "signals"}, Weight: 4}
+    addContribution(deltas, previous, current)
+
+    if d := deltas["crypto"]; d.frequency != 0 || d.popularity != 1 {
+        t.Errorf("crypto delta = %+v, want frequency 0, popularity 1", *d)
+    }
+    if d := deltas["news"]; d.frequency != -1 || d.popularity != -3 {
+        t.Errorf("news delta = %+v, want frequency -1, popularity -3", *d)
+    }
+    if d := deltas["signals"]; d.frequency != 1 || d.popularity != 4 {
+        t.Errorf("signals delta = %+v, want frequency 1, popularity 4", *d)
+    }
+}
+
+func TestMergeSuggestion(t *testing.T) {
+    current := &Suggestion{ID: suggestionID("news"), Query: "news", Frequency: 2, Popularity: 5.5}
+
+    merged, ok := mergeSuggestion(current, "news", &keywordDelta{frequency: -1, popularity: -3}, 1)
+    if !ok || merged.Frequency != 1 || merged.Popularity != 2.5 || !merged.Visible {
+        t.Errorf("merged = %+v, %v; want frequency 1, popularity 2.5, visible", merged, ok)
+    }
+    if _, ok := mergeSuggestion(current, "news", &keywordDelta{frequency: -2, popularity: -5.5}, 1); ok {
+        t.Error("keyword without documents should be deleted")
+    }
+    if merged, ok := mergeSuggestion(nil, "signals", &keywordDelta{frequency: 1, popularity: 4}, 1); !ok || merged.ID != suggestionID("signals") {
+        t.Errorf("new keyword = %+v, %v", merged, ok)
+    }
+}
+
+func TestMergeSuggestionMinFrequency(t *testing.T) {
+    merged, ok := mergeSuggestion(nil, "rare", &keywordDelta{frequency: 1, popularity: 2}, 2)
+    if !ok || merged.Visible {
+        t.Errorf("merged = %+v, %v; want kept but hidden below the threshold", merged, ok)
+    }
+    merged, ok = mergeSuggestion(merged, "rare", &keywordDelta{frequency: 1, popularity: 2}, 2)
+    if !ok || !merged.Visible {
+        t.Errorf("merged = %+v, %v; want visible once the threshold is reached", merged, ok)
+    }
+}
+
+func TestSourceStateFingerprintIgnoresSmallMemberChanges(t *testing.T) {
+    b, err := NewBuilder(nil, Config{Tokenizer: tokenizer.Config{DictPath: "tokenizer/testdata/dict.txt"}})
+    if err != nil {
+        t.Fatal(err)
+    }
+    doc := sourceDocument{ID: "-100", Title: "Crypto News", MembersCount: 1000}
+    before := newSourceState(b.tokenizer, doc)
+    doc.MembersCount = 1010
+    if after := newSourceState(b.tokenizer, doc); after.Fingerprint != before.Fingerprint {
+        t.Error("fingerprint changed for a 1% member change")
+    }
+    doc.Title = "Crypto Signals"
+    if after := newSourceState(b.tokenizer, doc); after.Fingerprint == before.Fingerprint {
+        t.Error("fingerprint unchanged after a title change")
+    }
+}
+
+func TestScanStartOverlapsCursor(t *testing.T) {
+    if got := scanStart("2024-03-10 08:00:30.500Z"); got != "2024-03-10 07:59:30.500Z" {
+        t.Errorf("scanStart = %q, want one minute earlier", got)
+    }
+    if got := scanStart(""); got != "" {
+        t.Errorf("scanStart of an empty cursor = %q, want a full scan", got)
+    }
+}
+
+func TestChangedStatesSkipsSameFingerprint(t *testing.T) {
+    previous := map[string]*sourceState{
+        "-100": {ID: "-100", Fingerprint: "a"},
+        "-200": {ID: "-200", Fingerprint: "b"},
+    }
+    states := []sourceState{
+        {ID: "-100", Fingerprint: "a"},
+        {ID: "-200", Fingerprint: "c"},
+        {ID: "-300", Fingerprint: "d"},
+    }
+    changed := changedStates(states, previous)
+    if len(changed) != 2 || changed[0].ID != "-200" || changed[1].ID != "-300" {
+        t.Errorf("changed = %+v, want -200 and -300", changed)
+    }
+}
@@ -0,0 +1,80 @@
+/*
+ * 文件功能描述：从 telegram_index 文档中提取搜索建议关键词，分词和过滤见 tokenizer 包
+ * 主要类/接口说明：sourceDocument 源文档、sourceState 每个文档贡献的关键词及权重
+ * 修改历史记录：
+ * @author fcj
+ * @date 2023-11-15
+ * @version 1.0.0
+ * © Telegram Bot Services Team
+ */
+
+package suggest
+
+import (
+    "crypto/sha1"
+    "encoding/hex"
+    "math"
+    "strconv"
+
+    "management-service/suggest/tokenizer"
+)
+
+// sourceColumns 从 telegram_index 读取的字段；用户名多为拼接的英文片段，不参与生成建议
+var sourceColumns = []string{"id", "chat_id", "title", "description", "members_count", "updated"}
+
+// sourceDocument telegram_index 记录中参与生成建议的字段
+type sourceDocument struct {
+    // RecordID PocketBase 记录ID，分页时与 Updated 一起作为位置
+    RecordID string `db:"id"`
+    // ID Bot API 格式的会话ID，也是 Meilisearch 文档ID
+    ID           string  `db:"chat_id"`
+    Title        string  `db:"title"`
+    Description  string  `db:"description"`
+    MembersCount float64 `db:"members_count"`
+    // Updated PocketBase 每次保存时写入的时间
+    Updated string `db:"updated"`
+}
+
+// sourceState 一个源文档对建议的贡献，保存在状态索引中，用于增量更新时撤销旧的贡献
+type sourceState struct {
+    ID string `json:"id"`
+    // Fingerprint 关键词和权重的摘要，未变化的文档跳过
+    Fingerprint string   `json:"fingerprint"`
+    Keywords    []string `json:"keywords"`
+    Weight      float64  `json:"weight"`
+}
+
+// extractKeywords 对标题和简介分词，返回去重并排序后的小写关键词
+func extractKeywords(tk *tokenizer.Tokenizer, doc sourceDocument) []string {
+    return tk.Keywords(doc.Title + " " + doc.Description)
+}
+
+// documentWeight 文档对关键词热度的贡献：log10(成员数+1)，保留一位小数，
+// 成员数小幅波动不会改变权重，也就不会触发重新计算
+func documentWeight(membersCount float64) float64 {
+    return math.Round(math.Log10(math.Max(membersCount, 0)+1)*10) / 10
+}
+
+// newSourceState 计算源文档的关键词、权重和指纹
+func newSourceState(tk *tokenizer.Tokenizer, doc sourceDocument) sourceState {
+    state := sourceState{
+        ID:       doc.ID,
+        Keywords: extractKeywords(tk, doc),
+        Weight:   documentWeight(doc.MembersCount),
+    }
+    h := sha1.New()
+    h.Write([]byte(strconv.FormatFloat(state.Weight, 'f', 1, 64)))
+    for _, k := range state.Keywords {
+        h.Write([]byte{0})
+        h.Write([]byte(k))
+    }
+    state.Fingerprint = hex.EncodeToString(h.Sum(nil))
+    return state
+}
+
+// suggestionID 关键词在建议索引中的主键
+func suggestionID(keyword string) string {
+    h := sha1.New()
+    h.Write([]byte(keyword))
+    return hex.EncodeToString(h.Sum(nil))
+}
@@ -0,0 +1,229 @@
+/*
+ * 文件功能描述：搜索建议分词器，基于 jiebago 分词并过滤停用词、链接、数字、用户名等无意义的词
+ * 主要类/接口说明：Config 分词配置、Tokenizer 分词器
+ * 修改历史记录：
+ * @author fcj
+ * @date 2023-11-15
+ * @version 1.0.0
+ * © Telegram Bot Services Team
+ */
+
+package tokenizer
+
+import (
+    "bufio"
+    "embed"
+    "fmt"
+    "io"
+    "os"
+    "regexp"
+    "sort"
+    "strings"
+    "unicode"
+    "unicode/utf8"
+
+    "github.com/wangbin/jiebago"
+)
+
+const (
+    // defaultMinRunes 关键词最少字符数
+    defaultMinRunes = 2
+    // defaultMaxRunes 关键词最多字符数，更长的多为哈希、邀请码等
+    defaultMaxRunes = 24
+)
+
+//go:embed stopwords/*.txt
+var builtinStopwords embed.FS
+
+var (
+    // urlPattern 链接，包括 t.me 邀请链接和不带协议的域名
+    urlPattern = regexp.MustCompile(`(?i)\b(?:https?://|www\.|t\.me/|telegram\.me/)\S*|\b[\w-]+(?:\.[\w-]+)*\.(?:com|org|net|io|me|cn|ru|xyz|top)(?:/\S*)?\b`)
+    // mentionPattern @用户名
+    mentionPattern = regexp.MustCompile(`@\w+`)
+)
+
+// Config 分词配置
+// @author fcj
+// @date 2023-11-15
+// @version 1.0.0
+type Config struct {
+    // DictPath jiebago 主词典路径
+    DictPath string
+    // UserDictPaths 用户词典路径，用于收录 Telegram 圈内用语，使其不被拆开
+    UserDictPaths []string
+    // StopwordPaths 额外的停用词文件，每行一个，# 开头为注释；内置中英文停用词始终生效
+    StopwordPaths []string
+    // MinRunes 关键词最少字符数，默认 2
+    MinRunes int
+    // MaxRunes 关键词最多字符数，默认 24
+    MaxRunes int
+}
+
+// Tokenizer 分词器，创建后可并发使用
+// @author fcj
+// @date 2023-11-15
+// @version 1.0.0
+type Tokenizer struct {
+    seg       jiebago.Segmenter
+    stopwords map[string]struct{}
+    minRunes  int
+    maxRunes  int
+}
+
+// New 加载词典和停用词，创建分词器
+// @author fcj
+// @date 2023-11-15
+// @version 1.0.0
+// @param config 分词配置
+// @return *Tokenizer 分词器
+// @return error 词典或停用词文件无法读取时返回错误
+func New(config Config) (*Tokenizer, error) {
+    t := &Tokenizer{
+        stopwords: make(map[string]struct{}),
+        minRunes:  config.MinRunes,
+        maxRunes:  config.MaxRunes,
+    }
+    if t.minRunes <= 0 {
+        t.minRunes = defaultMinRunes
+    }
+    if t.maxRunes <= 0 {
+        t.maxRunes = defaultMaxRunes
+    }
+
+    // jiebago 不检查文件是否存在，先确认路径有效
+    if _, err := os.Stat(config.DictPath); err != nil {
+        return nil, fmt.Errorf("failed to open dictionary: %w", err)
+    }
+    if err := t.seg.LoadDictionary(config.DictPath); err != nil {
+        return nil, fmt.Errorf("failed to load dictionary %s: %w", config.DictPath, err)
+    }
+    for _, path := range config.UserDictPaths {
+        if _, err := os.Stat(path); err != nil {
+            return nil, fmt.Errorf("failed to open user dictionary: %w", err)
+        }
+        if err := t.seg.LoadUserDictionary(path); err != nil {
+            return nil, fmt.Errorf("failed to load user dictionary %s: %w", path, err)
+        }
+    }
+
+    entries, err := builtinStopwords.ReadDir("stopwords")
+    if err != nil {
+        return nil, fmt.Errorf("failed to read builtin stopwords: %w", err)
+    }
+    for _, entry := range entries {
+        f, err := builtinStopwords.Open("stopwords/" + entry.Name())
+        if err != nil {
+            return nil, fmt.Errorf("failed to open builtin stopwords %s: %w", entry.Name(), err)
+        }
+        err = t.addStopwords(f)
+        f.Close()
+        if err != nil {
+            return nil, fmt.Errorf("failed to read builtin stopwords %s: %w", entry.Name(), err)
+        }
+    }
+    for _, path := range config.StopwordPaths {
+        f, err := os.Open(path)
+        if err != nil {
+            return nil, fmt.Errorf("failed to open stopwords: %w", err)
+        }
+        err = t.addStopwords(f)
+        f.Close()
+        if err != nil {
+            return nil, fmt.Errorf("failed to read stopwords %s: %w", path, err)
+        }
+    }
+    return t, nil
+}
+
+// DictionaryEntries 统计词典的词条数（非空行），用于在启动时发现误用的精简词典：
+// 词条过少时 jieba 会把中文切成单字，单字又会被 MinRunes 过滤，几乎提取不到中文关键词
+// @author fcj
+// @date 2023-11-15
+// @version 1.0.0
+// @param path 词典路径
+// @return int 词条数
+// @return error 词典无法读取时返回错误
+func DictionaryEntries(path string) (int, error) {
+    f, err := os.Open(path)
+    if err != nil {
+        return 0, fmt.Errorf("failed to open dictionary: %w", err)
+    }
+    defer f.Close()
+    n := 0
+    scanner := bufio.NewScanner(f)
+    for scanner.Scan() {
+        if strings.TrimSpace(scanner.Text()) != "" {
+            n++
+        }
+    }
+    if err := scanner.Err(); err != nil {
+        return 0, fmt.Errorf("failed to read dictionary %s: %w", path, err)
+    }
+    return n, nil
+}
+
+// addStopwords 读取停用词，每行一个，忽略空行和 # 开头的注释
+func (t *Tokenizer) addStopwords(r io.Reader) error {
+    scanner := bufio.NewScanner(r)
+    for scanner.Scan() {
+        word := strings.ToLower(strings.TrimSpace(scanner.Text()))
+        if word == "" || strings.HasPrefix(word, "#") {
+            continue
+        }
+        t.stopwords[word] = struct{}{}
+    }
+    return scanner.Err()
+}
+
+// Keywords 提取文本中的关键词：去掉链接和 @用户名后分词，过滤停用词、数字、符号和长度不合适的词，
+// 返回去重并排序后的小写关键词
+// @author fcj
+// @date 2023-11-15
+// @version 1.0.0
+// @param text 文本
+// @return []string 关键词
+func (t *Tokenizer) Keywords(text string) []string {
+    text = urlPattern.ReplaceAllString(text, " ")
+    text = mentionPattern.ReplaceAllString(text, " ")
+
+    seen := make(map[string]struct{})
+    keywords := make([]string, 0)
+    for word := range t.seg.CutForSearch(text, true) {
+        word = strings.ToLower(strings.TrimSpace(word))
+        if _, ok := seen[word]; ok || !t.keep(word) {
+            continue
+        }
+        seen[word] = struct{}{}
+        keywords = append(keywords, word)
+    }
+    sort.Strings(keywords)
+    return keywords
+}
+
+// keep 判断分词结果是否可以作为关键词
+func (t *Tokenizer) keep(word string) bool {
+    n := utf8.RuneCountInString(word)
+    if n < t.minRunes || n > t.maxRunes {
+        return false
+    }
+    if _, ok := t.stopwords[word]; ok {
+        return false
+    }
+    // 至少包含一个字母或汉字，去掉纯数字、表情和符号；
+    // 字母与数字混合的只保留字母开头的（如 web3、gpt4），去掉 2024年、100u 之类
+    hasLetter := false
+    for _, r := range word {
+        switch {
+        case unicode.IsLetter(r):
+            hasLetter = true
+        case unicode.IsDigit(r), r == '_', r == '-', r == '.':
+        default:
+            return false
+        }
+    }
+    if !hasLetter {
+        return false
+    }
+    first, _ := utf8.DecodeRuneInString(word)
+    return unicode.IsLetter(first)
+}
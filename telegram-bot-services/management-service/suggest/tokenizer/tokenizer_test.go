@@ -0,0 +1,81 @@
+package tokenizer
+
+import (
+    "encoding/json"
+    "os"
+    "reflect"
+    "testing"
+)
+
+func newTestTokenizer(t *testing.T, userDicts ...string) *Tokenizer {
+    t.Helper()
+    tk, err := New(Config{
+        DictPath:      "testdata/dict.txt",
+        UserDictPaths: userDicts,
+        StopwordPaths: []string{"testdata/stopwords.txt"},
+    })
+    if err != nil {
+        t.Fatal(err)
+    }
+    return tk
+}
+
+func TestKeywordsCorpus(t *testing.T) {
+    data, err := os.ReadFile("testdata/corpus.json")
+    if err != nil {
+        t.Fatal(err)
+    }
+    var corpus []struct {
+        Name     string   `json:"name"`
+        Text     string   `json:"text"`
+        Keywords []string `json:"keywords"`
+    }
+    if err := json.Unmarshal(data, &corpus); err != nil {
+        t.Fatal(err)
+    }
+
+    tk := newTestTokenizer(t, "testdata/userdict.txt")
+    for _, c := range corpus {
+        t.Run(c.Name, func(t *testing.T) {
+            if got := tk.Keywords(c.Text); !reflect.DeepEqual(got, c.Keywords) {
+                t.Errorf("Keywords(%q) = %q, want %q", c.Text, got, c.Keywords)
+            }
+        })
+    }
+}
+
+func TestUserDictionaryKeepsSlangTogether(t *testing.T) {
+    without := newTestTokenizer(t).Keywords("撸毛")
+    with := newTestTokenizer(t, "testdata/userdict.txt").Keywords("撸毛")
+    if reflect.DeepEqual(without, with) {
+        t.Fatalf("user dictionary had no effect: %q", with)
+    }
+    if !reflect.DeepEqual(with, []string{"撸毛"}) {
+        t.Errorf("Keywords = %q, want [撸毛]", with)
+    }
+}
+
+func TestNewRejectsMissingFiles(t *testing.T) {
+    if _, err := New(Config{DictPath: "testdata/missing.txt"}); err == nil {
+        t.Error("expected an error for a missing dictionary")
+    }
+    if _, err := New(Config{DictPath: "testdata/dict.txt", UserDictPaths: []string{"testdata/missing.txt"}}); err == nil {
+        t.Error("expected an error for a missing user dictionary")
+    }
+    if _, err := New(Config{DictPath: "testdata/dict.txt", StopwordPaths: []string{"testdata/missing.txt"}}); err == nil {
+        t.Error("expected an error for a missing stopword file")
+    }
+}
+
+func TestDictionaryEntries(t *testing.T) {
+    n, err := DictionaryEntries("testdata/dict.txt")
+    if err != nil {
+        t.Fatal(err)
+    }
+    if n != 8 {
+        t.Errorf("DictionaryEntries = %d, want 8", n)
+    }
+    if _, err := DictionaryEntries("testdata/missing.txt"); err == nil {
+        t.Error("expected an error for a missing dictionary")
+    }
+}
@@ -0,0 +1,3 @@
+module telegram-bot-services/pkg
+
+go 1.23.0
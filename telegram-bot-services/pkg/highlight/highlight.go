@@ -0,0 +1,100 @@
+// Package highlight asks Meilisearch for highlighted, cropped attributes and
+// renders them safely. Meilisearch marks matches with private-use characters
+// instead of HTML tags, so hit text is escaped first and the markers become
+// tags afterwards; text from a chat can never inject markup.
+package highlight
+
+import (
+	"html"
+	"strings"
+)
+
+const (
+	// preTag and postTag surround matches in _formatted. They are Unicode
+	// private-use characters, which html.EscapeString leaves alone.
+	preTag  = "\uE000"
+	postTag = "\uE001"
+	// cropMarker replaces the text cut off by a crop.
+	cropMarker = "…"
+	// DefaultCropLength is the crop window in words, centred on the first match.
+	DefaultCropLength = 24
+)
+
+// Fields lists the attributes of an index to highlight and to crop.
+type Fields struct {
+	Highlight []string
+	Crop      []string
+}
+
+// ChatFields are the telegram_index attributes.
+var ChatFields = Fields{
+	Highlight: []string{"title", "description"},
+	Crop:      []string{"description"},
+}
+
+// MessageFields are the attributes of the messages index.
+var MessageFields = Fields{
+	Highlight: []string{"text", "title"},
+	Crop:      []string{"text"},
+}
+
+// Params returns the Meilisearch search parameters for f. Matches are returned
+// in each hit's _formatted and their offsets in _matchesPosition.
+func (f Fields) Params(cropLength int) map[string]interface{} {
+	if cropLength <= 0 {
+		cropLength = DefaultCropLength
+	}
+	return map[string]interface{}{
+		"attributesToHighlight": f.Highlight,
+		"attributesToCrop":      f.Crop,
+		"cropLength":            cropLength,
+		"cropMarker":            cropMarker,
+		"highlightPreTag":       preTag,
+		"highlightPostTag":      postTag,
+		"showMatchesPosition":   true,
+	}
+}
+
+// Formatted returns the highlighted value of attribute from a hit, or "" when
+// the hit has no string value for it.
+func Formatted(hit map[string]interface{}, attribute string) string {
+	formatted, _ := hit["_formatted"].(map[string]interface{})
+	value, _ := formatted[attribute].(string)
+	return value
+}
+
+// HTML escapes a highlighted value and wraps each match in open and close,
+// e.g. "<b>" and "</b>". Tags are always balanced, even if the text itself
+// contains marker characters.
+func HTML(value, open, close string) string {
+	var b strings.Builder
+	inside := false
+	for value != "" {
+		i := strings.IndexAny(value, preTag+postTag)
+		if i < 0 {
+			b.WriteString(html.EscapeString(value))
+			break
+		}
+		b.WriteString(html.EscapeString(value[:i]))
+		// Both markers are three bytes long in UTF-8.
+		marker := value[i : i+len(preTag)]
+		value = value[i+len(preTag):]
+		switch {
+		case marker == preTag && !inside:
+			b.WriteString(open)
+			inside = true
+		case marker == postTag && inside:
+			b.WriteString(close)
+			inside = false
+		}
+	}
+	if inside {
+		b.WriteString(close)
+	}
+	return b.String()
+}
+
+// Text removes the match markers, leaving the cropped plain text.
+func Text(value string) string {
+	return strings.NewReplacer(preTag, "", postTag, "").Replace(value)
+}
@@ -0,0 +1,36 @@
+package highlight
+
+import "testing"
+
+func TestHTML(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+		want  string
+	}{
+		{"escapes text around matches", "…a <b> & \uE000crypto\uE001 news", "…a &lt;b&gt; &amp; <b>crypto</b> news"},
+		{"no matches", "plain", "plain"},
+		{"unterminated match is closed", "\uE000open", "<b>open</b>"},
+		{"stray markers are dropped", "a\uE001b\uE000c\uE000d\uE001", "ab<b>cd</b>"},
+		{"multibyte text", "\uE000加密\uE001货币", "<b>加密</b>货币"},
+	}
+	for _, tt := range tests {
+		if got := HTML(tt.value, "<b>", "</b>"); got != tt.want {
+			t.Errorf("%s: HTML(%q) = %q, want %q", tt.name, tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestFormattedAndText(t *testing.T) {
+	hit := map[string]interface{}{
+		"text":       "full text",
+		"_formatted": map[string]interface{}{"text": "…the \uE000match\uE001 here…"},
+	}
+	got := Formatted(hit, "text")
+	if Text(got) != "…the match here…" {
+		t.Errorf("Text(%q) = %q", got, Text(got))
+	}
+	if Formatted(hit, "title") != "" || Formatted(map[string]interface{}{}, "text") != "" {
+		t.Error("missing attributes should be empty")
+	}
+}
@@ -0,0 +1,175 @@
+package query
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Sort orders accepted by Fields.SortRules.
+const (
+	SortRelevance = "relevance"
+	SortNewest    = "newest"
+	SortMembers   = "members"
+	SortGrowth    = "growth"
+)
+
+// Facets accepted by Fields.FacetAttributes.
+const (
+	FacetType       = "type"
+	FacetLanguage   = "language"
+	FacetCountry    = "country"
+	FacetCategories = "categories"
+)
+
+var (
+	sortOrder  = []string{SortRelevance, SortNewest, SortMembers, SortGrowth}
+	agoPattern = regexp.MustCompile(`^(\d+)([hd])$`)
+)
+
+// errorf returns an option error matching ErrInvalidQuery.
+func errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("%w: %s", ErrInvalidQuery, fmt.Sprintf(format, args...))
+}
+
+// sortField returns the attribute sorted for a sort order, or "" when the
+// index does not support it. Relevance has no attribute.
+func (f Fields) sortField(sort string) string {
+	switch sort {
+	case SortNewest:
+		return f.Recency
+	case SortMembers:
+		return f.Members
+	case SortGrowth:
+		return f.Growth
+	}
+	return ""
+}
+
+// Sorts lists the sort orders supported by the index, relevance first.
+func (f Fields) Sorts() []string {
+	sorts := []string{SortRelevance}
+	for _, s := range sortOrder[1:] {
+		if f.sortField(s) != "" {
+			sorts = append(sorts, s)
+		}
+	}
+	return sorts
+}
+
+// SortRules returns the Meilisearch sort parameter for a sort order. An empty
+// sort selects DefaultSort; relevance returns nil so Meilisearch ranks by its
+// ranking rules alone.
+func (f Fields) SortRules(sort string) ([]string, error) {
+	sort = strings.ToLower(strings.TrimSpace(sort))
+	if sort == "" {
+		sort = f.DefaultSort
+	}
+	if sort == "" || sort == SortRelevance {
+		return nil, nil
+	}
+	field := f.sortField(sort)
+	if field == "" {
+		return nil, errorf("sort %q is not supported for this search, use %s", sort, strings.Join(f.Sorts(), ", "))
+	}
+	return []string{field + ":desc"}, nil
+}
+
+// TimeRange renders from and to as a filter on Date. Each bound is either
+// relative to now (1h, 24h, 7d, 30d: that long ago), a date (2025-01-31, UTC)
+// or an RFC 3339 timestamp. from is inclusive; to is exclusive, except that a
+// date includes the whole day. Empty bounds are open.
+func (f Fields) TimeRange(from, to string, now time.Time) (string, error) {
+	from, to = strings.TrimSpace(from), strings.TrimSpace(to)
+	if from == "" && to == "" {
+		return "", nil
+	}
+	if f.Date == "" {
+		return "", errorf("from and to are not supported for this search")
+	}
+	var filters []string
+	var start, end time.Time
+	if from != "" {
+		t, err := parseTime(from, now, false)
+		if err != nil {
+			return "", errorf("from: %v", err)
+		}
+		start = t
+		filters = append(filters, fmt.Sprintf("%s >= %d", f.Date, t.Unix()))
+	}
+	if to != "" {
+		t, err := parseTime(to, now, true)
+		if err != nil {
+			return "", errorf("to: %v", err)
+		}
+		end = t
+		filters = append(filters, fmt.Sprintf("%s < %d", f.Date, t.Unix()))
+	}
+	if from != "" && to != "" && !start.Before(end) {
+		return "", errorf("from must be before to")
+	}
+	return strings.Join(filters, " AND "), nil
+}
+
+// parseTime parses a TimeRange bound. endOfDay moves a date to the following
+// midnight so that an exclusive upper bound still includes the day.
+func parseTime(s string, now time.Time, endOfDay bool) (time.Time, error) {
+	if m := agoPattern.FindStringSubmatch(s); m != nil {
+		n, err := strconv.Atoi(m[1])
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid duration %q", s)
+		}
+		unit := time.Hour
+		if m[2] == "d" {
+			unit = 24 * time.Hour
+		}
+		return now.Add(-time.Duration(n) * unit), nil
+	}
+	if day, err := time.Parse("2006-01-02", s); err == nil {
+		if endOfDay {
+			day = day.AddDate(0, 0, 1)
+		}
+		return day, nil
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("expected 24h, 7d, a date like 2025-01-31 or an RFC 3339 time, got %q", s)
+}
+
+// FacetAttributes maps facet names to the attributes of the index, in the same
+// order, for the Meilisearch facets parameter. Names may be repeated or comma
+// separated; duplicates are dropped.
+func (f Fields) FacetAttributes(names []string) (facets []string, attributes []string, err error) {
+	seen := make(map[string]bool)
+	for _, list := range names {
+		for _, name := range strings.Split(list, ",") {
+			name = strings.ToLower(strings.TrimSpace(name))
+			if name == "" || seen[name] {
+				continue
+			}
+			var attribute string
+			switch name {
+			case FacetType:
+				attribute = f.Type
+			case FacetLanguage:
+				attribute = f.Language
+			case FacetCountry:
+				attribute = f.Country
+			case FacetCategories:
+				attribute = f.Categories
+			default:
+				return nil, nil, errorf("unknown facet %q, use type, language, country or categories", name)
+			}
+			if attribute == "" {
+				return nil, nil, errorf("facet %q is not supported for this search", name)
+			}
+			seen[name] = true
+			facets = append(facets, name)
+			attributes = append(attributes, attribute)
+		}
+	}
+	return facets, attributes, nil
+}
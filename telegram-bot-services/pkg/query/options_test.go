@@ -0,0 +1,82 @@
+package query
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestSortRules(t *testing.T) {
+	tests := []struct {
+		fields Fields
+		sort   string
+		want   []string
+	}{
+		{ChatFields, "", []string{"members_count:desc"}},
+		{ChatFields, "relevance", nil},
+		{ChatFields, "Growth", []string{"growth_rate:desc"}},
+		{ChatFields, "newest", []string{"last_activity:desc"}},
+		{MessageFields, "", []string{"date:desc"}},
+		{MessageFields, "newest", []string{"date:desc"}},
+	}
+	for _, tt := range tests {
+		got, err := tt.fields.SortRules(tt.sort)
+		if err != nil || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("SortRules(%q) = %v, %v; want %v", tt.sort, got, err, tt.want)
+		}
+	}
+	for _, sort := range []string{"members", "growth", "oldest"} {
+		if _, err := MessageFields.SortRules(sort); !errors.Is(err, ErrInvalidQuery) {
+			t.Errorf("messages SortRules(%q) error = %v, want ErrInvalidQuery", sort, err)
+		}
+	}
+	if got := MessageFields.Sorts(); !reflect.DeepEqual(got, []string{"relevance", "newest"}) {
+		t.Errorf("MessageFields.Sorts() = %v", got)
+	}
+}
+
+func TestTimeRange(t *testing.T) {
+	now := time.Date(2025, 3, 10, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		from, to string
+		want     string
+	}{
+		{"", "", ""},
+		{"24h", "", "date >= 1741521600"},
+		{"7d", "1h", "date >= 1741003200 AND date < 1741604400"},
+		{"2025-03-01", "2025-03-01", "date >= 1740787200 AND date < 1740873600"},
+		{"", "2025-03-01T08:00:00+08:00", "date < 1740787200"},
+	}
+	for _, tt := range tests {
+		got, err := MessageFields.TimeRange(tt.from, tt.to, now)
+		if err != nil || got != tt.want {
+			t.Errorf("TimeRange(%q, %q) = %q, %v; want %q", tt.from, tt.to, got, err, tt.want)
+		}
+	}
+	for _, bounds := range [][2]string{{"yesterday", ""}, {"", "3w"}, {"1h", "7d"}} {
+		if _, err := MessageFields.TimeRange(bounds[0], bounds[1], now); !errors.Is(err, ErrInvalidQuery) {
+			t.Errorf("TimeRange(%q, %q) error = %v, want ErrInvalidQuery", bounds[0], bounds[1], err)
+		}
+	}
+	if _, err := ChatFields.TimeRange("24h", "", now); !errors.Is(err, ErrInvalidQuery) {
+		t.Errorf("chats TimeRange error = %v, want ErrInvalidQuery", err)
+	}
+}
+
+func TestFacetAttributes(t *testing.T) {
+	facets, attributes, err := ChatFields.FacetAttributes([]string{"type, Language", "country", "type"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(facets, []string{"type", "language", "country"}) ||
+		!reflect.DeepEqual(attributes, []string{"type", "language_code", "country"}) {
+		t.Errorf("FacetAttributes = %v, %v", facets, attributes)
+	}
+	if _, _, err := ChatFields.FacetAttributes([]string{"owner"}); !errors.Is(err, ErrInvalidQuery) {
+		t.Errorf("unknown facet error = %v, want ErrInvalidQuery", err)
+	}
+	if _, _, err := MessageFields.FacetAttributes([]string{"categories"}); !errors.Is(err, ErrInvalidQuery) {
+		t.Errorf("unsupported facet error = %v, want ErrInvalidQuery", err)
+	}
+}
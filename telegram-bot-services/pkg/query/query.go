@@ -0,0 +1,607 @@
+// Package query parses the search syntax shared by bot-service and management-service
+// into a Meilisearch query string and a validated filter expression.
+//
+// Supported syntax:
+//
+//	crypto news              all words must match
+//	"exact phrase"           phrase match
+//	-spam  NOT spam          exclude a word or "phrase"
+//	type:channel             group, supergroup, channel, bot; comma separated for several
+//	members:>1000            >, >=, <, <=, =, or a range 100..5000; k/m suffixes (10k)
+//	lang:zh                  language code
+//	in:@somechannel in:-100… restrict to one chat by username or chat ID
+//	after:2025-01-01         on or after a date (UTC); before: is exclusive
+//	a OR b, (a OR b) c       OR and parentheses between qualifiers
+//
+// Qualifiers can be negated with - or NOT. Meilisearch cannot OR free-text terms,
+// so OR and parentheses only accept qualifiers. Unknown qualifiers (foo:bar) are
+// searched as plain words.
+//
+// The sort, from/to and facets parameters of the search APIs are validated by
+// Fields.SortRules, Fields.TimeRange and Fields.FacetAttributes.
+package query
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// ErrInvalidQuery is matched by every parse error via errors.Is.
+var ErrInvalidQuery = errors.New("invalid query")
+
+// Error describes a malformed query. Pos is the rune offset of the offending token.
+type Error struct {
+	Pos int
+	Msg string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("invalid query at position %d: %s", e.Pos+1, e.Msg)
+}
+
+// Is reports whether target is ErrInvalidQuery.
+func (e *Error) Is(target error) bool {
+	return target == ErrInvalidQuery
+}
+
+// Fields maps qualifiers to the attribute names of an index. A qualifier whose
+// field is empty is rejected as unsupported for that index.
+type Fields struct {
+	Type         string
+	Members      string
+	Language     string
+	ChatID       string
+	ChatUsername string
+	// Date must hold Unix seconds.
+	Date string
+
+	// The fields below are used by the search options in options.go.
+	Growth     string
+	Country    string
+	Categories string
+	// Recency is sorted descending for SortNewest.
+	Recency string
+	// DefaultSort applies when no sort is requested.
+	DefaultSort string
+}
+
+// ChatFields are the telegram_index attributes.
+var ChatFields = Fields{
+	Type:        "type",
+	Members:     "members_count",
+	Language:    "language_code",
+	Growth:      "growth_rate",
+	Country:     "country",
+	Categories:  "categories",
+	Recency:     "last_activity",
+	DefaultSort: SortMembers,
+}
+
+// MessageFields are the attributes of the messages index.
+var MessageFields = Fields{
+	Type:         "type",
+	ChatID:       "chat_id",
+	ChatUsername: "username",
+	Date:         "date",
+	Recency:      "date",
+	DefaultSort:  SortNewest,
+}
+
+// Query is a parsed search.
+type Query struct {
+	// Text is passed as Meilisearch q: words, "phrases" and -excluded terms.
+	Text string
+	// Filter is a Meilisearch filter expression, empty when there are no qualifiers.
+	Filter string
+}
+
+// Parse parses input using fields to name the filtered attributes.
+func Parse(input string, fields Fields) (Query, error) {
+	tokens, err := lex(input)
+	if err != nil {
+		return Query{}, err
+	}
+	p := &parser{tokens: tokens, fields: fields}
+	if len(tokens) == 0 {
+		return Query{}, nil
+	}
+	root, err := p.parseOr()
+	if err != nil {
+		return Query{}, err
+	}
+	if tok := p.peek(); tok != nil {
+		return Query{}, &Error{Pos: tok.pos, Msg: fmt.Sprintf("unexpected %q", tok.text)}
+	}
+
+	var text, filters []string
+	for _, n := range flattenAnd(root) {
+		switch n := n.(type) {
+		case *termNode:
+			text = append(text, n.query())
+		case *notNode:
+			if term, ok := n.child.(*termNode); ok {
+				text = append(text, "-"+term.query())
+				continue
+			}
+			f, err := compileFilter(n)
+			if err != nil {
+				return Query{}, err
+			}
+			filters = append(filters, f)
+		default:
+			f, err := compileFilter(n)
+			if err != nil {
+				return Query{}, err
+			}
+			filters = append(filters, f)
+		}
+	}
+	return Query{Text: strings.Join(text, " "), Filter: And(filters...)}, nil
+}
+
+// And joins non-empty filter expressions with AND, parenthesising each one.
+func And(filters ...string) string {
+	parts := make([]string, 0, len(filters))
+	for _, f := range filters {
+		if f != "" {
+			parts = append(parts, f)
+		}
+	}
+	if len(parts) == 1 {
+		return parts[0]
+	}
+	for i, f := range parts {
+		parts[i] = "(" + f + ")"
+	}
+	return strings.Join(parts, " AND ")
+}
+
+type tokenKind int
+
+const (
+	tokWord tokenKind = iota
+	tokPhrase
+	tokQualifier
+	tokLParen
+	tokRParen
+	tokNot
+	tokAnd
+	tokOr
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	// key and value are set for qualifiers.
+	key, value string
+	pos        int
+}
+
+// qualifiers lists the recognised qualifier keys; anything else is a plain word.
+var qualifiers = map[string]bool{
+	"type": true, "members": true, "lang": true, "in": true, "after": true, "before": true,
+}
+
+func lex(input string) ([]token, error) {
+	runes := []rune(input)
+	var tokens []token
+	i := 0
+	for i < len(runes) {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '(':
+			tokens = append(tokens, token{kind: tokLParen, text: "(", pos: i})
+			i++
+		case r == ')':
+			tokens = append(tokens, token{kind: tokRParen, text: ")", pos: i})
+			i++
+		case r == '"':
+			phrase, next, err := readPhrase(runes, i)
+			if err != nil {
+				return nil, err
+			}
+			if strings.TrimSpace(phrase) == "" {
+				return nil, &Error{Pos: i, Msg: "empty phrase"}
+			}
+			tokens = append(tokens, token{kind: tokPhrase, text: phrase, pos: i})
+			i = next
+		case r == '-' && i+1 < len(runes) && !unicode.IsSpace(runes[i+1]) && runes[i+1] != ')':
+			tokens = append(tokens, token{kind: tokNot, text: "-", pos: i})
+			i++
+		default:
+			start := i
+			for i < len(runes) && !unicode.IsSpace(runes[i]) && runes[i] != '(' && runes[i] != ')' && runes[i] != '"' {
+				i++
+			}
+			word := string(runes[start:i])
+			if word == "-" {
+				// A dangling dash ("crypto - news") is punctuation, not a negation.
+				continue
+			}
+			if key, value, ok := strings.Cut(word, ":"); ok && qualifiers[strings.ToLower(key)] {
+				if value == "" && i < len(runes) && runes[i] == '"' {
+					phrase, next, err := readPhrase(runes, i)
+					if err != nil {
+						return nil, err
+					}
+					value, i = phrase, next
+				}
+				if value == "" {
+					return nil, &Error{Pos: start, Msg: fmt.Sprintf("missing value for %s:", key)}
+				}
+				tokens = append(tokens, token{kind: tokQualifier, text: word, key: strings.ToLower(key), value: value, pos: start})
+				continue
+			}
+			switch word {
+			case "AND":
+				tokens = append(tokens, token{kind: tokAnd, text: word, pos: start})
+			case "OR", "|":
+				tokens = append(tokens, token{kind: tokOr, text: word, pos: start})
+			case "NOT":
+				tokens = append(tokens, token{kind: tokNot, text: word, pos: start})
+			default:
+				tokens = append(tokens, token{kind: tokWord, text: word, pos: start})
+			}
+		}
+	}
+	return tokens, nil
+}
+
+// readPhrase reads a double-quoted string starting at runes[start] and returns
+// its content and the index after the closing quote.
+func readPhrase(runes []rune, start int) (string, int, error) {
+	for j := start + 1; j < len(runes); j++ {
+		if runes[j] == '"' {
+			return string(runes[start+1 : j]), j + 1, nil
+		}
+	}
+	return "", 0, &Error{Pos: start, Msg: "unterminated quote"}
+}
+
+type node interface{}
+
+type termNode struct {
+	text   string
+	phrase bool
+	pos    int
+}
+
+// query renders the term for Meilisearch q.
+func (t *termNode) query() string {
+	if t.phrase {
+		return `"` + t.text + `"`
+	}
+	return t.text
+}
+
+type filterNode struct {
+	expr string
+}
+
+type notNode struct {
+	child node
+	pos   int
+}
+
+type andNode struct {
+	children []node
+}
+
+type orNode struct {
+	children []node
+	pos      int
+}
+
+type parser struct {
+	tokens []token
+	pos    int
+	fields Fields
+}
+
+func (p *parser) peek() *token {
+	if p.pos < len(p.tokens) {
+		return &p.tokens[p.pos]
+	}
+	return nil
+}
+
+func (p *parser) parseOr() (node, error) {
+	first, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	children := []node{first}
+	var orPos int
+	for {
+		tok := p.peek()
+		if tok == nil || tok.kind != tokOr {
+			break
+		}
+		if len(children) == 1 {
+			orPos = tok.pos
+		}
+		p.pos++
+		if next := p.peek(); next == nil || next.kind == tokRParen || next.kind == tokOr {
+			return nil, &Error{Pos: tok.pos, Msg: "OR needs something on both sides"}
+		}
+		child, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		children = append(children, child)
+	}
+	if len(children) == 1 {
+		return first, nil
+	}
+	return &orNode{children: children, pos: orPos}, nil
+}
+
+func (p *parser) parseAnd() (node, error) {
+	var children []node
+	for {
+		tok := p.peek()
+		if tok == nil || tok.kind == tokOr || tok.kind == tokRParen {
+			break
+		}
+		if tok.kind == tokAnd {
+			if len(children) == 0 {
+				return nil, &Error{Pos: tok.pos, Msg: "AND needs something on both sides"}
+			}
+			p.pos++
+			if next := p.peek(); next == nil || next.kind == tokOr || next.kind == tokRParen || next.kind == tokAnd {
+				return nil, &Error{Pos: tok.pos, Msg: "AND needs something on both sides"}
+			}
+			continue
+		}
+		child, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		children = append(children, child)
+	}
+	switch len(children) {
+	case 0:
+		if tok := p.peek(); tok != nil {
+			return nil, &Error{Pos: tok.pos, Msg: fmt.Sprintf("unexpected %q", tok.text)}
+		}
+		return nil, &Error{Pos: p.endPos(), Msg: "unexpected end of query"}
+	case 1:
+		return children[0], nil
+	}
+	return &andNode{children: children}, nil
+}
+
+func (p *parser) parseUnary() (node, error) {
+	tok := p.peek()
+	if tok.kind != tokNot {
+		return p.parsePrimary()
+	}
+	p.pos++
+	if p.peek() == nil {
+		return nil, &Error{Pos: tok.pos, Msg: "nothing to exclude after " + tok.text}
+	}
+	child, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	if inner, ok := child.(*notNode); ok {
+		return inner.child, nil
+	}
+	return &notNode{child: child, pos: tok.pos}, nil
+}
+
+func (p *parser) parsePrimary() (node, error) {
+	tok := p.peek()
+	p.pos++
+	switch tok.kind {
+	case tokWord:
+		return &termNode{text: tok.text, pos: tok.pos}, nil
+	case tokPhrase:
+		return &termNode{text: tok.text, phrase: true, pos: tok.pos}, nil
+	case tokQualifier:
+		expr, err := p.fields.qualifier(tok)
+		if err != nil {
+			return nil, err
+		}
+		return &filterNode{expr: expr}, nil
+	case tokLParen:
+		inner, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if closing := p.peek(); closing == nil || closing.kind != tokRParen {
+			return nil, &Error{Pos: tok.pos, Msg: "unclosed parenthesis"}
+		}
+		p.pos++
+		return inner, nil
+	}
+	return nil, &Error{Pos: tok.pos, Msg: fmt.Sprintf("unexpected %q", tok.text)}
+}
+
+func (p *parser) endPos() int {
+	if len(p.tokens) == 0 {
+		return 0
+	}
+	last := p.tokens[len(p.tokens)-1]
+	return last.pos + len([]rune(last.text))
+}
+
+// flattenAnd returns the conjuncts of n.
+func flattenAnd(n node) []node {
+	and, ok := n.(*andNode)
+	if !ok {
+		return []node{n}
+	}
+	var out []node
+	for _, child := range and.children {
+		out = append(out, flattenAnd(child)...)
+	}
+	return out
+}
+
+// compileFilter renders a subtree made only of qualifiers.
+func compileFilter(n node) (string, error) {
+	switch n := n.(type) {
+	case *filterNode:
+		return n.expr, nil
+	case *notNode:
+		inner, err := compileFilter(n.child)
+		if err != nil {
+			return "", err
+		}
+		return "NOT (" + inner + ")", nil
+	case *andNode:
+		return compileJoin(n.children, " AND ")
+	case *orNode:
+		return compileJoin(n.children, " OR ")
+	case *termNode:
+		return "", &Error{Pos: n.pos, Msg: fmt.Sprintf("%q: OR and parentheses can only combine filters such as type:channel", n.text)}
+	}
+	return "", fmt.Errorf("%w: unknown node %T", ErrInvalidQuery, n)
+}
+
+func compileJoin(children []node, op string) (string, error) {
+	parts := make([]string, 0, len(children))
+	for _, child := range children {
+		expr, err := compileFilter(child)
+		if err != nil {
+			return "", err
+		}
+		parts = append(parts, "("+expr+")")
+	}
+	return strings.Join(parts, op), nil
+}
+
+var (
+	chatTypes       = map[string]bool{"group": true, "supergroup": true, "channel": true, "bot": true}
+	languagePattern = regexp.MustCompile(`^[a-z]{2,3}(-[a-z0-9]{2,8})?$`)
+	usernamePattern = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_]{3,31}$`)
+	chatIDPattern   = regexp.MustCompile(`^-?[0-9]{1,20}$`)
+	rangePattern    = regexp.MustCompile(`^([0-9.]+[kKmM]?)\.\.([0-9.]+[kKmM]?)$`)
+	comparePattern  = regexp.MustCompile(`^(>=|<=|>|<|=)?([0-9.]+[kKmM]?)$`)
+)
+
+// qualifier renders a qualifier token as a filter expression.
+func (f Fields) qualifier(tok *token) (string, error) {
+	supported := map[string]bool{
+		"type":    f.Type != "",
+		"members": f.Members != "",
+		"lang":    f.Language != "",
+		"in":      f.ChatID != "" || f.ChatUsername != "",
+		"after":   f.Date != "",
+		"before":  f.Date != "",
+	}[tok.key]
+	if !supported {
+		return "", &Error{Pos: tok.pos, Msg: tok.key + ": is not supported for this search"}
+	}
+	invalid := func(format string, args ...interface{}) error {
+		return &Error{Pos: tok.pos, Msg: fmt.Sprintf("%s: ", tok.key) + fmt.Sprintf(format, args...)}
+	}
+
+	switch tok.key {
+	case "type":
+		var values []string
+		for _, v := range strings.Split(strings.ToLower(tok.value), ",") {
+			if !chatTypes[v] {
+				return "", invalid("unknown type %q, use group, supergroup, channel or bot", v)
+			}
+			values = append(values, v)
+			// Users do not distinguish groups from supergroups.
+			if v == "group" {
+				values = append(values, "supergroup")
+			}
+		}
+		if len(values) == 1 {
+			return fmt.Sprintf("%s = %s", f.Type, quote(values[0])), nil
+		}
+		quoted := make([]string, len(values))
+		for i, v := range values {
+			quoted[i] = quote(v)
+		}
+		return fmt.Sprintf("%s IN [%s]", f.Type, strings.Join(quoted, ", ")), nil
+
+	case "members":
+		if m := rangePattern.FindStringSubmatch(tok.value); m != nil {
+			low, err1 := parseCount(m[1])
+			high, err2 := parseCount(m[2])
+			if err1 != nil || err2 != nil || low > high {
+				return "", invalid("invalid range %q", tok.value)
+			}
+			return fmt.Sprintf("%s %d TO %d", f.Members, low, high), nil
+		}
+		m := comparePattern.FindStringSubmatch(tok.value)
+		if m == nil {
+			return "", invalid("expected a number such as >1000 or 100..5000, got %q", tok.value)
+		}
+		n, err := parseCount(m[2])
+		if err != nil {
+			return "", invalid("invalid number %q", m[2])
+		}
+		op := m[1]
+		if op == "" {
+			op = "="
+		}
+		return fmt.Sprintf("%s %s %d", f.Members, op, n), nil
+
+	case "lang":
+		lang := strings.ToLower(tok.value)
+		if !languagePattern.MatchString(lang) {
+			return "", invalid("invalid language code %q", tok.value)
+		}
+		return fmt.Sprintf("%s = %s", f.Language, quote(lang)), nil
+
+	case "in":
+		value := strings.TrimPrefix(tok.value, "@")
+		if chatIDPattern.MatchString(value) {
+			if f.ChatID == "" {
+				return "", invalid("chat IDs are not supported for this search")
+			}
+			return fmt.Sprintf("%s = %s", f.ChatID, value), nil
+		}
+		if !usernamePattern.MatchString(value) {
+			return "", invalid("expected @username or a chat ID, got %q", tok.value)
+		}
+		if f.ChatUsername == "" {
+			return "", invalid("usernames are not supported for this search")
+		}
+		return fmt.Sprintf("%s = %s", f.ChatUsername, quote(value)), nil
+
+	default: // after, before
+		day, err := time.Parse("2006-01-02", tok.value)
+		if err != nil {
+			return "", invalid("expected a date like 2025-01-31, got %q", tok.value)
+		}
+		if tok.key == "after" {
+			return fmt.Sprintf("%s >= %d", f.Date, day.Unix()), nil
+		}
+		return fmt.Sprintf("%s < %d", f.Date, day.Unix()), nil
+	}
+}
+
+// parseCount parses 1500, 1.5k or 2m.
+func parseCount(s string) (int64, error) {
+	multiplier := 1.0
+	switch s[len(s)-1] {
+	case 'k', 'K':
+		multiplier, s = 1e3, s[:len(s)-1]
+	case 'm', 'M':
+		multiplier, s = 1e6, s[:len(s)-1]
+	}
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, err
+	}
+	return int64(v * multiplier), nil
+}
+
+// quote renders a Meilisearch filter string literal.
+func quote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
@@ -0,0 +1,155 @@
+package query
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name   string
+		input  string
+		fields Fields
+		text   string
+		filter string
+	}{
+		{
+			name:   "plain words",
+			input:  "crypto  news",
+			fields: ChatFields,
+			text:   "crypto news",
+		},
+		{
+			name:   "spec example",
+			input:  `"exact phrase" -spam type:channel members:>1000 lang:zh in:@somechannel after:2025-01-01`,
+			fields: Fields{Type: "type", Members: "members_count", Language: "language_code", ChatUsername: "username", Date: "date"},
+			text:   `"exact phrase" -spam`,
+			filter: `(type = "channel") AND (members_count > 1000) AND (language_code = "zh") AND (username = "somechannel") AND (date >= 1735689600)`,
+		},
+		{
+			name:   "group includes supergroups",
+			input:  "type:group",
+			fields: ChatFields,
+			filter: `type IN ["group", "supergroup"]`,
+		},
+		{
+			name:   "type list",
+			input:  "type:channel,bot",
+			fields: ChatFields,
+			filter: `type IN ["channel", "bot"]`,
+		},
+		{
+			name:   "members range with suffixes",
+			input:  "members:1k..2.5m",
+			fields: ChatFields,
+			filter: "members_count 1000 TO 2500000",
+		},
+		{
+			name:   "NOT phrase and NOT qualifier",
+			input:  `news NOT "free money" -type:bot`,
+			fields: ChatFields,
+			text:   `news -"free money"`,
+			filter: `NOT (type = "bot")`,
+		},
+		{
+			name:   "OR between qualifiers",
+			input:  "airdrop (type:channel OR members:>=500) lang:en",
+			fields: ChatFields,
+			text:   "airdrop",
+			filter: `((type = "channel") OR (members_count >= 500)) AND (language_code = "en")`,
+		},
+		{
+			name:   "chat id scope and before",
+			input:  "in:-1001234567890 before:2024-03-10 hello",
+			fields: MessageFields,
+			text:   "hello",
+			filter: `(chat_id = -1001234567890) AND (date < 1710028800)`,
+		},
+		{
+			name:   "explicit AND, dangling dash and unknown qualifier",
+			input:  "crypto AND news - https://t.me/x foo:bar",
+			fields: ChatFields,
+			text:   "crypto news https://t.me/x foo:bar",
+		},
+		{
+			name:   "quoted qualifier value",
+			input:  `in:"somechannel"`,
+			fields: MessageFields,
+			filter: `username = "somechannel"`,
+		},
+		{
+			name:   "empty",
+			input:  "   ",
+			fields: ChatFields,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Parse(tt.input, tt.fields)
+			if err != nil {
+				t.Fatalf("Parse(%q) error: %v", tt.input, err)
+			}
+			if got.Text != tt.text {
+				t.Errorf("Text = %q, want %q", got.Text, tt.text)
+			}
+			if got.Filter != tt.filter {
+				t.Errorf("Filter = %q, want %q", got.Filter, tt.filter)
+			}
+		})
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		input string
+		pos   int
+	}{
+		{input: `crypto "unterminated`, pos: 7},
+		{input: "(type:channel OR type:bot", pos: 0},
+		{input: "type:channel)", pos: 12},
+		{input: "type:forum", pos: 0},
+		{input: "members:lots", pos: 0},
+		{input: "members:500..100", pos: 0},
+		{input: "lang:chinese!", pos: 0},
+		{input: "after:yesterday", pos: 0},
+		{input: "in:@a", pos: 0},
+		{input: "crypto OR bitcoin", pos: 0},
+		{input: "type:bot OR", pos: 9},
+		{input: "AND crypto", pos: 0},
+		{input: "crypto NOT", pos: 7},
+		{input: "lang:", pos: 0},
+		{input: `""`, pos: 0},
+	}
+	for _, tt := range tests {
+		_, err := Parse(tt.input, ChatFields)
+		if !errors.Is(err, ErrInvalidQuery) {
+			t.Errorf("Parse(%q) error = %v, want ErrInvalidQuery", tt.input, err)
+			continue
+		}
+		var qerr *Error
+		if errors.As(err, &qerr) && qerr.Pos != tt.pos {
+			t.Errorf("Parse(%q) error position = %d, want %d (%v)", tt.input, qerr.Pos, tt.pos, err)
+		}
+	}
+}
+
+func TestParseUnsupportedQualifier(t *testing.T) {
+	if _, err := Parse("after:2025-01-01", ChatFields); !errors.Is(err, ErrInvalidQuery) {
+		t.Errorf("after: on chats error = %v, want ErrInvalidQuery", err)
+	}
+	if _, err := Parse("members:>10", MessageFields); !errors.Is(err, ErrInvalidQuery) {
+		t.Errorf("members: on messages error = %v, want ErrInvalidQuery", err)
+	}
+}
+
+func TestAnd(t *testing.T) {
+	if got := And("", "a = 1", ""); got != "a = 1" {
+		t.Errorf("And = %q", got)
+	}
+	if got := And("a = 1", "b = 2 OR c = 3"); got != "(a = 1) AND (b = 2 OR c = 3)" {
+		t.Errorf("And = %q", got)
+	}
+	if got := And(); got != "" {
+		t.Errorf("And() = %q", got)
+	}
+}
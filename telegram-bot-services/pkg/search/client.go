@@ -0,0 +1,162 @@
+package search
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	// taskPollInterval is the first wait between task status checks; it
+	// doubles up to taskPollMaxInterval.
+	taskPollInterval    = 50 * time.Millisecond
+	taskPollMaxInterval = time.Second
+)
+
+var (
+	// ErrNotFound matches errors for indexes, documents or tasks that do not exist.
+	ErrNotFound = errors.New("not found")
+	// ErrIndexExists matches a failed task that created an index that already exists.
+	ErrIndexExists = errors.New("index already exists")
+)
+
+// TaskError is an asynchronous Meilisearch task that failed or was canceled.
+type TaskError struct {
+	UID     int64
+	Status  string
+	Code    string
+	Message string
+}
+
+func (e *TaskError) Error() string {
+	if e.Code == "" {
+		return fmt.Sprintf("Meilisearch task %d %s", e.UID, e.Status)
+	}
+	return fmt.Sprintf("Meilisearch task %d %s: %s (%s)", e.UID, e.Status, e.Message, e.Code)
+}
+
+// Is reports index_already_exists failures as ErrIndexExists and
+// index_not_found failures as ErrNotFound.
+func (e *TaskError) Is(target error) bool {
+	switch target {
+	case ErrIndexExists:
+		return e.Code == "index_already_exists"
+	case ErrNotFound:
+		return e.Code == "index_not_found"
+	}
+	return false
+}
+
+// task is the summary Meilisearch returns for a request that enqueues work.
+type task struct {
+	TaskUID int64 `json:"taskUid"`
+}
+
+// do sends a JSON request to Meilisearch and decodes the response into out,
+// which may be nil. Requests without a deadline are bounded by Config.Timeout.
+func (c *Client) do(ctx context.Context, method, path string, body, out interface{}) error {
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.cfg.Timeout)
+		defer cancel()
+	}
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("failed to encode Meilisearch request: %w", err)
+		}
+		reader = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.cfg.URL+path, reader)
+	if err != nil {
+		return fmt.Errorf("failed to create Meilisearch request: %w", err)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.cfg.Key != "" {
+		req.Header.Set("Authorization", "Bearer "+c.cfg.Key)
+	}
+	resp, err := c.cfg.HTTPClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send request to Meilisearch: %w", err)
+	}
+	defer resp.Body.Close()
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read Meilisearch response: %w", err)
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		return fmt.Errorf("%w: %s %s: %s", ErrNotFound, method, path, respBody)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("Meilisearch returned status %d for %s %s: %s", resp.StatusCode, method, path, respBody)
+	}
+	if out == nil {
+		return nil
+	}
+	if err := json.Unmarshal(respBody, out); err != nil {
+		return fmt.Errorf("failed to decode Meilisearch response: %w", err)
+	}
+	return nil
+}
+
+// enqueue sends a request that creates an asynchronous task and waits for the
+// task to finish.
+func (c *Client) enqueue(ctx context.Context, method, path string, body interface{}) error {
+	var t task
+	if err := c.do(ctx, method, path, body, &t); err != nil {
+		return err
+	}
+	return c.WaitTask(ctx, t.TaskUID)
+}
+
+// WaitTask polls a task until it succeeds, fails or ctx is done. It returns a
+// *TaskError for failed and canceled tasks. Without a deadline on ctx the wait
+// is bounded by Config.TaskTimeout.
+func (c *Client) WaitTask(ctx context.Context, uid int64) error {
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.cfg.TaskTimeout)
+		defer cancel()
+	}
+	interval := taskPollInterval
+	for {
+		var status struct {
+			Status string `json:"status"`
+			Error  *struct {
+				Message string `json:"message"`
+				Code    string `json:"code"`
+			} `json:"error"`
+		}
+		if err := c.do(ctx, http.MethodGet, "/tasks/"+strconv.FormatInt(uid, 10), nil, &status); err != nil {
+			return fmt.Errorf("failed to get Meilisearch task %d: %w", uid, err)
+		}
+		switch status.Status {
+		case "succeeded":
+			return nil
+		case "failed", "canceled":
+			err := &TaskError{UID: uid, Status: status.Status}
+			if status.Error != nil {
+				err.Code, err.Message = status.Error.Code, status.Error.Message
+			}
+			return err
+		}
+
+		timer := time.NewTimer(interval)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return fmt.Errorf("timed out waiting for Meilisearch task %d (status: %s): %w", uid, status.Status, ctx.Err())
+		case <-timer.C:
+		}
+		interval = min(interval*2, taskPollMaxInterval)
+	}
+}
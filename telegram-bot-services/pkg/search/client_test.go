@@ -0,0 +1,140 @@
+package search
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeTasks answers every write with a new task and reports tasks as
+// succeeded, except those failed by fail.
+type fakeTasks struct {
+	mu       sync.Mutex
+	requests []string
+	bodies   []string
+	// fail maps "METHOD path" to the error code of the task it creates.
+	fail  map[string]string
+	tasks map[int64]string
+}
+
+func (f *fakeTasks) start(t *testing.T, cfg Config) *Client {
+	f.tasks = make(map[int64]string)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		if r.Method == http.MethodGet && strings.HasPrefix(r.URL.Path, "/tasks/") {
+			var uid int64
+			fmt.Sscan(strings.TrimPrefix(r.URL.Path, "/tasks/"), &uid)
+			code, ok := f.tasks[uid]
+			switch {
+			case !ok:
+				w.WriteHeader(http.StatusNotFound)
+			case code == "":
+				fmt.Fprint(w, `{"status": "succeeded"}`)
+			case code == "pending":
+				fmt.Fprint(w, `{"status": "processing"}`)
+			default:
+				fmt.Fprintf(w, `{"status": "failed", "error": {"message": "boom", "code": %q}}`, code)
+			}
+			return
+		}
+		request := r.Method + " " + r.URL.Path
+		body, _ := io.ReadAll(r.Body)
+		f.requests = append(f.requests, request)
+		f.bodies = append(f.bodies, string(body))
+		uid := int64(len(f.tasks) + 1)
+		f.tasks[uid] = f.fail[request]
+		w.WriteHeader(http.StatusAccepted)
+		fmt.Fprintf(w, `{"taskUid": %d}`, uid)
+	}))
+	t.Cleanup(srv.Close)
+	cfg.URL = srv.URL
+	return New(cfg)
+}
+
+func TestEnsureIndex(t *testing.T) {
+	meili := &fakeTasks{fail: map[string]string{"POST /indexes": "index_already_exists"}}
+	client := meili.start(t, Config{})
+
+	if err := client.EnsureIndex(context.Background(), "messages", MessageSettings); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"POST /indexes", "PATCH /indexes/messages/settings"}
+	if strings.Join(meili.requests, ", ") != strings.Join(want, ", ") {
+		t.Errorf("requests = %v, want %v", meili.requests, want)
+	}
+	var settings Settings
+	if err := json.Unmarshal([]byte(meili.bodies[1]), &settings); err != nil || settings.SortableAttributes[0] != "date" {
+		t.Errorf("settings = %s (%v)", meili.bodies[1], err)
+	}
+
+	if err := client.CreateIndex(context.Background(), "messages", MessageSettings); !errors.Is(err, ErrIndexExists) {
+		t.Errorf("CreateIndex error = %v, want ErrIndexExists", err)
+	}
+}
+
+func TestWriteDocumentsInBatches(t *testing.T) {
+	meili := &fakeTasks{}
+	client := meili.start(t, Config{BatchSize: 2})
+
+	docs := make([]Message, 5)
+	for i := range docs {
+		docs[i] = Message{ID: MessageDocumentID(-100, i), ChatID: -100, MessageID: i}
+	}
+	if err := client.AddDocuments(context.Background(), "messages", docs); err != nil {
+		t.Fatal(err)
+	}
+	if len(meili.requests) != 3 || meili.requests[0] != "POST /indexes/messages/documents" {
+		t.Fatalf("requests = %v", meili.requests)
+	}
+	var batch []map[string]interface{}
+	if err := json.Unmarshal([]byte(meili.bodies[2]), &batch); err != nil || len(batch) != 1 || batch[0]["id"] != "-100_4" {
+		t.Errorf("last batch = %s (%v)", meili.bodies[2], err)
+	}
+
+	if err := client.UpdateDocuments(context.Background(), "telegram_index", []Chat{{ID: "-100", GrowthRate: 1.5}}); err != nil {
+		t.Fatal(err)
+	}
+	if meili.requests[3] != "PUT /indexes/telegram_index/documents" || meili.bodies[3] != `[{"id":"-100","growth_rate":1.5}]` {
+		t.Errorf("update = %s %s", meili.requests[3], meili.bodies[3])
+	}
+
+	if err := client.AddDocuments(context.Background(), "messages", Message{}); err == nil {
+		t.Error("AddDocuments accepted a single document")
+	}
+}
+
+func TestTaskFailures(t *testing.T) {
+	meili := &fakeTasks{fail: map[string]string{
+		"POST /indexes/messages/documents/delete-batch": "invalid_document_id",
+		"DELETE /indexes/missing":                       "index_not_found",
+		"DELETE /indexes/slow":                          "pending",
+	}}
+	client := meili.start(t, Config{})
+
+	err := client.DeleteDocuments(context.Background(), "messages", []string{"a b"})
+	var taskErr *TaskError
+	if !errors.As(err, &taskErr) || taskErr.Code != "invalid_document_id" || taskErr.Status != "failed" {
+		t.Errorf("DeleteDocuments error = %v, want a TaskError", err)
+	}
+	if err := client.DeleteIndex(context.Background(), "missing"); err != nil {
+		t.Errorf("DeleteIndex of a missing index: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if err := client.DeleteIndex(ctx, "slow"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("DeleteIndex of a slow task error = %v, want DeadlineExceeded", err)
+	}
+	if err := client.WaitTask(context.Background(), 999); !errors.Is(err, ErrNotFound) {
+		t.Errorf("WaitTask of an unknown task error = %v, want ErrNotFound", err)
+	}
+}
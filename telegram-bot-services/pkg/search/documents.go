@@ -0,0 +1,270 @@
+package search
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"telegram-bot-services/pkg/highlight"
+)
+
+// Chat is a document of the chats index. bot-service writes the basic fields
+// when a chat is submitted; collection-service adds statistics and scores with
+// partial updates.
+type Chat struct {
+	// ID is the Bot API chat ID, e.g. "-1001234567890".
+	ID           string   `json:"id"`
+	Type         string   `json:"type,omitempty"`
+	Title        string   `json:"title,omitempty"`
+	Username     string   `json:"username,omitempty"`
+	FirstName    string   `json:"first_name,omitempty"`
+	LastName     string   `json:"last_name,omitempty"`
+	Description  string   `json:"description,omitempty"`
+	InviteLink   string   `json:"invite_link,omitempty"`
+	MembersCount int64    `json:"members_count,omitempty"`
+	IsVerified   bool     `json:"is_verified,omitempty"`
+	LanguageCode string   `json:"language_code,omitempty"`
+	Country      string   `json:"country,omitempty"`
+	Categories   []string `json:"categories,omitempty"`
+	GrowthRate   float64  `json:"growth_rate,omitempty"`
+	// LastActivity, CreatedAt and UpdatedAt are RFC 3339 times.
+	LastActivity string `json:"last_activity,omitempty"`
+	CreatedAt    string `json:"created_at,omitempty"`
+	UpdatedAt    string `json:"updated_at,omitempty"`
+}
+
+// Message is a document of the messages index, written by the bot-service
+// ingest pipeline and by collection-service. Title, Username and Type
+// describe the chat the message was sent in. The fields after Text are only
+// known to collection-service.
+type Message struct {
+	// ID is MessageDocumentID(ChatID, MessageID).
+	ID         string `json:"id"`
+	ChatID     int64  `json:"chat_id"`
+	MessageID  int    `json:"message_id"`
+	Title      string `json:"title"`
+	Username   string `json:"username"`
+	Type       string `json:"type"`
+	SenderID   int64  `json:"sender_id"`
+	SenderName string `json:"sender_name"`
+	// Date is the Unix time the message was sent.
+	Date int64  `json:"date"`
+	Text string `json:"text"`
+
+	MediaType        string   `json:"media_type,omitempty"`
+	HasMedia         bool     `json:"has_media"`
+	FileName         string   `json:"file_name,omitempty"`
+	FileSize         int64    `json:"file_size,omitempty"`
+	MimeType         string   `json:"mime_type,omitempty"`
+	URLs             []string `json:"urls,omitempty"`
+	HasLink          bool     `json:"has_link"`
+	Mentions         []string `json:"mentions,omitempty"`
+	Hashtags         []string `json:"hashtags,omitempty"`
+	ForwardFromID    int64    `json:"forward_from_id,omitempty"`
+	ForwardFromName  string   `json:"forward_from_name,omitempty"`
+	IsForward        bool     `json:"is_forward"`
+	ReplyToMessageID int      `json:"reply_to_message_id,omitempty"`
+	Views            int      `json:"views,omitempty"`
+	Forwards         int      `json:"forwards,omitempty"`
+	Reactions        int      `json:"reactions,omitempty"`
+	EditDate         int64    `json:"edit_date,omitempty"`
+	GroupedID        int64    `json:"grouped_id,omitempty"`
+	MediaKey         string   `json:"media_key,omitempty"`
+	ThumbnailKey     string   `json:"thumbnail_key,omitempty"`
+}
+
+// MessageDocumentID returns the document ID of a message, <chat_id>_<message_id>.
+func MessageDocumentID(chatID int64, messageID int) string {
+	return fmt.Sprintf("%d_%d", chatID, messageID)
+}
+
+// AddDocuments adds or replaces documents in index uid. docs is a slice of
+// documents with an id, e.g. []Message; it is sent in batches of
+// Config.BatchSize and each batch is waited for.
+func (c *Client) AddDocuments(ctx context.Context, uid string, docs interface{}) error {
+	return c.writeDocuments(ctx, http.MethodPost, uid, docs)
+}
+
+// UpdateDocuments adds documents or updates the fields they contain, leaving
+// other fields of existing documents as they are. docs is as for AddDocuments.
+func (c *Client) UpdateDocuments(ctx context.Context, uid string, docs interface{}) error {
+	return c.writeDocuments(ctx, http.MethodPut, uid, docs)
+}
+
+// writeDocuments sends docs in batches with method, POST to replace and PUT
+// to update.
+func (c *Client) writeDocuments(ctx context.Context, method, uid string, docs interface{}) error {
+	v := reflect.ValueOf(docs)
+	if v.Kind() != reflect.Slice {
+		return fmt.Errorf("documents must be a slice, got %T", docs)
+	}
+	for start := 0; start < v.Len(); start += c.cfg.BatchSize {
+		end := min(start+c.cfg.BatchSize, v.Len())
+		if err := c.enqueue(ctx, method, "/indexes/"+uid+"/documents?primaryKey=id", v.Slice(start, end).Interface()); err != nil {
+			return fmt.Errorf("failed to write %d documents to %s: %w", end-start, uid, err)
+		}
+	}
+	return nil
+}
+
+// DeleteDocuments deletes documents by ID from index uid; IDs that do not
+// exist are ignored.
+func (c *Client) DeleteDocuments(ctx context.Context, uid string, ids []string) error {
+	for start := 0; start < len(ids); start += c.cfg.BatchSize {
+		end := min(start+c.cfg.BatchSize, len(ids))
+		if err := c.enqueue(ctx, http.MethodPost, "/indexes/"+uid+"/documents/delete-batch", ids[start:end]); err != nil {
+			return fmt.Errorf("failed to delete %d documents from %s: %w", end-start, uid, err)
+		}
+	}
+	return nil
+}
+
+// GetDocuments reads a page of documents from index uid, with only fields
+// when given, into out, a pointer to a slice.
+func (c *Client) GetDocuments(ctx context.Context, uid string, offset, limit int, fields []string, out interface{}) error {
+	params := url.Values{"offset": {strconv.Itoa(offset)}, "limit": {strconv.Itoa(limit)}}
+	if len(fields) > 0 {
+		params.Set("fields", strings.Join(fields, ","))
+	}
+	var page struct {
+		Results json.RawMessage `json:"results"`
+	}
+	if err := c.do(ctx, http.MethodGet, "/indexes/"+uid+"/documents?"+params.Encode(), nil, &page); err != nil {
+		return fmt.Errorf("failed to get documents from %s at offset %d: %w", uid, offset, err)
+	}
+	if err := json.Unmarshal(page.Results, out); err != nil {
+		return fmt.Errorf("failed to decode documents from %s: %w", uid, err)
+	}
+	return nil
+}
+
+// FetchDocuments reads the documents with the given IDs from index uid into
+// out, a pointer to a slice. The index must have id as a filterable attribute.
+func (c *Client) FetchDocuments(ctx context.Context, uid string, ids []string, out interface{}) error {
+	quoted := make([]string, 0, len(ids))
+	for _, id := range ids {
+		quoted = append(quoted, strconv.Quote(id))
+	}
+	body := map[string]interface{}{
+		"filter": "id IN [" + strings.Join(quoted, ", ") + "]",
+		"limit":  len(ids),
+	}
+	var page struct {
+		Results json.RawMessage `json:"results"`
+	}
+	if err := c.do(ctx, http.MethodPost, "/indexes/"+uid+"/documents/fetch", body, &page); err != nil {
+		return fmt.Errorf("failed to fetch documents from %s: %w", uid, err)
+	}
+	if err := json.Unmarshal(page.Results, out); err != nil {
+		return fmt.Errorf("failed to decode documents from %s: %w", uid, err)
+	}
+	return nil
+}
+
+// Attribute names of the documents in each index, matching Chat and Message.
+var (
+	chatAttributes = documentAttributes{
+		ID: "id", ChatID: "id", Type: "type", Title: "title", Username: "username",
+		Description: "description", MembersCount: "members_count",
+	}
+	messageAttributes = documentAttributes{
+		ID: "id", ChatID: "chat_id", Type: "type", Title: "title", Username: "username",
+		MessageID: "message_id", Text: "text", Date: "date",
+	}
+)
+
+// documentAttributes maps Hit fields to the attributes of an index; empty
+// names are not present in that index.
+type documentAttributes struct {
+	ID, ChatID, Type, Title, Username, Description string
+	MembersCount, MessageID, Text, Date            string
+}
+
+// fields maps the attributes that can be highlighted to Hit field names.
+func (a documentAttributes) fields() map[string]string {
+	fields := make(map[string]string, 3)
+	for attribute, field := range map[string]string{a.Title: "title", a.Description: "description", a.Text: "text"} {
+		if attribute != "" {
+			fields[attribute] = field
+		}
+	}
+	return fields
+}
+
+// newHit converts a Meilisearch hit of the given kind to the shared schema.
+func (c *Client) newHit(kind string, doc map[string]interface{}) Hit {
+	a := chatAttributes
+	if kind == KindMessage {
+		a = messageAttributes
+	}
+	hit := Hit{
+		Kind:         kind,
+		ID:           stringValue(doc[a.ID]),
+		ChatID:       stringValue(doc[a.ChatID]),
+		Type:         stringValue(doc[a.Type]),
+		Title:        stringValue(doc[a.Title]),
+		Username:     stringValue(doc[a.Username]),
+		Description:  stringValue(doc[a.Description]),
+		MembersCount: intValue(doc[a.MembersCount]),
+		MessageID:    intValue(doc[a.MessageID]),
+		Text:         stringValue(doc[a.Text]),
+		Date:         intValue(doc[a.Date]),
+	}
+
+	fields := a.fields()
+	for attribute, field := range fields {
+		formatted := highlight.Formatted(doc, attribute)
+		if formatted == "" {
+			continue
+		}
+		if hit.Highlight == nil {
+			hit.Highlight = make(map[string]string)
+			hit.snippets = make(map[string]string)
+		}
+		hit.Highlight[field] = highlight.HTML(formatted, c.cfg.HighlightOpen, c.cfg.HighlightClose)
+		hit.snippets[field] = highlight.Text(formatted)
+	}
+	if positions, ok := doc["_matchesPosition"]; ok {
+		var matches map[string][]Match
+		if data, err := json.Marshal(positions); err == nil && json.Unmarshal(data, &matches) == nil {
+			for attribute, list := range matches {
+				if field, ok := fields[attribute]; ok {
+					if hit.MatchesPosition == nil {
+						hit.MatchesPosition = make(map[string][]Match)
+					}
+					hit.MatchesPosition[field] = list
+				}
+			}
+		}
+	}
+	return hit
+}
+
+// stringValue returns strings as they are and numbers without exponent, so
+// that chat IDs stored as numbers read the same as those stored as strings.
+func stringValue(v interface{}) string {
+	switch v := v.(type) {
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	}
+	return ""
+}
+
+// intValue returns numbers and numeric strings as integers, 0 otherwise.
+func intValue(v interface{}) int64 {
+	switch v := v.(type) {
+	case float64:
+		return int64(v)
+	case string:
+		n, _ := strconv.ParseInt(v, 10, 64)
+		return n
+	}
+	return 0
+}
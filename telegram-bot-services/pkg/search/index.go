@@ -0,0 +1,97 @@
+package search
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// Settings are the index settings managed by the services. Empty lists are
+// left unchanged by UpdateSettings.
+type Settings struct {
+	SearchableAttributes []string `json:"searchableAttributes,omitempty"`
+	FilterableAttributes []string `json:"filterableAttributes,omitempty"`
+	SortableAttributes   []string `json:"sortableAttributes,omitempty"`
+	RankingRules         []string `json:"rankingRules,omitempty"`
+}
+
+// ChatSettings are the settings of the chats index. The filterable and
+// sortable attributes back the qualifiers and options of the query package.
+var ChatSettings = Settings{
+	SearchableAttributes: []string{"title", "username", "description", "first_name", "last_name"},
+	FilterableAttributes: []string{
+		"type", "is_verified", "is_restricted", "is_scam", "is_fake", "language_code", "tags",
+		"content_types", "members_count", "sender_is_bot", "country", "categories",
+	},
+	SortableAttributes: []string{"members_count", "growth_rate", "last_activity"},
+	RankingRules:       []string{"words", "typo", "proximity", "attribute", "sort", "exactness", "members_count:desc"},
+}
+
+// MessageSettings are the settings of the messages index.
+var MessageSettings = Settings{
+	SearchableAttributes: []string{"text", "title", "username", "sender_name", "file_name", "hashtags"},
+	FilterableAttributes: []string{
+		"chat_id", "username", "message_id", "type", "sender_id", "date", "media_type", "has_media",
+		"has_link", "hashtags", "mentions", "is_forward", "forward_from_id", "reply_to_message_id", "grouped_id",
+	},
+	SortableAttributes: []string{"date", "views", "forwards", "reactions"},
+}
+
+// IndexExists reports whether index uid exists.
+func (c *Client) IndexExists(ctx context.Context, uid string) (bool, error) {
+	err := c.do(ctx, http.MethodGet, "/indexes/"+uid, nil, nil)
+	if errors.Is(err, ErrNotFound) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// CreateIndex creates index uid with primary key id and applies settings. It
+// fails with ErrIndexExists if the index exists.
+func (c *Client) CreateIndex(ctx context.Context, uid string, settings Settings) error {
+	if err := c.enqueue(ctx, http.MethodPost, "/indexes", map[string]string{"uid": uid, "primaryKey": "id"}); err != nil {
+		return fmt.Errorf("failed to create index %s: %w", uid, err)
+	}
+	return c.UpdateSettings(ctx, uid, settings)
+}
+
+// EnsureIndex creates index uid if it is missing and applies settings either
+// way, so that services starting in any order converge on the same settings.
+func (c *Client) EnsureIndex(ctx context.Context, uid string, settings Settings) error {
+	err := c.CreateIndex(ctx, uid, settings)
+	if errors.Is(err, ErrIndexExists) {
+		return c.UpdateSettings(ctx, uid, settings)
+	}
+	return err
+}
+
+// UpdateSettings applies settings to index uid.
+func (c *Client) UpdateSettings(ctx context.Context, uid string, settings Settings) error {
+	if err := c.enqueue(ctx, http.MethodPatch, "/indexes/"+uid+"/settings", settings); err != nil {
+		return fmt.Errorf("failed to update settings of index %s: %w", uid, err)
+	}
+	return nil
+}
+
+// DeleteIndex deletes index uid; a missing index is not an error.
+func (c *Client) DeleteIndex(ctx context.Context, uid string) error {
+	err := c.enqueue(ctx, http.MethodDelete, "/indexes/"+uid, nil)
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		return fmt.Errorf("failed to delete index %s: %w", uid, err)
+	}
+	return nil
+}
+
+// SwapIndexes atomically swaps the documents and settings of each pair of
+// indexes. Both indexes of a pair must exist.
+func (c *Client) SwapIndexes(ctx context.Context, pairs ...[2]string) error {
+	body := make([]map[string][]string, 0, len(pairs))
+	for _, pair := range pairs {
+		body = append(body, map[string][]string{"indexes": {pair[0], pair[1]}})
+	}
+	if err := c.enqueue(ctx, http.MethodPost, "/swap-indexes", body); err != nil {
+		return fmt.Errorf("failed to swap indexes: %w", err)
+	}
+	return nil
+}
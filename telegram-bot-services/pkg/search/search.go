@@ -0,0 +1,481 @@
+// Package search is the Meilisearch client shared by bot-service,
+// management-service and collection-service. It defines the documents of the
+// chats (telegram_index) and messages indexes, bootstraps indexes with their
+// settings, writes documents in batches and waits for the resulting tasks.
+//
+// A search runs over chats and messages in one Meilisearch federated
+// multi-search, with per-index weights, and returns hits in one schema
+// whatever index they come from.
+//
+// Every call takes a context; calls without a deadline are bounded by
+// Config.Timeout per request and Config.TaskTimeout per task.
+package search
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"telegram-bot-services/pkg/highlight"
+	"telegram-bot-services/pkg/query"
+)
+
+const (
+	// DefaultChatsIndex holds groups, channels and bots.
+	DefaultChatsIndex = "telegram_index"
+	// DefaultMessagesIndex holds messages ingested by bot-service and
+	// collection-service.
+	DefaultMessagesIndex = "messages"
+	// DefaultChatWeight and DefaultMessageWeight multiply the ranking score of
+	// each index in federated search; a chat matching as well as a message
+	// ranks first.
+	DefaultChatWeight    = 1.0
+	DefaultMessageWeight = 0.9
+	// DefaultTimeout bounds a request when the context has no deadline.
+	DefaultTimeout = 10 * time.Second
+	// DefaultTaskTimeout bounds the wait for a task when the context has no
+	// deadline.
+	DefaultTaskTimeout = 5 * time.Minute
+	// DefaultBatchSize is the number of documents written per request.
+	DefaultBatchSize = 1000
+	// DefaultHitsPerPage applies when Request.HitsPerPage is not positive.
+	DefaultHitsPerPage = 10
+	// MaxHitsPerPage caps Request.HitsPerPage.
+	MaxHitsPerPage = 50
+)
+
+// Hit kinds.
+const (
+	KindChat    = "chat"
+	KindMessage = "message"
+)
+
+// Scopes select what a search covers.
+const (
+	// ScopeAll searches chats and messages together.
+	ScopeAll     = "all"
+	ScopeGroup   = "group"
+	ScopeChannel = "channel"
+	ScopeBot     = "bot"
+	ScopeMessage = "message"
+)
+
+// Config configures a Client. Zero values select the defaults above.
+type Config struct {
+	URL           string
+	Key           string
+	ChatsIndex    string
+	MessagesIndex string
+	ChatWeight    float64
+	MessageWeight float64
+	// CropLength is the snippet length in words, see highlight.DefaultCropLength.
+	CropLength int
+	// HighlightOpen and HighlightClose wrap matches in Hit.Highlight,
+	// "<mark>" and "</mark>" by default.
+	HighlightOpen  string
+	HighlightClose string
+	Timeout        time.Duration
+	TaskTimeout    time.Duration
+	BatchSize      int
+	HTTPClient     *http.Client
+}
+
+// Client accesses Meilisearch. It is safe for concurrent use.
+type Client struct {
+	cfg Config
+}
+
+// New returns a Client for cfg.
+func New(cfg Config) *Client {
+	if cfg.ChatsIndex == "" {
+		cfg.ChatsIndex = DefaultChatsIndex
+	}
+	if cfg.MessagesIndex == "" {
+		cfg.MessagesIndex = DefaultMessagesIndex
+	}
+	if cfg.ChatWeight <= 0 {
+		cfg.ChatWeight = DefaultChatWeight
+	}
+	if cfg.MessageWeight <= 0 {
+		cfg.MessageWeight = DefaultMessageWeight
+	}
+	if cfg.HighlightOpen == "" && cfg.HighlightClose == "" {
+		cfg.HighlightOpen, cfg.HighlightClose = "<mark>", "</mark>"
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = DefaultTimeout
+	}
+	if cfg.TaskTimeout <= 0 {
+		cfg.TaskTimeout = DefaultTaskTimeout
+	}
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = DefaultBatchSize
+	}
+	if cfg.HTTPClient == nil {
+		cfg.HTTPClient = &http.Client{}
+	}
+	cfg.URL = strings.TrimRight(cfg.URL, "/")
+	return &Client{cfg: cfg}
+}
+
+// Options are the optional parameters of a search, validated by the query
+// package: Sort is relevance, newest, members or growth; From and To bound the
+// message date; Facets lists type, language, country or categories.
+type Options struct {
+	Sort   string
+	From   string
+	To     string
+	Facets []string
+}
+
+// Request is a search.
+type Request struct {
+	// Query uses the syntax of the query package.
+	Query string
+	// Scope is one of the Scope constants; "" means ScopeAll.
+	Scope       string
+	Page        int
+	HitsPerPage int
+	Options
+}
+
+// Hit is a chat or a message. Chat hits leave the message fields empty.
+type Hit struct {
+	Kind         string `json:"kind"`
+	ID           string `json:"id"`
+	ChatID       string `json:"chatId"`
+	Type         string `json:"type"`
+	Title        string `json:"title"`
+	Username     string `json:"username,omitempty"`
+	Description  string `json:"description,omitempty"`
+	MembersCount int64  `json:"membersCount,omitempty"`
+	MessageID    int64  `json:"messageId,omitempty"`
+	Text         string `json:"text,omitempty"`
+	// Date is the message time in Unix seconds.
+	Date int64 `json:"date,omitempty"`
+	// Score is the weighted ranking score of a search over all scopes.
+	Score float64 `json:"score,omitempty"`
+	// Highlight maps title, description and text to escaped HTML with the
+	// matches wrapped in the configured tags; description and text are
+	// cropped around the first match.
+	Highlight map[string]string `json:"highlight,omitempty"`
+	// MatchesPosition maps the same fields to the byte offsets of the matches.
+	MatchesPosition map[string][]Match `json:"matchesPosition,omitempty"`
+
+	snippets map[string]string
+}
+
+// Match is the position of a matched term in a field.
+type Match struct {
+	Start  int `json:"start"`
+	Length int `json:"length"`
+}
+
+// Snippet returns the cropped plain text of a highlighted field, or "" when
+// the hit has none.
+func (h Hit) Snippet(field string) string {
+	return h.snippets[field]
+}
+
+// Result is a page of hits.
+type Result struct {
+	Hits        []Hit `json:"hits"`
+	Page        int   `json:"page"`
+	HitsPerPage int   `json:"hitsPerPage"`
+	TotalPages  int   `json:"totalPages"`
+	// TotalHits is estimated for searches over all scopes.
+	TotalHits int `json:"totalHits"`
+	// Facets maps each requested facet to the number of hits per value.
+	Facets map[string]map[string]int `json:"facets,omitempty"`
+}
+
+// Sorts returns the sort orders available in a scope and the one used when
+// none is given. Searches over all scopes are merged by relevance only.
+func Sorts(scope string) (sorts []string, defaultSort string) {
+	switch scope {
+	case ScopeGroup, ScopeChannel, ScopeBot:
+		return query.ChatFields.Sorts(), query.ChatFields.DefaultSort
+	case ScopeMessage:
+		return query.MessageFields.Sorts(), query.MessageFields.DefaultSort
+	}
+	return []string{query.SortRelevance}, query.SortRelevance
+}
+
+// indexQuery is the search of one index within a request.
+type indexQuery struct {
+	kind      string
+	index     string
+	weight    float64
+	fields    query.Fields
+	highlight highlight.Fields
+	params    map[string]interface{}
+	// facetNames and facetAttributes map the requested facets to attributes.
+	facetNames      []string
+	facetAttributes []string
+}
+
+// Search runs req. Malformed queries and options return an error matching
+// query.ErrInvalidQuery.
+func (c *Client) Search(ctx context.Context, req Request) (*Result, error) {
+	if req.Page < 1 {
+		req.Page = 1
+	}
+	if req.HitsPerPage < 1 {
+		req.HitsPerPage = DefaultHitsPerPage
+	}
+	if req.HitsPerPage > MaxHitsPerPage {
+		req.HitsPerPage = MaxHitsPerPage
+	}
+
+	switch req.Scope {
+	case ScopeGroup, ScopeChannel, ScopeBot, ScopeMessage:
+		q, err := c.indexQuery(req, req.Scope)
+		if err != nil {
+			return nil, err
+		}
+		return c.searchIndex(ctx, req, q)
+	case ScopeAll, "":
+		return c.searchAll(ctx, req)
+	}
+	return nil, fmt.Errorf("%w: unknown scope %q", query.ErrInvalidQuery, req.Scope)
+}
+
+// indexQuery builds the search of the index behind scope; ScopeAll searches
+// all chats.
+func (c *Client) indexQuery(req Request, scope string) (*indexQuery, error) {
+	q := &indexQuery{
+		kind:      KindChat,
+		index:     c.cfg.ChatsIndex,
+		weight:    c.cfg.ChatWeight,
+		fields:    query.ChatFields,
+		highlight: highlight.ChatFields,
+	}
+	var scopeFilter string
+	switch scope {
+	case ScopeGroup:
+		scopeFilter = fmt.Sprintf(`%s IN ["group", "supergroup"]`, q.fields.Type)
+	case ScopeChannel, ScopeBot:
+		scopeFilter = fmt.Sprintf(`%s = "%s"`, q.fields.Type, scope)
+	case ScopeMessage:
+		q.kind, q.index, q.weight = KindMessage, c.cfg.MessagesIndex, c.cfg.MessageWeight
+		q.fields, q.highlight = query.MessageFields, highlight.MessageFields
+		// Private chats with the bot are personal saves and never show up in public search.
+		scopeFilter = fmt.Sprintf(`%s EXISTS AND %s != "private"`, messageAttributes.MessageID, q.fields.Type)
+	}
+
+	parsed, err := query.Parse(req.Query, q.fields)
+	if err != nil {
+		return nil, err
+	}
+	sort, err := q.fields.SortRules(req.Sort)
+	if err != nil {
+		return nil, err
+	}
+	timeRange, err := q.fields.TimeRange(req.From, req.To, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	q.facetNames, q.facetAttributes, err = q.fields.FacetAttributes(req.Facets)
+	if err != nil {
+		return nil, err
+	}
+
+	q.params = q.highlight.Params(c.cfg.CropLength)
+	q.params["q"] = parsed.Text
+	if filter := query.And(parsed.Filter, timeRange, scopeFilter); filter != "" {
+		q.params["filter"] = filter
+	}
+	if sort != nil {
+		q.params["sort"] = sort
+	}
+	return q, nil
+}
+
+// searchIndex searches a single index with page-based pagination.
+func (c *Client) searchIndex(ctx context.Context, req Request, q *indexQuery) (*Result, error) {
+	body := q.params
+	body["page"] = req.Page
+	body["hitsPerPage"] = req.HitsPerPage
+	if q.facetAttributes != nil {
+		body["facets"] = q.facetAttributes
+	}
+
+	var resp struct {
+		Hits              []map[string]interface{}  `json:"hits"`
+		Page              int                       `json:"page"`
+		HitsPerPage       int                       `json:"hitsPerPage"`
+		TotalPages        int                       `json:"totalPages"`
+		TotalHits         int                       `json:"totalHits"`
+		FacetDistribution map[string]map[string]int `json:"facetDistribution"`
+	}
+	if err := c.do(ctx, http.MethodPost, "/indexes/"+q.index+"/search", body, &resp); err != nil {
+		return nil, err
+	}
+
+	result := &Result{
+		Hits:        make([]Hit, 0, len(resp.Hits)),
+		Page:        resp.Page,
+		HitsPerPage: resp.HitsPerPage,
+		TotalPages:  resp.TotalPages,
+		TotalHits:   resp.TotalHits,
+	}
+	for _, doc := range resp.Hits {
+		result.Hits = append(result.Hits, c.newHit(q.kind, doc))
+	}
+	if len(q.facetNames) > 0 {
+		result.Facets = make(map[string]map[string]int, len(q.facetNames))
+		addFacets(result.Facets, q, resp.FacetDistribution)
+	}
+	return result, nil
+}
+
+// searchAll merges chats and messages with federated search. An index that
+// cannot run the query, for example messages for members:>1000 or chats for
+// from=24h, is left out; the search fails only if no index can run it.
+// Facets an index does not have are requested from the others.
+func (c *Client) searchAll(ctx context.Context, req Request) (*Result, error) {
+	if sort := strings.ToLower(strings.TrimSpace(req.Sort)); sort != "" && sort != query.SortRelevance {
+		return nil, fmt.Errorf("%w: sort %q needs a filter, results over all scopes are ranked by relevance", query.ErrInvalidQuery, sort)
+	}
+	facets := req.Facets
+	var queries []*indexQuery
+	var firstErr error
+	for _, scope := range []string{ScopeAll, ScopeMessage} {
+		indexReq := req
+		indexReq.Sort = query.SortRelevance
+		indexReq.Facets = nil
+		q, err := c.indexQuery(indexReq, scope)
+		if err != nil {
+			if !errors.Is(err, query.ErrInvalidQuery) {
+				return nil, err
+			}
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		queries = append(queries, q)
+	}
+	if len(queries) == 0 {
+		return nil, firstErr
+	}
+	if err := selectFacets(queries, facets); err != nil {
+		return nil, err
+	}
+
+	federation := map[string]interface{}{
+		"offset": (req.Page - 1) * req.HitsPerPage,
+		"limit":  req.HitsPerPage,
+	}
+	facetsByIndex := make(map[string][]string)
+	multi := make([]map[string]interface{}, 0, len(queries))
+	byIndex := make(map[string]*indexQuery, len(queries))
+	for _, q := range queries {
+		params := q.params
+		params["indexUid"] = q.index
+		params["federationOptions"] = map[string]interface{}{"weight": q.weight}
+		multi = append(multi, params)
+		byIndex[q.index] = q
+		if q.facetAttributes != nil {
+			facetsByIndex[q.index] = q.facetAttributes
+		}
+	}
+	if len(facetsByIndex) > 0 {
+		federation["facetsByIndex"] = facetsByIndex
+	}
+
+	var resp struct {
+		Hits               []map[string]interface{} `json:"hits"`
+		EstimatedTotalHits int                      `json:"estimatedTotalHits"`
+		FacetsByIndex      map[string]struct {
+			Distribution map[string]map[string]int `json:"distribution"`
+		} `json:"facetsByIndex"`
+	}
+	body := map[string]interface{}{"federation": federation, "queries": multi}
+	if err := c.do(ctx, http.MethodPost, "/multi-search", body, &resp); err != nil {
+		return nil, err
+	}
+
+	result := &Result{
+		Hits:        make([]Hit, 0, len(resp.Hits)),
+		Page:        req.Page,
+		HitsPerPage: req.HitsPerPage,
+		TotalPages:  int(math.Ceil(float64(resp.EstimatedTotalHits) / float64(req.HitsPerPage))),
+		TotalHits:   resp.EstimatedTotalHits,
+	}
+	for _, doc := range resp.Hits {
+		federated, _ := doc["_federation"].(map[string]interface{})
+		indexUID, _ := federated["indexUid"].(string)
+		kind := KindChat
+		if q, ok := byIndex[indexUID]; ok {
+			kind = q.kind
+		}
+		hit := c.newHit(kind, doc)
+		hit.Score, _ = federated["weightedRankingScore"].(float64)
+		result.Hits = append(result.Hits, hit)
+	}
+	if len(facets) > 0 {
+		result.Facets = make(map[string]map[string]int)
+		for _, q := range queries {
+			addFacets(result.Facets, q, resp.FacetsByIndex[q.index].Distribution)
+		}
+	}
+	return result, nil
+}
+
+// selectFacets assigns each requested facet to the queries whose index has
+// it, and fails for facets no index has.
+func selectFacets(queries []*indexQuery, facets []string) error {
+	for _, list := range facets {
+		for _, name := range strings.Split(list, ",") {
+			var lastErr error
+			found := false
+			for _, q := range queries {
+				names, attributes, err := q.fields.FacetAttributes([]string{name})
+				if err != nil {
+					lastErr = err
+					continue
+				}
+				found = true
+				if len(names) > 0 && !slices.Contains(q.facetNames, names[0]) {
+					q.facetNames = append(q.facetNames, names[0])
+					q.facetAttributes = append(q.facetAttributes, attributes[0])
+				}
+			}
+			if !found {
+				return lastErr
+			}
+		}
+	}
+	return nil
+}
+
+// addFacets adds the distribution of q's facet attributes to facets under the
+// facet names, summing counts across indexes.
+func addFacets(facets map[string]map[string]int, q *indexQuery, distribution map[string]map[string]int) {
+	for i, name := range q.facetNames {
+		counts := facets[name]
+		if counts == nil {
+			counts = make(map[string]int)
+			facets[name] = counts
+		}
+		for value, n := range distribution[q.facetAttributes[i]] {
+			counts[value] += n
+		}
+	}
+}
+
+// SearchIndex runs a raw search on index uid with Meilisearch search
+// parameters and decodes the response into out, for indexes other than chats
+// and messages.
+func (c *Client) SearchIndex(ctx context.Context, uid string, params map[string]interface{}, out interface{}) error {
+	if err := c.do(ctx, http.MethodPost, "/indexes/"+uid+"/search", params, out); err != nil {
+		return fmt.Errorf("failed to search %s: %w", uid, err)
+	}
+	return nil
+}
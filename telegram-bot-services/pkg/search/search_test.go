@@ -0,0 +1,147 @@
+package search
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"telegram-bot-services/pkg/query"
+)
+
+// fakeMeili records the last request and answers with response.
+type fakeMeili struct {
+	path     string
+	body     map[string]interface{}
+	response string
+}
+
+func (f *fakeMeili) start(t *testing.T) *Client {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		f.path = r.URL.Path
+		f.body = nil
+		if err := json.NewDecoder(r.Body).Decode(&f.body); err != nil {
+			t.Errorf("decode request: %v", err)
+		}
+		w.Write([]byte(f.response))
+	}))
+	t.Cleanup(srv.Close)
+	return New(Config{URL: srv.URL, Key: "key", HighlightOpen: "<b>", HighlightClose: "</b>"})
+}
+
+func TestSearchAllFederates(t *testing.T) {
+	meili := &fakeMeili{response: `{
+		"hits": [
+			{"id": "-100", "type": "channel", "title": "Crypto News", "members_count": 1200,
+			 "_formatted": {"title": "\ue000Crypto\ue001 News"},
+			 "_federation": {"indexUid": "telegram_index", "weightedRankingScore": 0.98}},
+			{"id": "-100_7", "chat_id": -100, "message_id": 7, "title": "Chat", "type": "supergroup", "date": 1700000000,
+			 "text": "buy <crypto> now", "_formatted": {"text": "…buy <\ue000crypto\ue001> now"},
+			 "_matchesPosition": {"text": [{"start": 5, "length": 6}]},
+			 "_federation": {"indexUid": "messages", "weightedRankingScore": 0.81}}
+		],
+		"estimatedTotalHits": 21,
+		"facetsByIndex": {
+			"telegram_index": {"distribution": {"type": {"channel": 3}}},
+			"messages": {"distribution": {"type": {"channel": 2, "supergroup": 5}}}
+		}
+	}`}
+	client := meili.start(t)
+
+	result, err := client.Search(context.Background(), Request{Query: "crypto", Page: 2, HitsPerPage: 10, Options: Options{Facets: []string{"type"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if meili.path != "/multi-search" {
+		t.Fatalf("path = %s, want /multi-search", meili.path)
+	}
+	federation := meili.body["federation"].(map[string]interface{})
+	if federation["offset"] != 10.0 || federation["limit"] != 10.0 {
+		t.Errorf("federation = %v", federation)
+	}
+	queries := meili.body["queries"].([]interface{})
+	if len(queries) != 2 {
+		t.Fatalf("queries = %v", queries)
+	}
+	messages := queries[1].(map[string]interface{})
+	if messages["indexUid"] != "messages" || messages["filter"] != `message_id EXISTS AND type != "private"` ||
+		messages["federationOptions"].(map[string]interface{})["weight"] != DefaultMessageWeight || messages["sort"] != nil {
+		t.Errorf("messages query = %v", messages)
+	}
+
+	if result.TotalHits != 21 || result.TotalPages != 3 || result.Page != 2 {
+		t.Errorf("result = %+v", result)
+	}
+	chat, message := result.Hits[0], result.Hits[1]
+	if chat.Kind != KindChat || chat.ChatID != "-100" || chat.MembersCount != 1200 || chat.Highlight["title"] != "<b>Crypto</b> News" || chat.Score != 0.98 {
+		t.Errorf("chat hit = %+v", chat)
+	}
+	if message.Kind != KindMessage || message.ChatID != "-100" || message.MessageID != 7 || message.Date != 1700000000 ||
+		message.Highlight["text"] != "…buy &lt;<b>crypto</b>&gt; now" || message.Snippet("text") != "…buy <crypto> now" ||
+		len(message.MatchesPosition["text"]) != 1 {
+		t.Errorf("message hit = %+v", message)
+	}
+	if got := result.Facets["type"]; got["channel"] != 5 || got["supergroup"] != 5 {
+		t.Errorf("facets = %v", result.Facets)
+	}
+}
+
+func TestSearchAllSkipsIndexesThatCannotRunTheQuery(t *testing.T) {
+	meili := &fakeMeili{response: `{"hits": [], "estimatedTotalHits": 0}`}
+	client := meili.start(t)
+
+	if _, err := client.Search(context.Background(), Request{Query: "news members:>1000", Options: Options{Facets: []string{"language"}}}); err != nil {
+		t.Fatal(err)
+	}
+	queries := meili.body["queries"].([]interface{})
+	if len(queries) != 1 || queries[0].(map[string]interface{})["indexUid"] != "telegram_index" {
+		t.Errorf("queries = %v, want chats only", queries)
+	}
+
+	if _, err := client.Search(context.Background(), Request{Query: "news", Options: Options{From: "24h"}}); err != nil {
+		t.Fatal(err)
+	}
+	queries = meili.body["queries"].([]interface{})
+	if len(queries) != 1 || queries[0].(map[string]interface{})["indexUid"] != "messages" {
+		t.Errorf("queries = %v, want messages only", queries)
+	}
+}
+
+func TestSearchErrors(t *testing.T) {
+	client := (&fakeMeili{response: `{}`}).start(t)
+	for _, req := range []Request{
+		{Query: "news", Options: Options{Sort: "members"}},
+		{Query: "members:>10 after:2025-01-01"},
+		{Query: "news", Options: Options{Facets: []string{"owner"}}},
+		{Query: "news", Scope: "forum"},
+		{Query: "news", Scope: ScopeMessage, Options: Options{Sort: "growth"}},
+	} {
+		if _, err := client.Search(context.Background(), req); !errors.Is(err, query.ErrInvalidQuery) {
+			t.Errorf("Search(%+v) error = %v, want ErrInvalidQuery", req, err)
+		}
+	}
+}
+
+func TestSearchScope(t *testing.T) {
+	meili := &fakeMeili{response: `{"hits": [{"id": "-200", "type": "supergroup", "title": "Group"}],
+		"page": 1, "hitsPerPage": 5, "totalPages": 1, "totalHits": 1,
+		"facetDistribution": {"language_code": {"zh": 1}}}`}
+	client := meili.start(t)
+
+	result, err := client.Search(context.Background(), Request{Query: "group", Scope: ScopeGroup, HitsPerPage: 5, Options: Options{Facets: []string{"language"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if meili.path != "/indexes/telegram_index/search" || meili.body["filter"] != `type IN ["group", "supergroup"]` ||
+		meili.body["sort"].([]interface{})[0] != "members_count:desc" {
+		t.Errorf("request %s %v", meili.path, meili.body)
+	}
+	if len(result.Hits) != 1 || result.Hits[0].Kind != KindChat || result.Facets["language"]["zh"] != 1 {
+		t.Errorf("result = %+v", result)
+	}
+	if sorts, def := Sorts(ScopeAll); len(sorts) != 1 || def != query.SortRelevance {
+		t.Errorf("Sorts(all) = %v, %q", sorts, def)
+	}
+}